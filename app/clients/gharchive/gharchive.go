@@ -0,0 +1,135 @@
+package gharchive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Event is a single line of an hourly GH Archive dump.
+// Ref: https://www.gharchive.org
+type Event struct {
+	Type      string          `json:"type"`
+	Actor     Actor           `json:"actor"`
+	Repo      Repo            `json:"repo"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Actor ...
+type Actor struct {
+	Login     string `json:"login"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// Repo ...
+type Repo struct {
+	Name string `json:"name"` // username/repo
+}
+
+// PushPayload is the payload of a PushEvent.
+type PushPayload struct {
+	Commits []PushCommit `json:"commits"`
+}
+
+// PushCommit ...
+type PushCommit struct {
+	SHA      string `json:"sha"`
+	Message  string `json:"message"`
+	Distinct bool   `json:"distinct"`
+	Author   struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"author"`
+}
+
+// PushEvent is a PushEvent, with its payload decoded.
+type PushEvent struct {
+	Event
+	Commits []PushCommit
+}
+
+// FindFiles returns the file at the path, or the gzipped
+// archive files inside the path if it is a directory.
+func FindFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json.gz") {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no archive files found in %s", path)
+	}
+
+	return files, nil
+}
+
+// ReadPushEvents reads a gzipped archive file, and calls fn for every PushEvent.
+// Other event types are skipped without decoding their payload.
+func ReadPushEvents(path string, fn func(event PushEvent)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	defer gz.Close()
+
+	return readPushEvents(gz, fn)
+}
+
+// readPushEvents decodes the newline-delimited events from the reader.
+func readPushEvents(r io.Reader, fn func(event PushEvent)) error {
+	decoder := json.NewDecoder(r)
+
+	for {
+		event := Event{}
+		err := decoder.Decode(&event)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("not able to decode event: %v", err)
+		}
+
+		if event.Type != "PushEvent" {
+			continue
+		}
+
+		payload := PushPayload{}
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return fmt.Errorf("not able to decode PushEvent payload: %v", err)
+		}
+
+		fn(PushEvent{Event: event, Commits: payload.Commits})
+	}
+}
@@ -0,0 +1,93 @@
+package gharchive
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	u "github.com/tunedmystic/commits.lol/app/utils"
+)
+
+func Test_ReadPushEvents(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gharchive")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "2020-12-01-15.json.gz")
+	writeArchive(t, path, testEvents)
+
+	events := []PushEvent{}
+	err := ReadPushEvents(path, func(event PushEvent) {
+		events = append(events, event)
+	})
+
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(events), 1)
+	u.AssertEqual(t, events[0].Actor.Login, "alice")
+	u.AssertEqual(t, events[0].Repo.Name, "alice/gems")
+	u.AssertEqual(t, events[0].CreatedAt.Format("2006-01-02"), "2020-12-01")
+	u.AssertEqual(t, len(events[0].Commits), 2)
+	u.AssertEqual(t, events[0].Commits[0].Message, "fixed a stupid bug lol")
+	u.AssertEqual(t, events[0].Commits[0].Distinct, true)
+	u.AssertEqual(t, events[0].Commits[1].Distinct, false)
+}
+
+func Test_ReadPushEvents_not_gzipped(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gharchive")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "2020-12-01-15.json.gz")
+	ioutil.WriteFile(path, []byte(testEvents), 0644)
+
+	err := ReadPushEvents(path, func(event PushEvent) {})
+	u.AssertEqual(t, err.Error(), path+": gzip: invalid header")
+}
+
+func Test_readPushEvents_bad_json(t *testing.T) {
+	err := readPushEvents(strings.NewReader(`{"type": "PushEvent"`), func(event PushEvent) {})
+	u.AssertEqual(t, err.Error(), "not able to decode event: unexpected EOF")
+}
+
+func Test_FindFiles(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gharchive")
+	defer os.RemoveAll(dir)
+
+	_, err := FindFiles(dir)
+	u.AssertEqual(t, err.Error(), "no archive files found in "+dir)
+
+	writeArchive(t, filepath.Join(dir, "2020-12-01-16.json.gz"), testEvents)
+	writeArchive(t, filepath.Join(dir, "2020-12-01-15.json.gz"), testEvents)
+	ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hi"), 0644)
+
+	files, err := FindFiles(dir)
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(files), 2)
+	u.AssertEqual(t, filepath.Base(files[0]), "2020-12-01-15.json.gz")
+
+	// A single file is returned as is.
+	files, err = FindFiles(files[1])
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(files), 1)
+}
+
+// ------------------------------------------------------------------
+// Helpers
+// ------------------------------------------------------------------
+
+func writeArchive(t *testing.T, path, data string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(data))
+	gz.Close()
+}
+
+const testEvents = `{"id":"1","type":"WatchEvent","actor":{"login":"bob"},"repo":{"name":"alice/gems"},"payload":{"action":"started"},"created_at":"2020-12-01T15:00:00Z"}
+{"id":"2","type":"PushEvent","actor":{"login":"alice","avatar_url":"https://avatars.githubusercontent.com/u/1?"},"repo":{"name":"alice/gems"},"payload":{"push_id":1,"size":2,"distinct_size":1,"commits":[{"sha":"6104942438c14ec7bd21c6cd5bd995272b3faff6","author":{"name":"Alice","email":"alice@example.com"},"message":"fixed a stupid bug lol","distinct":true},{"sha":"7d0e7bc1c6cd5bd995272b3faff66104942438c1","author":{"name":"Alice","email":"alice@example.com"},"message":"stupid typo","distinct":false}]},"created_at":"2020-12-01T15:01:00Z"}
+`
@@ -0,0 +1,26 @@
+package gitea
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// APIError ...
+type APIError struct {
+	URL        string `json:"-"`
+	StatusCode int    `json:"-"`
+	Message    string `json:"message"`
+}
+
+// NewAPIError ...
+func NewAPIError(url string, data []byte, statusCode int) *APIError {
+	e := APIError{URL: url, StatusCode: statusCode}
+	if err := json.Unmarshal(data, &e); err != nil {
+		e.Message = "not able to unmarshal error response"
+	}
+	return &e
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gitea error %v: %v | URL: %v", e.StatusCode, e.Message, e.URL)
+}
@@ -0,0 +1,139 @@
+package gitea
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beefsack/go-rate"
+	"github.com/tunedmystic/commits.lol/app/config"
+	"github.com/tunedmystic/commits.lol/app/utils"
+)
+
+// Client for a Gitea (or Forgejo) instance, such as Codeberg.
+type Client struct {
+	baseURL     string
+	apiKey      string
+	limiter     *rate.RateLimiter
+	repoLimit   int
+	commitLimit int
+	calls       int
+	budget      *utils.CallBudget
+}
+
+// NewClient ...
+func NewClient(baseURL string) Client {
+	return Client{
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		apiKey:      config.App.GiteaAPIKey,
+		limiter:     rate.New(60, time.Minute),   // 60 times per minute
+		repoLimit:   config.App.GiteaRepoLimit,   // Max amount of recently updated repos to crawl
+		commitLimit: config.App.GiteaCommitLimit, // Max amount of commits to fetch per repo
+	}
+}
+
+// BaseURL returns the URL of the instance.
+func (g *Client) BaseURL() string {
+	return g.baseURL
+}
+
+// Calls returns the amount of API requests made by the client.
+func (g *Client) Calls() int {
+	return g.calls
+}
+
+// SetBudget sets the budget that the client's API requests are taken from.
+// Once it's spent, the requests fail with utils.ErrBudgetSpent.
+func (g *Client) SetBudget(budget *utils.CallBudget) {
+	g.budget = budget
+}
+
+// get makes a GET request to the API and unmarshals the response into v.
+func (g *Client) get(path string, params url.Values, v interface{}) error {
+	if !g.budget.Spend() {
+		return utils.ErrBudgetSpent
+	}
+
+	// Check the rate limit, and block until the rate limit has lifted.
+	g.limiter.Wait()
+	g.calls++
+
+	// Build request
+	url := fmt.Sprintf("%v/api/v1%v", g.baseURL, path)
+	if len(params) > 0 {
+		url += "?" + params.Encode()
+	}
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Add("User-Agent", "commits.lol")
+	req.Header.Add("Accept", "application/json")
+	if g.apiKey != "" {
+		req.Header.Add("Authorization", "token "+g.apiKey)
+	}
+
+	// Make request
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %v", err)
+	}
+
+	// Read the response body.
+	data, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return NewAPIError(url, data, res.StatusCode)
+	}
+
+	// Unmarshal the JSON data.
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("not able to unmarshal response: %v", err)
+	}
+
+	return nil
+}
+
+// RecentRepos returns the most recently updated repositories on the instance.
+// Gitea has no global commit search, so recent repo activity is the starting point for crawling.
+// Example:
+//
+//	https://codeberg.org/api/v1/repos/search?sort=updated&order=desc&limit=50
+func (g *Client) RecentRepos() ([]Repository, error) {
+	params := url.Values{}
+	params.Set("sort", "updated")
+	params.Set("order", "desc")
+	params.Set("limit", strconv.Itoa(g.repoLimit))
+
+	response := RepoSearchResponse{}
+	if err := g.get("/repos/search", params, &response); err != nil {
+		return nil, err
+	}
+
+	return response.Data, nil
+}
+
+// RepoCommits returns the latest commits of a repository, made after the `since` date.
+// Example:
+//
+//	https://codeberg.org/api/v1/repos/owner/repo/commits?limit=50&stat=false
+func (g *Client) RepoCommits(fullName string, since time.Time) ([]CommitItem, error) {
+	params := url.Values{}
+	params.Set("limit", strconv.Itoa(g.commitLimit))
+	params.Set("stat", "false")
+	params.Set("verification", "false")
+	params.Set("files", "false")
+	if !since.IsZero() {
+		params.Set("since", since.Format(time.RFC3339))
+	}
+
+	items := []CommitItem{}
+	if err := g.get(fmt.Sprintf("/repos/%s/commits", fullName), params, &items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
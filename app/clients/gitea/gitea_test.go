@@ -0,0 +1,152 @@
+package gitea
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	u "github.com/tunedmystic/commits.lol/app/utils"
+)
+
+func Test_RecentRepos(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	g := NewClient(s.URL)
+
+	repos, err := g.RecentRepos()
+
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(repos), 2)
+	u.AssertEqual(t, repos[0].FullName, "alice/gems")
+	u.AssertEqual(t, repos[0].URL, "https://codeberg.org/alice/gems")
+}
+
+func Test_RepoCommits(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	g := NewClient(s.URL)
+
+	items, err := g.RepoCommits("alice/gems", time.Time{})
+
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(items), 3)
+	u.AssertEqual(t, items[0].Commit.Message, "fixed a stupid bug lol\n")
+	u.AssertEqual(t, items[0].Author.Login, "alice")
+
+	// Authors without an account are null.
+	var noAuthor *User
+	u.AssertEqual(t, items[2].Author, noAuthor)
+}
+
+func Test_RepoCommits_APIError(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	g := NewClient(s.URL)
+
+	expected := fmt.Sprintf("gitea error 409: Git Repository is empty. | URL: %v/api/v1/repos/bob/empty/commits?files=false&limit=50&stat=false&verification=false", s.URL)
+
+	items, err := g.RepoCommits("bob/empty", time.Time{})
+
+	u.AssertEqual(t, len(items), 0)
+	u.AssertEqual(t, err.Error(), expected)
+}
+
+func Test_RecentRepos_invalid_url(t *testing.T) {
+	g := NewClient("1")
+
+	_, err := g.RecentRepos()
+
+	u.AssertEqual(t, err.Error(), `error making request: Get "1/api/v1/repos/search?limit=50&order=desc&sort=updated": unsupported protocol scheme ""`)
+}
+
+func Test_BaseURL(t *testing.T) {
+	g := NewClient("https://codeberg.org/")
+	u.AssertEqual(t, g.BaseURL(), "https://codeberg.org")
+}
+
+// ------------------------------------------------------------------
+// Helpers
+// ------------------------------------------------------------------
+
+// testServer creates a stand-in Gitea server so we can mock our API responses.
+func testServer() *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/v1/repos/search", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(responseRepoSearch))
+	})
+
+	mux.HandleFunc("/api/v1/repos/alice/gems/commits", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(responseRepoCommits))
+	})
+
+	mux.HandleFunc("/api/v1/repos/bob/empty/commits", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"message": "Git Repository is empty.", "url": "https://codeberg.org/api/swagger"}`))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+// ------------------------------------------------------------------
+// Test JSON response data
+// ------------------------------------------------------------------
+
+const responseRepoSearch = `{
+    "ok": true,
+    "data": [
+        {
+            "id": 1,
+            "name": "gems",
+            "full_name": "alice/gems",
+            "description": "All the gems",
+            "html_url": "https://codeberg.org/alice/gems",
+            "owner": {"login": "alice", "avatar_url": "https://codeberg.org/avatars/alice"},
+            "updated_at": "2020-12-03T10:00:00Z"
+        },
+        {
+            "id": 2,
+            "name": "empty",
+            "full_name": "bob/empty",
+            "description": "",
+            "html_url": "https://codeberg.org/bob/empty",
+            "owner": {"login": "bob", "avatar_url": "https://codeberg.org/avatars/bob"},
+            "updated_at": "2020-12-02T10:00:00Z"
+        }
+    ]
+}`
+
+const responseRepoCommits = `[
+    {
+        "sha": "6104942438c14ec7bd21c6cd5bd995272b3faff6",
+        "html_url": "https://codeberg.org/alice/gems/commit/6104942438c14ec7bd21c6cd5bd995272b3faff6",
+        "commit": {
+            "message": "fixed a stupid bug lol\n",
+            "author": {"name": "Alice", "email": "alice@example.com", "date": "2020-12-03T10:00:00Z"}
+        },
+        "author": {"login": "alice", "avatar_url": "https://codeberg.org/avatars/alice"}
+    },
+    {
+        "sha": "7d0e7bc1c6cd5bd995272b3faff66104942438c1",
+        "html_url": "https://codeberg.org/alice/gems/commit/7d0e7bc1c6cd5bd995272b3faff66104942438c1",
+        "commit": {
+            "message": "Add [WIP] stupid feature\n",
+            "author": {"name": "Alice", "email": "alice@example.com", "date": "2020-12-02T10:00:00Z"}
+        },
+        "author": {"login": "alice", "avatar_url": "https://codeberg.org/avatars/alice"}
+    },
+    {
+        "sha": "c6cd5bd995272b3faff66104942438c17d0e7bc1",
+        "html_url": "https://codeberg.org/alice/gems/commit/c6cd5bd995272b3faff66104942438c17d0e7bc1",
+        "commit": {
+            "message": "stupid typo\n",
+            "author": {"name": "Someone", "email": "someone@example.com", "date": "2020-12-01T10:00:00Z"}
+        },
+        "author": null
+    }
+]`
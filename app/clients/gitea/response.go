@@ -0,0 +1,48 @@
+package gitea
+
+import "time"
+
+// RepoSearchResponse ...
+type RepoSearchResponse struct {
+	OK   bool         `json:"ok"`
+	Data []Repository `json:"data"`
+}
+
+// Repository ...
+type Repository struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	FullName    string    `json:"full_name"`
+	Description string    `json:"description"`
+	URL         string    `json:"html_url"`
+	Owner       User      `json:"owner"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CommitItem ...
+type CommitItem struct {
+	URL    string `json:"html_url"`
+	SHA    string `json:"sha"`
+	Commit Commit `json:"commit"`
+	Author *User  `json:"author"`
+}
+
+// Commit ...
+type Commit struct {
+	Message string     `json:"message"`
+	Author  AuthorInfo `json:"author"`
+}
+
+// AuthorInfo ...
+type AuthorInfo struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+// User ...
+type User struct {
+	Login     string `json:"login"`
+	AvatarURL string `json:"avatar_url"`
+	URL       string `json:"html_url"`
+}
@@ -3,21 +3,20 @@ package github
 import (
 	"encoding/json"
 	"fmt"
+
+	"github.com/tunedmystic/commits.lol/app/utils"
 )
 
 // Errors raised by Commit validation.
+// The errors for the commit message are raised by the MessageValidator.
 const (
 	ErrNoAuthor      ValidationError = "validate CommitItem: no author"
-	ErrMessageLength ValidationError = "validate CommitItem: commit message too long"
-	ErrMessageFormat ValidationError = "validate CommitItem: commit message has formatting issues"
+	ErrMessageLength                 = utils.ErrMessageLength
+	ErrMessageFormat                 = utils.ErrMessageFormat
 )
 
 // ValidationError is returned when a Commit is not valid.
-type ValidationError string
-
-func (err ValidationError) Error() string {
-	return string(err)
-}
+type ValidationError = utils.ValidationError
 
 // APIError ...
 type APIError struct {
@@ -10,6 +10,7 @@ import (
 
 	"github.com/beefsack/go-rate"
 	"github.com/tunedmystic/commits.lol/app/config"
+	"github.com/tunedmystic/commits.lol/app/utils"
 	"go.uber.org/zap"
 )
 
@@ -21,6 +22,8 @@ type Client struct {
 	searchLimiterHr  *rate.RateLimiter
 	maxFetch         int
 	commitLength     int
+	calls            int
+	budget           *utils.CallBudget
 }
 
 // NewClient ...
@@ -28,15 +31,28 @@ func NewClient() Client {
 	return Client{
 		baseURL:          "https://api.github.com",
 		apiKey:           config.App.GithubAPIKey,
-		searchLimiterMin: rate.New(30, time.Second*70),   // 30 times per 70 seconds
-		searchLimiterHr:  rate.New(5000, time.Minute*70), // 5000 times per 70 minutes
-		maxFetch:         config.App.GithubMaxFetch,      // Max amount of items to fetch when paginating
-		commitLength:     config.App.GithubCommitLength,  // Max length of commit message
+		searchLimiterMin: rate.New(config.App.GithubSearchPerMin, time.Second*70),   // N times per 70 seconds
+		searchLimiterHr:  rate.New(config.App.GithubSearchPerHour, time.Minute*70), // N times per 70 minutes
+		maxFetch:         config.App.GithubMaxFetch,                                // Max amount of items to fetch when paginating
+		commitLength:     config.App.GithubCommitLength,                            // Max length of commit message
 	}
 }
 
+// Calls returns the amount of API requests made by the client.
+func (g *Client) Calls() int {
+	return g.calls
+}
+
+// SetBudget sets the budget that the client's API requests are taken from.
+// Once it's spent, the requests fail with utils.ErrBudgetSpent.
+func (g *Client) SetBudget(budget *utils.CallBudget) {
+	g.budget = budget
+}
+
 // RateLimits checks the rate limit for the configured API Key.
 func (g *Client) RateLimits() (RateLimitResponse, error) {
+	g.calls++
+
 	// Build request
 	url := fmt.Sprintf("%v/rate_limit", g.baseURL)
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
@@ -84,6 +100,11 @@ func (g *Client) CommitSearch(options CommitSearchOptions) (CommitSearchResponse
 		return response, errors.New("no search options provided")
 	}
 
+	if !g.budget.Spend() {
+		return response, utils.ErrBudgetSpent
+	}
+	g.calls++
+
 	// Build request
 	url := fmt.Sprintf("%v/search/commits?%v", g.baseURL, options.Serialize())
 
@@ -122,8 +143,12 @@ func (g *Client) CommitSearchPaginated(options CommitSearchOptions) ([]CommitIte
 	for {
 		zap.S().Infof("  Query [%s], fetching Page %d", options.QueryText, options.Page)
 
-		// Perform search.
+		// Perform search. The pages fetched so far are kept, once the budget is spent.
 		response, err := g.CommitSearch(options)
+		if err == utils.ErrBudgetSpent {
+			zap.S().Debugf("    - Query [%s], the API call budget was spent at Page %d", options.QueryText, options.Page)
+			break
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -98,6 +98,24 @@ func Test_CommitSearchPaginated(t *testing.T) {
 
 	u.AssertEqual(t, len(commitItems), 10)
 	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, g.Calls(), 5)
+}
+
+func Test_CommitSearchPaginated_budget(t *testing.T) {
+	s := testServer(http.StatusOK, []byte(responseCommitSearchMany))
+	defer s.Close()
+
+	g := NewClient()
+	g.baseURL = s.URL
+	g.maxFetch = 10
+	g.SetBudget(u.NewCallBudget(3))
+
+	// The pages fetched before the budget was spent are kept.
+	commitItems, err := g.CommitSearchPaginated(CommitSearchOptions{QueryText: "fixed a bug"})
+
+	u.AssertEqual(t, len(commitItems), 6)
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, g.Calls(), 3)
 }
 
 // ------------------------------------------------------------------
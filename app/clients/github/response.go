@@ -1,17 +1,37 @@
 package github
 
 import (
-	"regexp"
 	"time"
 
 	"github.com/tunedmystic/commits.lol/app/config"
+	"github.com/tunedmystic/commits.lol/app/utils"
 )
 
-// CommitMessagePattern is used to validate a commit message.
-var CommitMessagePattern *regexp.Regexp
+// MessageValidator is used to validate a commit message.
+// Its rules are loaded from the ValidationRulesFile, if it's set.
+var MessageValidator *utils.MessageValidator
 
 func init() {
-	CommitMessagePattern = regexp.MustCompile(`^[a-zA-Z][\w '#%\.\!\:\-\)\(]+$`)
+	validator, err := NewMessageValidator()
+	if err != nil {
+		panic(err)
+	}
+	MessageValidator = validator
+}
+
+// NewMessageValidator creates a MessageValidator with the rules from the config.
+func NewMessageValidator() (*utils.MessageValidator, error) {
+	rules := utils.DefaultValidationRules(config.App.GithubCommitLength)
+
+	if config.App.ValidationRulesFile != "" {
+		loaded, err := utils.LoadValidationRules(config.App.ValidationRulesFile, rules)
+		if err != nil {
+			return nil, err
+		}
+		rules = loaded
+	}
+
+	return utils.NewMessageValidator(rules)
 }
 
 // RateLimitResponse ...
@@ -84,13 +104,11 @@ func (c CommitItem) Validate() error {
 		return ErrNoAuthor
 	}
 
-	if len(c.Commit.Message) > config.App.GithubCommitLength {
-		return ErrMessageLength
-	}
-
-	if match := CommitMessagePattern.MatchString(c.Commit.Message); !match {
-		return ErrMessageFormat
-	}
+	return ValidateMessage(c.Commit.Message)
+}
 
-	return nil
+// ValidateMessage checks a commit message against the validation rules.
+// It is shared by the other commit sources, so every commit is held to the same rules.
+func ValidateMessage(message string) error {
+	return MessageValidator.Validate(message)
 }
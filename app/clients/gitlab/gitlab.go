@@ -0,0 +1,252 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beefsack/go-rate"
+	"github.com/tunedmystic/commits.lol/app/config"
+	"github.com/tunedmystic/commits.lol/app/utils"
+	"go.uber.org/zap"
+)
+
+// Client for Gitlab
+type Client struct {
+	baseURL       string
+	apiKey        string
+	searchLimiter *rate.RateLimiter
+	lookupLimiter *rate.RateLimiter
+	maxFetch      int
+	perPage       int
+	calls         int
+	budget        *utils.CallBudget
+
+	mu       *sync.Mutex
+	projects map[int]Project
+	users    map[string]User
+}
+
+// NewClient ...
+func NewClient() Client {
+	return Client{
+		baseURL:       strings.TrimRight(config.App.GitlabBaseURL, "/"),
+		apiKey:        config.App.GitlabAPIKey,
+		searchLimiter: rate.New(10, time.Minute),  // 10 times per minute
+		lookupLimiter: rate.New(100, time.Minute), // 100 project and user lookups per minute
+		maxFetch:      config.App.GitlabMaxFetch,  // Max amount of items to fetch when paginating
+		perPage:       20,
+		mu:            &sync.Mutex{},
+		projects:      map[int]Project{},
+		users:         map[string]User{},
+	}
+}
+
+// Calls returns the amount of API requests made by the client.
+func (g *Client) Calls() int {
+	return g.calls
+}
+
+// SetBudget sets the budget that the client's API requests are taken from.
+// Once it's spent, the requests fail with utils.ErrBudgetSpent.
+func (g *Client) SetBudget(budget *utils.CallBudget) {
+	g.budget = budget
+}
+
+// get makes a GET request to the API and unmarshals the response into v.
+func (g *Client) get(path string, params url.Values, v interface{}) (http.Header, error) {
+	if !g.budget.Spend() {
+		return nil, utils.ErrBudgetSpent
+	}
+	g.calls++
+
+	// Build request
+	url := fmt.Sprintf("%v/api/v4%v", g.baseURL, path)
+	if len(params) > 0 {
+		url += "?" + params.Encode()
+	}
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Add("User-Agent", "commits.lol")
+	req.Header.Add("PRIVATE-TOKEN", g.apiKey)
+
+	// Make request
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %v", err)
+	}
+
+	// Read the response body.
+	data, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, NewAPIError(url, data, res.StatusCode)
+	}
+
+	// Unmarshal the JSON data.
+	if err = json.Unmarshal(data, v); err != nil {
+		return nil, fmt.Errorf("not able to unmarshal response: %v", err)
+	}
+
+	return res.Header, nil
+}
+
+// CommitSearch searches commits across the instance, and returns
+// the items along with the number of the next page (0 if it's the last page).
+// Global commit search requires Advanced Search to be enabled on the instance.
+// Example:
+//
+//	https://gitlab.com/api/v4/search?scope=commits&search=monkey&page=1
+func (g *Client) CommitSearch(term string, page int) ([]CommitItem, int, error) {
+	items := []CommitItem{}
+
+	// Check the rate limit, and block until the rate limit has lifted.
+	g.searchLimiter.Wait()
+
+	if term == "" {
+		return items, 0, errors.New("no search term provided")
+	}
+
+	params := url.Values{}
+	params.Set("scope", "commits")
+	params.Set("search", term)
+	params.Set("per_page", strconv.Itoa(g.perPage))
+	if page > 0 {
+		params.Set("page", strconv.Itoa(page))
+	}
+
+	header, err := g.get("/search", params, &items)
+	if err != nil {
+		return []CommitItem{}, 0, err
+	}
+
+	nextPage, _ := strconv.Atoi(header.Get("X-Next-Page"))
+	return items, nextPage, nil
+}
+
+// maxScanFactor bounds the items that are paginated through, as a multiple of maxFetch,
+// for the searches whose items are mostly dropped by the keep func.
+const maxScanFactor = 4
+
+// CommitSearchPaginated fetches the pages of the search, until maxFetch items are kept.
+// The results are sorted by relevance, not by date, so the items are filtered with
+// the keep func before they count towards maxFetch. A nil keep func keeps every item.
+func (g *Client) CommitSearchPaginated(term string, keep func(item CommitItem) bool) ([]CommitItem, error) {
+	commitItems := make([]CommitItem, 0, g.perPage) // stores commit objects across the fetched pages
+	page := 1
+	scanned := 0
+
+	for {
+		zap.S().Infof("  Gitlab Query [%s], fetching Page %d", term, page)
+
+		// Perform search. The pages fetched so far are kept, once the budget is spent.
+		items, nextPage, err := g.CommitSearch(term, page)
+		if err == utils.ErrBudgetSpent {
+			zap.S().Debugf("    - Gitlab Query [%s], the API call budget was spent at Page %d", term, page)
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			if keep == nil || keep(item) {
+				commitItems = append(commitItems, item)
+			}
+		}
+		scanned += len(items)
+		zap.S().Debugf("    - Gitlab Query [%s], Page %d, got %d items", term, page, len(items))
+
+		// Check if last page.
+		if nextPage == 0 || len(items) == 0 {
+			zap.S().Debugf("    - Gitlab Query [%s], reached last Page %d", term, page)
+			break
+		}
+
+		// Check max item threshold.
+		if len(commitItems) >= g.maxFetch {
+			zap.S().Debugf("    - Gitlab Query [%s], reached items limit of %d", term, g.maxFetch)
+			commitItems = commitItems[:g.maxFetch]
+			break
+		}
+
+		if scanned >= g.maxFetch*maxScanFactor {
+			zap.S().Debugf("    - Gitlab Query [%s], reached scan limit of %d", term, g.maxFetch*maxScanFactor)
+			break
+		}
+
+		page = nextPage
+	}
+
+	zap.S().Infof("  Gitlab Query [%s], total fetched: %d", term, len(commitItems))
+	return commitItems, nil
+}
+
+// Project returns the project with the given ID.
+// Projects are cached, since many commits share the same project.
+// The cache is shared by the copies of the client.
+func (g *Client) Project(ID int) (Project, error) {
+	g.mu.Lock()
+	project, ok := g.projects[ID]
+	g.mu.Unlock()
+
+	if ok {
+		return project, nil
+	}
+
+	// Check the rate limit, and block until the rate limit has lifted.
+	g.lookupLimiter.Wait()
+
+	if _, err := g.get(fmt.Sprintf("/projects/%d", ID), nil, &project); err != nil {
+		return Project{}, err
+	}
+
+	g.mu.Lock()
+	g.projects[ID] = project
+	g.mu.Unlock()
+
+	return project, nil
+}
+
+// UserByEmail looks up the user account for a commit author's email.
+// An empty User is returned if no account could be found.
+// Users are cached, since authors tend to show up more than once.
+// The cache is shared by the copies of the client.
+func (g *Client) UserByEmail(email string) (User, error) {
+	g.mu.Lock()
+	user, ok := g.users[email]
+	g.mu.Unlock()
+
+	if ok || email == "" {
+		return user, nil
+	}
+
+	// Check the rate limit, and block until the rate limit has lifted.
+	g.lookupLimiter.Wait()
+
+	users := []User{}
+	params := url.Values{}
+	params.Set("search", email)
+
+	if _, err := g.get("/users", params, &users); err != nil {
+		return User{}, err
+	}
+
+	// Only trust the result if the search was unambiguous.
+	if len(users) == 1 {
+		user = users[0]
+	}
+
+	g.mu.Lock()
+	g.users[email] = user
+	g.mu.Unlock()
+
+	return user, nil
+}
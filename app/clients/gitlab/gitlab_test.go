@@ -0,0 +1,262 @@
+package gitlab
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	u "github.com/tunedmystic/commits.lol/app/utils"
+)
+
+func Test_CommitSearch_OK(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	g := testClient(s.URL)
+
+	items, nextPage, err := g.CommitSearch("fixed a bug", 1)
+
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, nextPage, 2)
+	u.AssertEqual(t, len(items), 2)
+	u.AssertEqual(t, items[0].ID, "6104942438c14ec7bd21c6cd5bd995272b3faff6")
+	u.AssertEqual(t, items[0].Message, "fixed a bug\n")
+	u.AssertEqual(t, items[0].AuthorEmail, "alice@example.com")
+	u.AssertEqual(t, items[0].ProjectID, 6)
+	u.AssertEqual(t, items[0].AuthoredDate.Format("2006-01-02"), "2020-12-01")
+}
+
+func Test_CommitSearch_APIError(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	g := testClient(s.URL)
+	g.apiKey = "bad-token"
+
+	expected := fmt.Sprintf("gitlab error 401: 401 Unauthorized | URL: %v/api/v4/search?page=1&per_page=20&scope=commits&search=fixed+a+bug", s.URL)
+
+	items, _, err := g.CommitSearch("fixed a bug", 1)
+
+	u.AssertEqual(t, len(items), 0)
+	u.AssertEqual(t, err.Error(), expected)
+}
+
+func Test_CommitSearch_empty_term(t *testing.T) {
+	g := testClient("1")
+
+	items, _, err := g.CommitSearch("", 1)
+
+	u.AssertEqual(t, len(items), 0)
+	u.AssertEqual(t, err.Error(), "no search term provided")
+}
+
+func Test_CommitSearchPaginated(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	g := testClient(s.URL)
+
+	items, err := g.CommitSearchPaginated("fixed a bug", nil)
+
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(items), 3)
+}
+
+func Test_CommitSearchPaginated_max_fetch(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	g := testClient(s.URL)
+	g.maxFetch = 2
+
+	items, err := g.CommitSearchPaginated("fixed a bug", nil)
+
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(items), 2)
+}
+
+func Test_CommitSearchPaginated_keep(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	g := testClient(s.URL)
+	g.maxFetch = 1
+
+	// The items that aren't kept don't count towards maxFetch.
+	items, err := g.CommitSearchPaginated("fixed a bug", func(item CommitItem) bool {
+		return item.Message == "fixed a bug for real\n"
+	})
+
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(items), 1)
+	u.AssertEqual(t, items[0].ShortID, "c6cd5bd9")
+	u.AssertEqual(t, g.Calls(), 2)
+}
+
+func Test_CommitSearchPaginated_budget(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	g := testClient(s.URL)
+	g.SetBudget(u.NewCallBudget(1))
+
+	// The pages fetched before the budget was spent are kept.
+	items, err := g.CommitSearchPaginated("fixed a bug", nil)
+
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(items) > 0, true)
+	u.AssertEqual(t, len(items) < 3, true)
+	u.AssertEqual(t, g.Calls(), 1)
+
+	// Lookups fail once the budget is spent.
+	_, err = g.Project(1)
+	u.AssertEqual(t, err, u.ErrBudgetSpent)
+}
+
+func Test_Project(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	g := testClient(s.URL)
+
+	project, err := g.Project(6)
+
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, project.Name, "gems")
+	u.AssertEqual(t, project.WebURL, "https://gitlab.example.com/team/gems")
+
+	// The project is cached after the first lookup.
+	_, cached := g.projects[6]
+	u.AssertEqual(t, cached, true)
+
+	g.Project(6)
+	u.AssertEqual(t, g.Calls(), 1)
+}
+
+func Test_UserByEmail(t *testing.T) {
+	s := testServer()
+	defer s.Close()
+
+	g := testClient(s.URL)
+
+	user, err := g.UserByEmail("alice@example.com")
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, user.Username, "alice")
+	u.AssertEqual(t, user.WebURL, "https://gitlab.example.com/alice")
+
+	// Unknown authors do not have a user.
+	user, err = g.UserByEmail("nobody@example.com")
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, user, User{})
+}
+
+// ------------------------------------------------------------------
+// Helpers
+// ------------------------------------------------------------------
+
+func testClient(baseURL string) Client {
+	g := NewClient()
+	g.baseURL = baseURL
+	g.apiKey = "some-token"
+	return g
+}
+
+// testServer creates a fake Gitlab server so we can mock our API responses.
+func testServer() *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/v4/search", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "some-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "401 Unauthorized"}`))
+			return
+		}
+
+		if r.URL.Query().Get("page") == "2" {
+			w.Write([]byte(responseCommitSearchPage2))
+			return
+		}
+
+		w.Header().Set("X-Next-Page", "2")
+		w.Write([]byte(responseCommitSearchPage1))
+	})
+
+	mux.HandleFunc("/api/v4/projects/6", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(responseProject))
+	})
+
+	mux.HandleFunc("/api/v4/users", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("search") == "alice@example.com" {
+			w.Write([]byte(responseUsers))
+			return
+		}
+		w.Write([]byte(`[]`))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+// ------------------------------------------------------------------
+// Test JSON response data
+// ------------------------------------------------------------------
+
+const responseCommitSearchPage1 = `[
+    {
+        "id": "6104942438c14ec7bd21c6cd5bd995272b3faff6",
+        "short_id": "61049424",
+        "title": "fixed a bug",
+        "message": "fixed a bug\n",
+        "author_name": "Alice",
+        "author_email": "alice@example.com",
+        "authored_date": "2020-12-01T10:00:00.000Z",
+        "committer_name": "Alice",
+        "committer_email": "alice@example.com",
+        "committed_date": "2020-12-01T10:00:00.000Z",
+        "created_at": "2020-12-01T10:00:00.000Z",
+        "project_id": 6,
+        "web_url": "https://gitlab.example.com/team/gems/-/commit/6104942438c14ec7bd21c6cd5bd995272b3faff6"
+    },
+    {
+        "id": "7d0e7bc1c6cd5bd995272b3faff66104942438c1",
+        "short_id": "7d0e7bc1",
+        "title": "fixed a bug again",
+        "message": "fixed a bug again\n",
+        "author_name": "Bob",
+        "author_email": "bob@example.com",
+        "authored_date": "2020-12-02T10:00:00.000Z",
+        "project_id": 6,
+        "web_url": "https://gitlab.example.com/team/gems/-/commit/7d0e7bc1c6cd5bd995272b3faff66104942438c1"
+    }
+]`
+
+const responseCommitSearchPage2 = `[
+    {
+        "id": "c6cd5bd995272b3faff66104942438c17d0e7bc1",
+        "short_id": "c6cd5bd9",
+        "title": "fixed a bug for real",
+        "message": "fixed a bug for real\n",
+        "author_name": "Alice",
+        "author_email": "alice@example.com",
+        "authored_date": "2020-12-03T10:00:00.000Z",
+        "project_id": 6,
+        "web_url": "https://gitlab.example.com/team/gems/-/commit/c6cd5bd995272b3faff66104942438c17d0e7bc1"
+    }
+]`
+
+const responseProject = `{
+    "id": 6,
+    "name": "gems",
+    "description": "All the gems",
+    "web_url": "https://gitlab.example.com/team/gems"
+}`
+
+const responseUsers = `[
+    {
+        "id": 1,
+        "username": "alice",
+        "name": "Alice",
+        "avatar_url": "https://gitlab.example.com/uploads/user/avatar/1/avatar.png",
+        "web_url": "https://gitlab.example.com/alice"
+    }
+]`
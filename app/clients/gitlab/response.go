@@ -0,0 +1,32 @@
+package gitlab
+
+import "time"
+
+// CommitItem ...
+type CommitItem struct {
+	ID           string    `json:"id"`
+	ShortID      string    `json:"short_id"`
+	Title        string    `json:"title"`
+	Message      string    `json:"message"`
+	AuthorName   string    `json:"author_name"`
+	AuthorEmail  string    `json:"author_email"`
+	AuthoredDate time.Time `json:"authored_date"`
+	ProjectID    int       `json:"project_id"`
+	WebURL       string    `json:"web_url"`
+}
+
+// Project ...
+type Project struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	WebURL      string `json:"web_url"`
+}
+
+// User ...
+type User struct {
+	ID        int    `json:"id"`
+	Username  string `json:"username"`
+	AvatarURL string `json:"avatar_url"`
+	WebURL    string `json:"web_url"`
+}
@@ -0,0 +1,181 @@
+package gitlog
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Separators used in the `git log` format, so commit messages can span many lines.
+const (
+	fieldSeparator  = "\x1f"
+	recordSeparator = "\x1e"
+)
+
+// logFormat is the `git log` format for a commit: sha, author name, author email, author date and message.
+var logFormat = strings.Join([]string{"%H", "%an", "%ae", "%aI", "%B"}, fieldSeparator) + recordSeparator
+
+// CommitItem ...
+type CommitItem struct {
+	SHA         string
+	AuthorName  string
+	AuthorEmail string
+	Date        time.Time
+	Message     string
+}
+
+// Repository is a local clone of a git repository.
+type Repository struct {
+	Path string
+}
+
+// IsRepository checks if the path is a git repository.
+func IsRepository(path string) bool {
+	if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+		return true
+	}
+
+	// Bare repositories don't have a .git directory.
+	_, headErr := os.Stat(filepath.Join(path, "HEAD"))
+	_, objectsErr := os.Stat(filepath.Join(path, "objects"))
+	return headErr == nil && objectsErr == nil
+}
+
+// FindRepositories returns the repository at the path, or the
+// repositories directly inside the path if it is a directory of clones.
+func FindRepositories(path string) ([]Repository, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if IsRepository(path) {
+		return []Repository{{Path: path}}, nil
+	}
+
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := []Repository{}
+	for _, entry := range entries {
+		repoPath := filepath.Join(path, entry.Name())
+		if entry.IsDir() && IsRepository(repoPath) {
+			repos = append(repos, Repository{Path: repoPath})
+		}
+	}
+
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("no git repositories found in %s", path)
+	}
+
+	return repos, nil
+}
+
+// Name returns the name of the repository's directory.
+func (r Repository) Name() string {
+	return strings.TrimSuffix(filepath.Base(r.Path), ".git")
+}
+
+// RemoteURL returns the web URL of the `origin` remote,
+// or an empty string if the repository has no such remote.
+// Example:
+//
+//	git@github.com:TunedMystic/commits.lol.git  ->  https://github.com/TunedMystic/commits.lol
+func (r Repository) RemoteURL() string {
+	out, err := exec.Command("git", "-C", r.Path, "remote", "get-url", "origin").Output()
+	if err != nil {
+		return ""
+	}
+
+	remote := strings.TrimSpace(string(out))
+	remote = strings.TrimSuffix(remote, ".git")
+
+	if strings.HasPrefix(remote, "git@") {
+		remote = "https://" + strings.Replace(strings.TrimPrefix(remote, "git@"), ":", "/", 1)
+	}
+
+	if !strings.HasPrefix(remote, "http://") && !strings.HasPrefix(remote, "https://") {
+		return ""
+	}
+
+	return remote
+}
+
+// Log walks the history of the repository, and calls fn for every commit.
+func (r Repository) Log(fn func(item CommitItem)) error {
+	cmd := exec.Command("git", "-C", r.Path, "log", "--all", "--no-merges", "--format="+logFormat)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	stderr := bytes.Buffer{}
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error running git log: %v", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	scanner.Split(splitRecords)
+
+	for scanner.Scan() {
+		item, ok := parseRecord(scanner.Text())
+		if ok {
+			fn(item)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		cmd.Wait()
+		return fmt.Errorf("error reading git log: %v", err)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("error running git log: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// splitRecords is a bufio.SplitFunc that splits the `git log` output into commits.
+func splitRecords(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.Index(data, []byte(recordSeparator)); i >= 0 {
+		return i + len(recordSeparator), data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// parseRecord parses a single commit from the `git log` output.
+func parseRecord(record string) (CommitItem, bool) {
+	fields := strings.SplitN(strings.TrimLeft(record, "\n"), fieldSeparator, 5)
+	if len(fields) != 5 {
+		return CommitItem{}, false
+	}
+
+	date, err := time.Parse(time.RFC3339, fields[3])
+	if err != nil {
+		return CommitItem{}, false
+	}
+
+	return CommitItem{
+		SHA:         fields[0],
+		AuthorName:  fields[1],
+		AuthorEmail: fields[2],
+		Date:        date,
+		Message:     strings.TrimSpace(fields[4]),
+	}, true
+}
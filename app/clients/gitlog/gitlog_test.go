@@ -0,0 +1,122 @@
+package gitlog
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	u "github.com/tunedmystic/commits.lol/app/utils"
+)
+
+func Test_FindRepositories_single_repo(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	repoPath := testRepo(t, dir, "gems")
+
+	repos, err := FindRepositories(repoPath)
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(repos), 1)
+	u.AssertEqual(t, repos[0].Path, repoPath)
+	u.AssertEqual(t, repos[0].Name(), "gems")
+}
+
+func Test_FindRepositories_directory_of_repos(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	testRepo(t, dir, "gems")
+	testRepo(t, dir, "more-gems")
+	os.Mkdir(filepath.Join(dir, "not-a-repo"), 0755)
+
+	repos, err := FindRepositories(dir)
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(repos), 2)
+}
+
+func Test_FindRepositories_no_repos(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	_, err := FindRepositories(dir)
+	u.AssertEqual(t, err.Error(), "no git repositories found in "+dir)
+}
+
+func Test_Log(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	repo := Repository{Path: testRepo(t, dir, "gems")}
+	gitCommit(t, repo.Path, "fixed a stupid bug")
+	gitCommit(t, repo.Path, "Add feature\n\nWith a body that\nspans many lines.")
+
+	items := []CommitItem{}
+	err := repo.Log(func(item CommitItem) {
+		items = append(items, item)
+	})
+
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(items), 2)
+
+	// Newest commits come first.
+	u.AssertEqual(t, items[0].Message, "Add feature\n\nWith a body that\nspans many lines.")
+	u.AssertEqual(t, items[1].Message, "fixed a stupid bug")
+	u.AssertEqual(t, items[1].AuthorName, "Alice")
+	u.AssertEqual(t, items[1].AuthorEmail, "alice@example.com")
+	u.AssertEqual(t, len(items[1].SHA), 40)
+	u.AssertEqual(t, items[1].Date.IsZero(), false)
+}
+
+func Test_RemoteURL(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	repo := Repository{Path: testRepo(t, dir, "gems")}
+	u.AssertEqual(t, repo.RemoteURL(), "")
+
+	git(t, repo.Path, "remote", "add", "origin", "git@github.com:alice/gems.git")
+	u.AssertEqual(t, repo.RemoteURL(), "https://github.com/alice/gems")
+}
+
+func Test_parseRecord(t *testing.T) {
+	item, ok := parseRecord("\nabc123\x1fAlice\x1falice@example.com\x1f2020-12-01T10:00:00+00:00\x1ffixed a bug\n")
+	u.AssertEqual(t, ok, true)
+	u.AssertEqual(t, item.SHA, "abc123")
+	u.AssertEqual(t, item.Message, "fixed a bug")
+
+	_, ok = parseRecord("\n")
+	u.AssertEqual(t, ok, false)
+}
+
+// ------------------------------------------------------------------
+// Helpers
+// ------------------------------------------------------------------
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "gitlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, _ = filepath.EvalSymlinks(dir)
+	return dir
+}
+
+// testRepo creates an empty git repository in the directory.
+func testRepo(t *testing.T, dir, name string) string {
+	path := filepath.Join(dir, name)
+	git(t, dir, "init", "-q", path)
+	return path
+}
+
+func gitCommit(t *testing.T, path, message string) {
+	git(t, path, "-c", "user.name=Alice", "-c", "user.email=alice@example.com", "commit", "-q", "--allow-empty", "-m", message)
+}
+
+func git(t *testing.T, path string, args ...string) {
+	cmd := exec.Command("git", append([]string{"-C", path}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+}
@@ -10,23 +10,57 @@ import (
 
 // Config contains all settings for the application.
 type Config struct {
-	Environment        string `split_words:"true" default:"dev"`
-	BaseURL            string `split_words:"true" required:"true"`
-	Port               int    `split_words:"true" required:"true"`
-	DatabaseName       string `split_words:"true" required:"true"`
-	GithubAPIKey       string `split_words:"true" required:"true"`
-	GithubMaxFetch     int    `split_words:"true" default:"50"`
-	GithubCommitLength int    `split_words:"true" default:"45"`
-	LogLevel           string `split_words:"true" default:"INFO"`
-	SentryDSN          string `split_words:"true"`
-	GoatcounterUser    string `split_words:"true"`
+	Environment         string   `split_words:"true" default:"dev"`
+	BaseURL             string   `split_words:"true" required:"true"`
+	Port                int      `split_words:"true" required:"true"`
+	DatabaseName        string   `split_words:"true"`
+	DatabaseURL         string   `split_words:"true"`
+	GithubAPIKey        string   `split_words:"true" required:"true"`
+	GithubMaxFetch      int      `split_words:"true" default:"50"`
+	GithubSearchPerMin  int      `split_words:"true" default:"30"`
+	GithubSearchPerHour int      `split_words:"true" default:"5000"`
+	GithubCommitLength  int      `split_words:"true" default:"45"`
+	ValidationRulesFile string   `split_words:"true"`
+	GitlabAPIKey        string   `split_words:"true"`
+	GitlabBaseURL       string   `split_words:"true" default:"https://gitlab.com"`
+	GitlabMaxFetch      int      `split_words:"true" default:"50"`
+	GiteaInstances      []string `split_words:"true"`
+	GiteaAPIKey         string   `split_words:"true"`
+	GiteaRepoLimit      int      `split_words:"true" default:"50"`
+	GiteaCommitLimit    int      `split_words:"true" default:"50"`
+	TermYieldDays       int      `split_words:"true" default:"14"`
+	RecentDays          int      `split_words:"true" default:"14"`
+	FetchDays           int      `split_words:"true" default:"3"`
+	WorkerSize          int      `split_words:"true" default:"4"`
+	APICallBudget       int      `split_words:"true" default:"0"`
+	LanguageAllowlist   []string `split_words:"true"`
+	ScoreBias           float64  `split_words:"true" default:"0"`
+	Webhooks            []string `split_words:"true"`
+	WebhookSecret       string   `split_words:"true"`
+	WebhookRetries      int      `split_words:"true" default:"3"`
+	LogLevel            string   `split_words:"true" default:"INFO"`
+	SentryDSN           string   `split_words:"true"`
+	GoatcounterUser     string   `split_words:"true"`
+	AdminUsername       string   `split_words:"true" default:"admin"`
+	AdminPassword       string   `split_words:"true"`
 }
 
-// SourceGithub is an enum for the Github source.
-const SourceGithub int = 1
+// Enums for the sources that commits are collected from.
+const (
+	SourceGithub int = 1
+	SourceGitlab int = 2
+	SourceGitea  int = 3
+	SourceLocal  int = 4
+)
 
-// WorkerSize defines the amount of goroutines to spawn when running background tasks.
-const WorkerSize int = 4
+// DatabaseSource returns the database to connect to. The DatabaseURL takes
+// precedence over the DatabaseName, which is an SQLite database.
+func (c Config) DatabaseSource() string {
+	if c.DatabaseURL != "" {
+		return c.DatabaseURL
+	}
+	return c.DatabaseName
+}
 
 // App stores the configuration for the application.
 var App Config
@@ -46,6 +80,16 @@ func init() {
 		panic(fmt.Sprintf("config: %+v\n", err.Error()))
 	}
 
+	// The database is either a URL, or the name of an SQLite database.
+	if App.DatabaseURL == "" && App.DatabaseName == "" {
+		panic("config: required key DATABASE_URL or DATABASE_NAME missing value\n")
+	}
+
+	// The webhook payloads are always signed.
+	if len(App.Webhooks) > 0 && App.WebhookSecret == "" {
+		panic("config: required key WEBHOOK_SECRET missing value, for WEBHOOKS\n")
+	}
+
 	// Resolve basepath.
 	setBasePath(&BasePath)
 }
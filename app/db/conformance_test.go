@@ -0,0 +1,755 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tunedmystic/commits.lol/app/models"
+	u "github.com/tunedmystic/commits.lol/app/utils"
+)
+
+// The conformance tests run against every backend of the Database interface.
+// The PostgreSQL backend is only tested if TEST_DATABASE_URL is set.
+
+func Test_BlockWords(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		hateful := models.BlockWord{Text: "hateful"}
+		u.AssertEqual(t, s.CreateBlockWord(&hateful), nil)
+		u.AssertEqual(t, s.CreateBlockWord(&models.BlockWord{Text: "awful"}), nil)
+		u.AssertEqual(t, hateful.ID, 1)
+
+		// Block words are unique.
+		u.AssertEqual(t, s.CreateBlockWord(&models.BlockWord{Text: "hateful"}) != nil, true)
+
+		words, err := s.AllBlockWords()
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, len(words), 2)
+		u.AssertEqual(t, words[0].Text, "awful")
+
+		u.AssertEqual(t, s.DeleteBlockWord(hateful.ID), nil)
+		words, _ = s.AllBlockWords()
+		u.AssertEqual(t, len(words), 1)
+	})
+}
+
+func Test_PipelineRuns(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		now := time.Now().UTC()
+
+		older := models.PipelineRun{Trigger: "cron", StartedAt: now.Add(-time.Hour), FinishedAt: now.Add(-time.Hour)}
+		newer := models.PipelineRun{Trigger: "cli", StartedAt: now, FinishedAt: now, Saved: 3, APICalls: 7}
+		newer.SetTerms([]string{"bug"})
+
+		u.AssertEqual(t, s.CreatePipelineRun(&older), nil)
+		u.AssertEqual(t, s.CreatePipelineRun(&newer), nil)
+		u.AssertEqual(t, newer.ID, 2)
+
+		runs, err := s.RecentPipelineRuns(10)
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, len(runs), 2)
+		u.AssertEqual(t, runs[0].ID, newer.ID)
+		u.AssertEqual(t, runs[0].Trigger, "cli")
+		u.AssertEqual(t, runs[0].Saved, 3)
+		u.AssertEqual(t, runs[0].APICalls, 7)
+		u.AssertEqual(t, runs[0].TermList()[0], "bug")
+
+		runs, _ = s.RecentPipelineRuns(1)
+		u.AssertEqual(t, len(runs), 1)
+	})
+}
+
+func Test_WebhookDeliveries(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		now := time.Now().UTC()
+		failed := models.WebhookDelivery{URL: "http://chat", Event: "run.started", Payload: "{}", Attempts: 4, Error: "timeout", CreatedAt: now}
+		delivered := models.WebhookDelivery{URL: "http://chat", Event: "run.finished", Payload: "{}", StatusCode: 200, Attempts: 1, Delivered: true, CreatedAt: now}
+
+		u.AssertEqual(t, s.CreateWebhookDelivery(&failed), nil)
+		u.AssertEqual(t, s.CreateWebhookDelivery(&delivered), nil)
+		u.AssertEqual(t, delivered.ID, 2)
+
+		deliveries, err := s.RecentWebhookDeliveries(10)
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, len(deliveries), 2)
+		u.AssertEqual(t, deliveries[0].Event, "run.finished")
+		u.AssertEqual(t, deliveries[0].Delivered, true)
+		u.AssertEqual(t, deliveries[1].Error, "timeout")
+	})
+}
+
+func Test_SearchTermYields(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		tables.addSearchTerm("lol", 1)
+		tables.addSearchTerm("meh", 2)
+		tables.addSearchTerm("new", 3)
+
+		now := time.Now().UTC()
+		histories := models.SearchHistories{
+			{RunID: 1, Term: "lol", SearchedAt: now, APICalls: 2, Saved: 5},
+			{RunID: 2, Term: "lol", SearchedAt: now, APICalls: 3, Saved: 1},
+			{RunID: 2, Term: "meh", SearchedAt: now, APICalls: 1, Saved: 0},
+			{RunID: 0, Term: "meh", SearchedAt: now.AddDate(0, 0, -30), APICalls: 4, Saved: 4},
+		}
+		u.AssertEqual(t, s.CreateSearchHistories(histories), nil)
+
+		yields, err := s.SearchTermYields(now.AddDate(0, 0, -14))
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, len(yields), 3)
+
+		u.AssertEqual(t, yields[0].Text, "lol")
+		u.AssertEqual(t, yields[0].Searches, 2)
+		u.AssertEqual(t, yields[0].APICalls, 5)
+		u.AssertEqual(t, yields[0].Saved, 6)
+
+		// Searches older than the window are ignored.
+		u.AssertEqual(t, yields[1].Text, "meh")
+		u.AssertEqual(t, yields[1].Searches, 1)
+		u.AssertEqual(t, yields[1].Saved, 0)
+
+		// Terms that were never searched are included.
+		u.AssertEqual(t, yields[2].Text, "new")
+		u.AssertEqual(t, yields[2].Searches, 0)
+
+		u.AssertEqual(t, s.UpdateSearchTermRank(yields[2].ID, models.RankDead), nil)
+
+		terms, _ := s.AllSearchTerms()
+		u.AssertEqual(t, terms[2].Rank, models.RankDead)
+
+		yields, err = s.RandomSearchTerms()
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, len(yields), 3)
+	})
+}
+
+func Test_UpdateSearchTermStates(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		tables.addSearchTerm("lol", 1)
+		tables.addSearchTerm("meh", 2)
+
+		now := time.Now().UTC().Truncate(time.Microsecond) // Postgres keeps microseconds.
+		newest := time.Date(2020, 12, 10, 12, 0, 0, 0, time.UTC)
+
+		states := models.SearchTermStates{
+			{Term: "lol", LastSearchedAt: now.Add(-time.Hour), Watermark: sql.NullTime{Time: newest, Valid: true}},
+		}
+		u.AssertEqual(t, s.UpdateSearchTermStates(states), nil)
+
+		// A later search with older commits doesn't move the watermark back.
+		states = models.SearchTermStates{
+			{Term: "lol", LastSearchedAt: now, Watermark: sql.NullTime{Time: newest.AddDate(0, 0, -1), Valid: true}},
+			{Term: "meh", LastSearchedAt: now},
+		}
+		u.AssertEqual(t, s.UpdateSearchTermStates(states), nil)
+
+		yields, err := s.SearchTermYields(now.AddDate(0, 0, -14))
+		u.AssertEqual(t, err, nil)
+
+		u.AssertEqual(t, yields[0].Text, "lol")
+		u.AssertEqual(t, yields[0].LastSearchedAt.Time.Equal(now), true)
+		u.AssertEqual(t, yields[0].Watermark.Time.Equal(newest), true)
+		u.AssertEqual(t, yields[1].Text, "meh")
+		u.AssertEqual(t, yields[1].LastSearchedAt.Valid, true)
+		u.AssertEqual(t, yields[1].Watermark.Valid, false)
+
+		// A newer commit moves the watermark forward.
+		states = models.SearchTermStates{
+			{Term: "meh", LastSearchedAt: now, Watermark: sql.NullTime{Time: newest, Valid: true}},
+		}
+		u.AssertEqual(t, s.UpdateSearchTermStates(states), nil)
+
+		yields, _ = s.SearchTermYields(now.AddDate(0, 0, -14))
+		u.AssertEqual(t, yields[1].Watermark.Time.Equal(newest), true)
+	})
+}
+
+func Test_SaveCommitBatch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		commits := models.GitCommits{
+			testCommit("alice", "gems", "fixed a bug"),
+			testCommit("alice", "rubies", "fixed a bug"), // same author and message
+			testCommit("bob", "gems", "fixed a bug"),
+		}
+		commits[2].Lang = "en"
+
+		created, err := s.SaveCommitBatch(commits)
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, len(created), 2)
+		u.AssertEqual(t, created[0].ID > 0, true)
+		u.AssertEqual(t, created[0].AuthorID, created[0].Author.ID)
+		u.AssertEqual(t, created[0].RepoID, created[0].Repo.ID)
+		u.AssertEqual(t, created[1].Author.Username, "bob")
+
+		saved, _ := s.GetCommit(created[1].ID)
+		u.AssertEqual(t, saved.Lang, "en")
+
+		// Saving the batch again creates nothing.
+		created, err = s.SaveCommitBatch(commits)
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, len(created), 0)
+
+		u.AssertEqual(t, tables.count("git_user"), 2)
+		u.AssertEqual(t, tables.count("git_repo"), 3)
+		u.AssertEqual(t, tables.count("git_commit"), 2)
+	})
+}
+
+func Test_SaveCommitBatch_long_values(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		long := strings.Repeat("a", 1000)
+
+		commits := models.GitCommits{
+			testCommit("alice", "gems", "fixed a bug"),
+			testCommit("bob"+long, "gems"+long, "fixed a bug"+long),
+		}
+		commits[1].Author.AvatarURL = "https://avatars.example.com/" + long
+		commits[1].Repo.Description = long
+		commits[1].SHA = strings.Repeat("f", 64) // a SHA-256 object
+
+		// A long value from a source doesn't fail the batch.
+		created, err := s.SaveCommitBatch(commits)
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, len(created), 2)
+
+		saved, _ := s.GetCommit(created[1].ID)
+		u.AssertEqual(t, saved.Message, "fixed a bug"+long)
+		u.AssertEqual(t, saved.Repo.Description, long)
+	})
+}
+
+func Test_UpdateCommit(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		created, _ := s.SaveCommitBatch(models.GitCommits{
+			testCommit("alice", "gems", "fixed a bug"),
+			testCommit("bob", "gems", "fixed another bug"),
+		})
+
+		commit := created[0]
+		commit.Group = "poop"
+		commit.Valid = false
+		u.AssertEqual(t, s.UpdateCommit(&commit), nil)
+
+		all, _ := s.AllCommits()
+		u.AssertEqual(t, all[0].Group, "poop")
+		u.AssertEqual(t, all[0].Valid, false)
+		u.AssertEqual(t, all[1].Valid, true)
+
+		// The url is still unique.
+		commit.URL = created[1].URL
+		u.AssertEqual(t, s.UpdateCommit(&commit) != nil, true)
+	})
+}
+
+func Test_GetCommit(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		commit := testCommit("alice", "gems", "fixed a bug")
+		commit.Body = "it was a feature\nnot anymore"
+		created, _ := s.SaveCommitBatch(models.GitCommits{commit})
+
+		found, err := s.GetCommit(created[0].ID)
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, found.Message, "fixed a bug")
+		u.AssertEqual(t, found.Body, "it was a feature\nnot anymore")
+		u.AssertEqual(t, found.Author.Username, "alice")
+		u.AssertEqual(t, found.Repo.Name, "gems")
+
+		// Commits that are not valid are not found.
+		found.Valid = false
+		u.AssertEqual(t, s.UpdateCommit(found), nil)
+
+		found, err = s.GetCommit(created[0].ID)
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, found == nil, true)
+	})
+}
+
+func Test_RecentCommitsByGroup(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		now := time.Now().UTC()
+		commits := models.GitCommits{}
+		add := func(group, lang, message string, date time.Time, valid bool) {
+			c := testCommit("alice", "gems", message)
+			c.Group, c.Lang, c.Date, c.Valid = group, lang, date, valid
+			commits = append(commits, c)
+		}
+
+		// The poop commits are recent, but only 5 of the holy commits are.
+		for i := 0; i < 50; i++ {
+			add("poop", "en", fmt.Sprintf("poop %d", i), now.Add(-time.Duration(i)*time.Hour), true)
+		}
+		for i := 0; i < 3; i++ {
+			add("poop", "es", fmt.Sprintf("poop es %d", i), now.AddDate(0, 0, -i), true)
+		}
+		for i := 0; i < 5; i++ {
+			add("holy", "en", fmt.Sprintf("holy recent %d", i), now.Add(-time.Duration(i)*time.Hour), true)
+			add("holy", "en", fmt.Sprintf("holy invalid %d", i), now.Add(-time.Duration(i)*time.Hour), false)
+		}
+		for i := 0; i < 40; i++ {
+			add("holy", "en", fmt.Sprintf("holy old %d", i), now.AddDate(-i, 0, -100), true)
+		}
+		s.SaveCommitBatch(commits)
+
+		// countByPrefix counts the distinct commits, by the prefix of their message.
+		countByPrefix := func(commits models.GitCommits) map[string]int {
+			counts := map[string]int{}
+			seen := map[int]bool{}
+			for _, c := range commits {
+				if !seen[c.ID] {
+					seen[c.ID] = true
+					counts[c.Message[:strings.LastIndex(c.Message, " ")]]++
+				}
+			}
+			return counts
+		}
+
+		// A full batch of distinct commits, from the group.
+		recent, err := s.RecentCommitsByGroup("poop", "")
+		u.AssertEqual(t, err, nil)
+		counts := countByPrefix(recent)
+		u.AssertEqual(t, len(recent), 33)
+		u.AssertEqual(t, counts["poop"]+counts["poop es"], 33)
+		u.AssertEqual(t, recent[0].Author.URL, "https://github.com/alice")
+
+		// The recent commits are filled up with older commits.
+		recent, _ = s.RecentCommitsByGroup("holy", "")
+		counts = countByPrefix(recent)
+		u.AssertEqual(t, len(recent), 33)
+		u.AssertEqual(t, counts["holy recent"], 5)
+		u.AssertEqual(t, counts["holy old"], 28)
+
+		// Every commit is returned, when there are fewer than a batch.
+		recent, _ = s.RecentCommitsByGroup("poop", "es")
+		u.AssertEqual(t, len(recent), 3)
+		u.AssertEqual(t, countByPrefix(recent)["poop es"], 3)
+
+		// The invalid commits are never returned.
+		recent, _ = s.RecentCommitsByGroup("", "")
+		u.AssertEqual(t, len(recent), 33)
+		u.AssertEqual(t, countByPrefix(recent)["holy invalid"], 0)
+
+		recent, err = s.RecentCommitsByGroup("nope", "")
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, len(recent), 0)
+	})
+}
+
+func Test_SearchCommits(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		now := time.Now().UTC()
+		commits := models.GitCommits{
+			testCommit("alice", "gems", "fixed the login bug"),
+			testCommit("bob", "gems", "Login page is broken"),
+			testCommit("carol", "gems", "fixed a typo"),
+			testCommit("dave", "gems", "login works now lol"),
+		}
+		for i := range commits {
+			commits[i].Date = now.Add(time.Duration(i) * time.Minute)
+		}
+		commits[1].Group = "poop"
+		commits[1].Lang = "en"
+		commits[3].Valid = false
+		s.SaveCommitBatch(commits)
+
+		// The commits with every word are found, newest first.
+		results, err := s.SearchCommits("login", models.SearchFilters{}, 1)
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, len(results.Commits), 2)
+		u.AssertEqual(t, results.Commits[0].Message, "Login page is broken")
+		u.AssertEqual(t, results.Commits[0].Author.Username, "bob")
+		u.AssertEqual(t, results.Commits[0].Repo.Name, "gems")
+		u.AssertEqual(t, results.Commits[1].Message, "fixed the login bug")
+		u.AssertEqual(t, results.HasNext, false)
+
+		results, _ = s.SearchCommits("FIXED login", models.SearchFilters{}, 1)
+		u.AssertEqual(t, len(results.Commits), 1)
+
+		results, _ = s.SearchCommits("login", models.SearchFilters{Group: "poop", Lang: "en"}, 1)
+		u.AssertEqual(t, len(results.Commits), 1)
+		u.AssertEqual(t, results.Commits[0].Author.Username, "bob")
+
+		results, _ = s.SearchCommits("login", models.SearchFilters{Lang: "es"}, 1)
+		u.AssertEqual(t, len(results.Commits), 0)
+
+		// Queries without words, or with the syntax of the search index, find nothing.
+		results, err = s.SearchCommits(`  "" `, models.SearchFilters{}, 1)
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, len(results.Commits), 0)
+
+		results, err = s.SearchCommits(`login" OR "typo*`, models.SearchFilters{}, 1)
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, len(results.Commits), 0)
+
+		// The search follows the updated messages.
+		results, _ = s.SearchCommits("typo", models.SearchFilters{}, 1)
+		typo := results.Commits[0]
+		typo.Message = "fixed a typo in the login form"
+		u.AssertEqual(t, s.UpdateCommit(&typo), nil)
+
+		results, _ = s.SearchCommits("login form", models.SearchFilters{}, 1)
+		u.AssertEqual(t, len(results.Commits), 1)
+	})
+}
+
+func Test_SearchCommits_pages(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		commits := models.GitCommits{}
+		for i := 0; i < models.SearchPageSize+5; i++ {
+			commits = append(commits, testCommit(fmt.Sprintf("user%d", i), "gems", fmt.Sprintf("bug number %d", i)))
+		}
+		s.SaveCommitBatch(commits)
+
+		results, err := s.SearchCommits("bug", models.SearchFilters{}, 1)
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, len(results.Commits), models.SearchPageSize)
+		u.AssertEqual(t, results.HasNext, true)
+
+		results, _ = s.SearchCommits("bug", models.SearchFilters{}, 2)
+		u.AssertEqual(t, len(results.Commits), 5)
+		u.AssertEqual(t, results.Page, 2)
+		u.AssertEqual(t, results.HasNext, false)
+	})
+}
+
+func Test_GetOrCreate(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		commit := testCommit("alice", "gems", "fixed a bug")
+
+		u.AssertEqual(t, s.GetOrCreateUser(&commit.Author), nil)
+		u.AssertEqual(t, s.GetOrCreateRepo(&commit.Repo), nil)
+		u.AssertEqual(t, commit.Author.ID, 1)
+		u.AssertEqual(t, commit.Repo.ID, 1)
+
+		commit.AuthorID = commit.Author.ID
+		commit.RepoID = commit.Repo.ID
+
+		created, err := s.GetOrCreateCommit(&commit)
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, created, true)
+		u.AssertEqual(t, commit.ID, 1)
+
+		// Getting them again returns the same rows.
+		other := testCommit("alice", "gems", "fixed a bug")
+		u.AssertEqual(t, s.GetOrCreateUser(&other.Author), nil)
+		u.AssertEqual(t, s.GetOrCreateRepo(&other.Repo), nil)
+		u.AssertEqual(t, other.Author.ID, 1)
+		u.AssertEqual(t, other.Repo.ID, 1)
+
+		other.AuthorID = other.Author.ID
+		created, err = s.GetOrCreateCommit(&other)
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, created, false)
+		u.AssertEqual(t, other.ID, 1)
+	})
+}
+
+func Test_GetOrCreate_concurrent_writers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		// Concurrent workers get the same rows, instead of creating duplicates.
+		results := make(chan bool)
+		for i := 0; i < 8; i++ {
+			go func() {
+				commit := testCommit("alice", "gems", "fixed a bug")
+				u.AssertEqual(t, s.GetOrCreateUser(&commit.Author), nil)
+				u.AssertEqual(t, s.GetOrCreateRepo(&commit.Repo), nil)
+
+				commit.AuthorID = commit.Author.ID
+				commit.RepoID = commit.Repo.ID
+
+				created, err := s.GetOrCreateCommit(&commit)
+				u.AssertEqual(t, err, nil)
+				results <- created
+			}()
+		}
+
+		created := 0
+		for i := 0; i < 8; i++ {
+			if <-results {
+				created++
+			}
+		}
+		u.AssertEqual(t, created, 1)
+
+		u.AssertEqual(t, tables.count("git_user"), 1)
+		u.AssertEqual(t, tables.count("git_repo"), 1)
+		u.AssertEqual(t, tables.count("git_commit"), 1)
+
+		// The same author and message is the same commit, even with another url.
+		commit := testCommit("alice", "rubies", "fixed a bug")
+		s.GetOrCreateUser(&commit.Author)
+		s.GetOrCreateRepo(&commit.Repo)
+		commit.AuthorID = commit.Author.ID
+		commit.RepoID = commit.Repo.ID
+
+		ok, err := s.GetOrCreateCommit(&commit)
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, ok, false)
+		u.AssertEqual(t, commit.ID, 1)
+	})
+}
+
+func Test_SaveCommitBatch_concurrent_writers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		// Concurrent batches wait for each other, instead of failing with "database is locked".
+		errs := make(chan error)
+		for i := 0; i < 8; i++ {
+			go func(i int) {
+				batch := models.GitCommits{}
+				for j := 0; j < 20; j++ {
+					batch = append(batch, testCommit(fmt.Sprintf("user%d", j), "gems", fmt.Sprintf("commit %d-%d", i, j)))
+				}
+				_, err := s.SaveCommitBatch(batch)
+				errs <- err
+			}(i)
+		}
+
+		for i := 0; i < 8; i++ {
+			u.AssertEqual(t, <-errs, nil)
+		}
+
+		u.AssertEqual(t, tables.count("git_commit"), 160)
+	})
+}
+
+func Test_SaveCommitBatch_concurrent_duplicates(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		canonical := testCommit("alice", "gems", "oops forgot to add the file")
+		canonical.SetMessageHash()
+		s.SaveCommitBatch(models.GitCommits{canonical})
+
+		// Concurrent batches save the same near-duplicate once, even though
+		// the unique index doesn't cover the near-duplicates.
+		errs := make(chan error)
+		for i := 0; i < 8; i++ {
+			go func(i int) {
+				duplicate := testCommit("bob", "gems", "Oops, forgot to add the file!!")
+				duplicate.URL += fmt.Sprintf("-%d", i)
+				duplicate.SetMessageHash()
+				_, err := s.SaveCommitBatch(models.GitCommits{duplicate})
+				errs <- err
+			}(i)
+		}
+
+		for i := 0; i < 8; i++ {
+			u.AssertEqual(t, <-errs, nil)
+		}
+
+		u.AssertEqual(t, tables.count("git_commit"), 2)
+	})
+}
+
+func Test_SaveCommitBatch_near_duplicates(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		commits := models.GitCommits{
+			testCommit("alice", "gems", "oops forgot to add the file"),
+			testCommit("bob", "gems", "Oops, forgot to add the file!!"), // same normalized message
+			testCommit("carol", "gems", "oops forgot to add the files"), // similar message
+			testCommit("dave", "gems", "i hate javascript so much"),
+		}
+		for i := range commits {
+			commits[i].SetMessageHash()
+		}
+
+		created, err := s.SaveCommitBatch(commits)
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, len(created), 4)
+
+		canonicalID := int64(created[0].ID)
+		u.AssertEqual(t, created[0].CanonicalID.Valid, false)
+		u.AssertEqual(t, created[1].CanonicalID.Int64, canonicalID)
+		u.AssertEqual(t, created[2].CanonicalID.Int64, canonicalID)
+		u.AssertEqual(t, created[3].CanonicalID.Valid, false)
+
+		// The canonical id is saved.
+		saved, _ := s.GetCommit(created[2].ID)
+		u.AssertEqual(t, saved.CanonicalID.Int64, canonicalID)
+	})
+}
+
+func Test_UpdateCommit_near_duplicates(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		commit := testCommit("alice", "gems", "i hate javascript so much")
+		commit.SetMessageHash()
+		created, _ := s.SaveCommitBatch(models.GitCommits{commit})
+
+		// The bands of the commit follow its new message.
+		commit = created[0]
+		commit.Message = "oops forgot to add the file"
+		commit.SetMessageHash()
+		u.AssertEqual(t, s.UpdateCommit(&commit), nil)
+
+		similar := testCommit("bob", "gems", "oops forgot to add the files")
+		similar.SetMessageHash()
+		other := testCommit("carol", "gems", "i hate javascript so much!!!")
+		other.SetMessageHash()
+
+		created, err := s.SaveCommitBatch(models.GitCommits{similar, other})
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, created[0].CanonicalID.Int64, int64(commit.ID))
+		u.AssertEqual(t, created[1].CanonicalID.Valid, false)
+	})
+}
+
+func Test_HumorModels(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		model, err := s.LatestHumorModel()
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, model == nil, true)
+
+		created, _ := s.SaveCommitBatch(models.GitCommits{
+			testCommit("alice", "gems", "why does this even work"),
+			testCommit("bob", "gems", "update dependencies"),
+		})
+
+		u.AssertEqual(t, s.LabelCommit(created[0].ID, false), nil)
+		u.AssertEqual(t, s.LabelCommit(created[0].ID, true), nil) // replaces the label
+		u.AssertEqual(t, s.LabelCommit(created[1].ID, false), nil)
+
+		labels, err := s.CommitLabels()
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, len(labels), 2)
+		u.AssertEqual(t, labels[0].Funny, true)
+		u.AssertEqual(t, labels[0].Message, "why does this even work")
+		u.AssertEqual(t, labels[1].Funny, false)
+
+		older := models.HumorModel{TrainedAt: time.Now().UTC(), Data: "{}"}
+		newer := models.HumorModel{TrainedAt: time.Now().UTC(), Examples: 2, Data: "{}"}
+		u.AssertEqual(t, s.SaveHumorModel(&older), nil)
+		u.AssertEqual(t, s.SaveHumorModel(&newer), nil)
+
+		model, err = s.LatestHumorModel()
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, model.ID, newer.ID)
+		u.AssertEqual(t, model.Examples, 2)
+	})
+}
+
+func Test_DedupeCommits(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Database, tables testTables) {
+		// Commits saved without hashes.
+		commits := models.GitCommits{
+			testCommit("alice", "gems", "why does this even work"),
+			testCommit("bob", "gems", "why does this even work lol"),
+			testCommit("carol", "gems", "Why does this even work?"),
+			testCommit("dave", "gems", "i hate javascript so much"),
+		}
+		created, _ := s.SaveCommitBatch(commits)
+		u.AssertEqual(t, len(created), 4)
+
+		duplicates, err := s.DedupeCommits()
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, duplicates, 2)
+
+		// Running it again gives the same result.
+		duplicates, _ = s.DedupeCommits()
+		u.AssertEqual(t, duplicates, 2)
+
+		all, _ := s.AllCommits()
+		u.AssertEqual(t, all[1].Author.Username, "bob")
+		u.AssertEqual(t, all[0].MessageHash, u.MessageHash("why does this even work"))
+		u.AssertEqual(t, all[0].CanonicalID.Valid, false)
+		u.AssertEqual(t, all[1].CanonicalID.Int64, int64(all[0].ID))
+		u.AssertEqual(t, all[2].CanonicalID.Int64, int64(all[0].ID))
+		u.AssertEqual(t, all[3].CanonicalID.Valid, false)
+	})
+}
+
+// ------------------------------------------------------------------
+// Helpers
+// ------------------------------------------------------------------
+
+// forEachBackend runs the test once for every backend, each with a new, empty database.
+// The test gets the database, and its tables for the rows the Database interface can't reach.
+func forEachBackend(t *testing.T, test func(t *testing.T, s Database, tables testTables)) {
+	t.Run("sqlite", func(t *testing.T) {
+		s := testDB(t)
+		defer s.Close()
+		clearDefaults(s.DB)
+		test(t, &s, sqlTables{s.DB})
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		url := os.Getenv("TEST_DATABASE_URL")
+		if url == "" {
+			t.Skip("TEST_DATABASE_URL is not set")
+		}
+
+		s := testPostgresDB(t, url)
+		defer s.Close()
+		clearDefaults(s.DB)
+		test(t, &s, sqlTables{s.DB})
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		s := NewMemoryDB()
+		test(t, s, memoryTables{s})
+	})
+}
+
+// clearDefaults deletes the default terms of a new database, so the tests start empty.
+func clearDefaults(raw *sqlx.DB) {
+	raw.MustExec(`DELETE FROM config_badword;`)
+	raw.MustExec(`DELETE FROM config_searchterm;`)
+	raw.MustExec(`DELETE FROM config_groupterm;`)
+}
+
+// testTables adds and counts the rows that the Database interface can't reach.
+type testTables interface {
+	addSearchTerm(text string, rank int)
+	count(table string) int
+}
+
+// sqlTables are the tables of an SQL backend, through a raw connection.
+type sqlTables struct {
+	raw *sqlx.DB
+}
+
+func (s sqlTables) addSearchTerm(text string, rank int) {
+	s.raw.MustExec(s.raw.Rebind(`INSERT INTO config_searchterm (text, rank) VALUES (?, ?);`), text, rank)
+}
+
+func (s sqlTables) count(table string) int {
+	count := 0
+	s.raw.Get(&count, `SELECT COUNT(*) FROM `+table+`;`)
+	return count
+}
+
+// memoryTables are the tables of a MemoryDB.
+type memoryTables struct {
+	m *MemoryDB
+}
+
+func (m memoryTables) addSearchTerm(text string, rank int) {
+	m.m.AddSearchTerm(text, rank)
+}
+
+func (m memoryTables) count(table string) int {
+	m.m.mu.Lock()
+	defer m.m.mu.Unlock()
+
+	switch table {
+	case "git_user":
+		return len(m.m.users)
+	case "git_repo":
+		return len(m.m.repos)
+	case "git_commit":
+		return len(m.m.commits)
+	}
+	panic("unknown table " + table)
+}
+
+// testCommit creates a valid commit, by the user in the repo.
+func testCommit(username, repo, message string) models.GitCommit {
+	return models.GitCommit{
+		Source:  1,
+		Message: message,
+		URL:     fmt.Sprintf("https://github.com/%s/%s/commit/%x", username, repo, message),
+		Date:    time.Now().UTC(),
+		Valid:   true,
+		Author:  models.GitUser{Source: 1, Username: username, URL: "https://github.com/" + username},
+		Repo:    models.GitRepo{Source: 1, Name: repo, URL: "https://github.com/" + username + "/" + repo},
+	}
+}
@@ -1,19 +1,46 @@
 package db
 
-import "github.com/tunedmystic/commits.lol/app/models"
+import (
+	"time"
+
+	"github.com/tunedmystic/commits.lol/app/models"
+)
 
 // Database defines the behavior for the application's database.
 type Database interface {
 	AllBadWords() (models.BadWords, error)
+	AllBlockWords() (models.BlockWords, error)
+	CreateBlockWord(word *models.BlockWord) error
+	DeleteBlockWord(ID int) error
 	AllGroupTerms() (models.GroupTerms, error)
-	RandomSearchTerms() (models.SearchTerms, error)
+	AllSearchTerms() (models.SearchTerms, error)
+	RandomSearchTerms() (models.SearchTermYields, error)
+	SearchTermYields(since time.Time) (models.SearchTermYields, error)
+	UpdateSearchTermRank(ID, rank int) error
 
 	AllCommits() (models.GitCommits, error)
 	UpdateCommit(commit *models.GitCommit) error
-	RecentCommitsByGroup(group string) (models.GitCommits, error)
+	RecentCommitsByGroup(group, lang string) (models.GitCommits, error)
+	GetCommit(ID int) (*models.GitCommit, error)
+	SearchCommits(query string, filters models.SearchFilters, page int) (models.SearchResults, error)
 	GetOrCreateUser(user *models.GitUser) error
 	GetOrCreateRepo(repo *models.GitRepo) error
-	GetOrCreateCommit(commit *models.GitCommit) error
+	GetOrCreateCommit(commit *models.GitCommit) (bool, error)
+	SaveCommitBatch(commits models.GitCommits) (models.GitCommits, error)
+	DedupeCommits() (int, error)
+
+	CreatePipelineRun(run *models.PipelineRun) error
+	RecentPipelineRuns(limit int) (models.PipelineRuns, error)
+	CreateSearchHistories(histories models.SearchHistories) error
+	UpdateSearchTermStates(states models.SearchTermStates) error
+
+	CreateWebhookDelivery(delivery *models.WebhookDelivery) error
+	RecentWebhookDeliveries(limit int) (models.WebhookDeliveries, error)
+
+	LabelCommit(commitID int, funny bool) error
+	CommitLabels() (models.CommitLabels, error)
+	SaveHumorModel(model *models.HumorModel) error
+	LatestHumorModel() (*models.HumorModel, error)
 
 	Close()
 }
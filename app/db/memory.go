@@ -0,0 +1,812 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tunedmystic/commits.lol/app/config"
+	"github.com/tunedmystic/commits.lol/app/models"
+	"github.com/tunedmystic/commits.lol/app/utils"
+)
+
+// MemoryDB is an in-memory type that implements the Database interface.
+// Used for testing. Unlike the MockDB, it keeps its rows, and behaves like the
+// SqliteDB: users, repos and commits are unique, updates are saved, and the
+// homepage and the search filter and sample the commits the same way.
+//
+// It starts empty, without the default terms. The terms that can't be created
+// through the Database interface are added with AddBadWord, AddGroupTerm and AddSearchTerm.
+type MemoryDB struct {
+	mu sync.Mutex
+
+	badWords     models.BadWords
+	blockWords   models.BlockWords
+	blockWordID  int
+	groupTerms   models.GroupTerms
+	searchTerms  models.SearchTerms
+	searchStates map[string]models.SearchTermState
+
+	// The rows of these tables are never deleted, so a row's ID is its index plus one.
+	users     []models.GitUser
+	repos     []models.GitRepo
+	commits   models.GitCommits
+	runs      models.PipelineRuns
+	histories models.SearchHistories
+
+	deliveries  models.WebhookDeliveries
+	labels      map[int]models.CommitLabel
+	humorModels []models.HumorModel
+
+	// The IDs of the commits, by the bands of their simhash, like the git_commit_band table.
+	bands map[int]map[int]bool
+}
+
+// NewMemoryDB returns a new, empty *MemoryDB type.
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{
+		searchStates: map[string]models.SearchTermState{},
+		labels:       map[int]models.CommitLabel{},
+		bands:        map[int]map[int]bool{},
+	}
+}
+
+// Close does nothing, as there's no connection to close.
+func (m *MemoryDB) Close() {}
+
+// AddBadWord inserts a new BadWord row.
+func (m *MemoryDB) AddBadWord(text string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.badWords = append(m.badWords, models.BadWord{ID: len(m.badWords) + 1, Text: text})
+}
+
+// AddGroupTerm inserts a new GroupTerm row.
+func (m *MemoryDB) AddGroupTerm(text, group string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.groupTerms = append(m.groupTerms, models.GroupTerm{ID: len(m.groupTerms) + 1, Text: text, Group: group})
+}
+
+// AddSearchTerm inserts a new SearchTerm row.
+func (m *MemoryDB) AddSearchTerm(text string, rank int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.searchTerms = append(m.searchTerms, models.SearchTerm{ID: len(m.searchTerms) + 1, Text: text, Rank: rank})
+}
+
+// errUnique is the error of a row that breaks a unique constraint, as SQLite words it.
+func errUnique(column string) error {
+	return fmt.Errorf("UNIQUE constraint failed: %s", column)
+}
+
+// ------------------------------------------------------------------
+// Methods to modify config-related tables (BadWWord, BlockWord, GroupTerm, SearchTerm)
+
+// AllBadWords returns all the bad words.
+func (m *MemoryDB) AllBadWords() (models.BadWords, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append(models.BadWords{}, m.badWords...), nil
+}
+
+// AllBlockWords returns all the block words.
+func (m *MemoryDB) AllBlockWords() (models.BlockWords, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	words := append(models.BlockWords{}, m.blockWords...)
+	sort.SliceStable(words, func(i, j int) bool {
+		return words[i].Text < words[j].Text
+	})
+
+	return words, nil
+}
+
+// CreateBlockWord inserts a new BlockWord row.
+func (m *MemoryDB) CreateBlockWord(word *models.BlockWord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.blockWords {
+		if existing.Text == word.Text {
+			return fmt.Errorf("error inserting block word: %v", errUnique("config_blockword.text"))
+		}
+	}
+
+	m.blockWordID++
+	word.ID = m.blockWordID
+	m.blockWords = append(m.blockWords, *word)
+	return nil
+}
+
+// DeleteBlockWord deletes the BlockWord with the given ID.
+func (m *MemoryDB) DeleteBlockWord(ID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, word := range m.blockWords {
+		if word.ID == ID {
+			m.blockWords = append(m.blockWords[:i], m.blockWords[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+// AllGroupTerms returns all the group terms.
+func (m *MemoryDB) AllGroupTerms() (models.GroupTerms, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append(models.GroupTerms{}, m.groupTerms...), nil
+}
+
+// AllSearchTerms returns all the search terms.
+func (m *MemoryDB) AllSearchTerms() (models.SearchTerms, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.sortedSearchTerms(), nil
+}
+
+// sortedSearchTerms returns the search terms, by rank and ID.
+func (m *MemoryDB) sortedSearchTerms() models.SearchTerms {
+	terms := append(models.SearchTerms{}, m.searchTerms...)
+	sort.SliceStable(terms, func(i, j int) bool {
+		if terms[i].Rank != terms[j].Rank {
+			return terms[i].Rank < terms[j].Rank
+		}
+		return terms[i].ID < terms[j].ID
+	})
+	return terms
+}
+
+// RandomSearchTerms returns a list of randomly selected terms.
+// The terms that went the longest without being searched are picked first,
+// and then sampled by their recent yield.
+func (m *MemoryDB) RandomSearchTerms() (models.SearchTermYields, error) {
+	amount := 18
+	since := time.Now().UTC().AddDate(0, 0, -config.App.TermYieldDays)
+
+	yields, err := m.SearchTermYields(since)
+	if err != nil {
+		return nil, fmt.Errorf("db:RandomSearchTerms: %v", err)
+	}
+
+	return yields.Stalest(amount*2).WeightedSample(amount, rand.Float64), nil
+}
+
+// SearchTermYields returns every search term, with its search results since the given time.
+func (m *MemoryDB) SearchTermYields(since time.Time) (models.SearchTermYields, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	yields := models.SearchTermYields{}
+
+	for _, term := range m.sortedSearchTerms() {
+		yield := models.SearchTermYield{SearchTerm: term}
+
+		for _, history := range m.histories {
+			if history.Term == term.Text && history.SearchedAt.After(since) {
+				yield.Searches++
+				yield.APICalls += history.APICalls
+				yield.Saved += history.Saved
+			}
+		}
+
+		if state, ok := m.searchStates[term.Text]; ok {
+			yield.LastSearchedAt = sql.NullTime{Time: state.LastSearchedAt, Valid: true}
+			yield.Watermark = state.Watermark
+		}
+
+		yields = append(yields, yield)
+	}
+
+	return yields, nil
+}
+
+// UpdateSearchTermRank ...
+func (m *MemoryDB) UpdateSearchTermRank(ID, rank int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.searchTerms {
+		if m.searchTerms[i].ID == ID {
+			m.searchTerms[i].Rank = rank
+		}
+	}
+
+	return nil
+}
+
+// ------------------------------------------------------------------
+// Methods to modify git-related tables (GitCommit, GitRepo, GitUser)
+
+// AllCommits returns all the commits, with their Author.
+func (m *MemoryDB) AllCommits() (models.GitCommits, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	commits := make(models.GitCommits, 0, len(m.commits))
+	for _, commit := range m.commits {
+		if commit, ok := m.join(commit, false); ok {
+			commits = append(commits, commit)
+		}
+	}
+
+	return commits, nil
+}
+
+// join returns the commit with its Author, and with its Repo if withRepo is true.
+// Returns false if they don't exist, like an inner join.
+func (m *MemoryDB) join(commit models.GitCommit, withRepo bool) (models.GitCommit, bool) {
+	if commit.AuthorID < 1 || commit.AuthorID > len(m.users) {
+		return commit, false
+	}
+	commit.Author = m.users[commit.AuthorID-1]
+	commit.Repo = models.GitRepo{}
+
+	if withRepo {
+		if commit.RepoID < 1 || commit.RepoID > len(m.repos) {
+			return commit, false
+		}
+		commit.Repo = m.repos[commit.RepoID-1]
+	}
+
+	return commit, true
+}
+
+// UpdateCommit ...
+func (m *MemoryDB) UpdateCommit(commit *models.GitCommit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if commit.ID < 1 || commit.ID > len(m.commits) {
+		return nil
+	}
+
+	if err := m.commitConflict(*commit); err != nil {
+		return fmt.Errorf("error inserting commit: %v", err)
+	}
+
+	m.saveBands(commit.ID, m.commits[commit.ID-1].Simhash, commit.Simhash)
+	m.commits[commit.ID-1] = stripCommit(*commit)
+	return nil
+}
+
+// commitConflict returns an error if another commit has the url, or if another canonical
+// commit has the author and message of the commit, when it's canonical too.
+func (m *MemoryDB) commitConflict(commit models.GitCommit) error {
+	for _, existing := range m.commits {
+		if existing.ID == commit.ID {
+			continue
+		}
+		if existing.URL == commit.URL {
+			return errUnique("git_commit.url")
+		}
+		if existing.AuthorID == commit.AuthorID && existing.Message == commit.Message &&
+			!existing.CanonicalID.Valid && !commit.CanonicalID.Valid {
+			return errUnique("git_commit.author_id, git_commit.message")
+		}
+	}
+	return nil
+}
+
+// stripCommit returns the commit without its Author and Repo, as they're saved in their own tables.
+func stripCommit(commit models.GitCommit) models.GitCommit {
+	commit.Author = models.GitUser{}
+	commit.Repo = models.GitRepo{}
+	return commit
+}
+
+// RecentCommitsByGroup returns a batch of random commits, from the last RecentDays,
+// or from older commits when the recent ones are too few.
+// The commits are filtered by group and language, if they're not empty.
+func (m *MemoryDB) RecentCommitsByGroup(group, lang string) (models.GitCommits, error) {
+	return recentCommitsByGroup(func(n int) (models.GitCommits, error) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		return m.sampleCommits(group, lang, n), nil
+	})
+}
+
+// sampleCommits returns n random commits, or every commit if there are fewer than n.
+// The commits are shuffled in each window of the sample, from the newest window,
+// until there are enough of them.
+func (m *MemoryDB) sampleCommits(group, lang string, n int) models.GitCommits {
+	commits := make(models.GitCommits, 0, n)
+	seen := make(map[int]bool, n)
+
+	for _, since := range sampleWindowStarts(time.Now().UTC()) {
+		window := models.GitCommits{}
+		for _, commit := range m.commits {
+			if seen[commit.ID] || !commit.Date.After(since) || !commit.Valid || commit.CanonicalID.Valid {
+				continue
+			}
+			if (group != "" && commit.Group != group) || (lang != "" && commit.Lang != lang) {
+				continue
+			}
+			if commit, ok := m.join(commit, false); ok {
+				window = append(window, commit)
+			}
+		}
+
+		rand.Shuffle(len(window), func(i, j int) {
+			window[i], window[j] = window[j], window[i]
+		})
+
+		for _, commit := range window {
+			if len(commits) == n {
+				break
+			}
+			seen[commit.ID] = true
+			commits = append(commits, commit)
+		}
+
+		if len(commits) == n {
+			break
+		}
+	}
+
+	rand.Shuffle(len(commits), func(i, j int) {
+		commits[i], commits[j] = commits[j], commits[i]
+	})
+
+	return commits
+}
+
+// GetCommit returns the valid commit with the given ID, with its Author and Repo.
+// Returns nil if there's no such commit.
+func (m *MemoryDB) GetCommit(ID int) (*models.GitCommit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if ID < 1 || ID > len(m.commits) || !m.commits[ID-1].Valid {
+		return nil, nil
+	}
+
+	commit, ok := m.join(m.commits[ID-1], true)
+	if !ok {
+		return nil, nil
+	}
+
+	return &commit, nil
+}
+
+// SearchCommits returns a page of the valid commits with every word of the query, newest first.
+// The words are matched anywhere in the messages, like the SqliteDB without the FTS5 index.
+func (m *MemoryDB) SearchCommits(query string, filters models.SearchFilters, page int) (models.SearchResults, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	words := models.SearchWords(query)
+	if len(words) == 0 {
+		return models.SearchResults{Query: query, Page: 1, Commits: models.GitCommits{}}, nil
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	results := models.SearchResults{Query: query, Page: page, Commits: models.GitCommits{}}
+
+	matches := models.GitCommits{}
+	for _, commit := range m.commits {
+		if !commit.Valid || commit.CanonicalID.Valid {
+			continue
+		}
+		if (filters.Group != "" && commit.Group != filters.Group) || (filters.Lang != "" && commit.Lang != filters.Lang) {
+			continue
+		}
+		if !containsWords(commit.Message, words) {
+			continue
+		}
+		if commit, ok := m.join(commit, true); ok {
+			matches = append(matches, commit)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if !matches[i].Date.Equal(matches[j].Date) {
+			return matches[i].Date.After(matches[j].Date)
+		}
+		return matches[i].ID > matches[j].ID
+	})
+
+	start := (page - 1) * models.SearchPageSize
+	if start < len(matches) {
+		end := start + models.SearchPageSize
+		if end < len(matches) {
+			results.HasNext = true
+		} else {
+			end = len(matches)
+		}
+		results.Commits = append(results.Commits, matches[start:end]...)
+	}
+
+	return results, nil
+}
+
+// containsWords returns true if the message has every word, ignoring the case.
+// The words are lowercase, like the SearchWords.
+func containsWords(message string, words []string) bool {
+	message = strings.ToLower(message)
+	for _, word := range words {
+		if !strings.Contains(message, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetOrCreateUser is a convenience method to get the provided User,
+// or create it if it doesn't exist.
+func (m *MemoryDB) GetOrCreateUser(user *models.GitUser) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.getOrCreateUser(user)
+	return nil
+}
+
+// getOrCreateUser sets the ID of the user with the same url, or inserts the user.
+func (m *MemoryDB) getOrCreateUser(user *models.GitUser) {
+	for _, existing := range m.users {
+		if existing.URL == user.URL {
+			user.ID = existing.ID
+			return
+		}
+	}
+
+	user.ID = len(m.users) + 1
+	m.users = append(m.users, *user)
+}
+
+// GetOrCreateRepo is a convenience method to get the provided Repo,
+// or create it if it doesn't exist.
+func (m *MemoryDB) GetOrCreateRepo(repo *models.GitRepo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.getOrCreateRepo(repo)
+	return nil
+}
+
+// getOrCreateRepo sets the ID of the repo with the same url, or inserts the repo.
+func (m *MemoryDB) getOrCreateRepo(repo *models.GitRepo) {
+	for _, existing := range m.repos {
+		if existing.URL == repo.URL {
+			repo.ID = existing.ID
+			return
+		}
+	}
+
+	repo.ID = len(m.repos) + 1
+	m.repos = append(m.repos, *repo)
+}
+
+// GetOrCreateCommit is a convenience method to get the provided Commit,
+// or create it if it doesn't exist. The same message by the same author,
+// or the same url, is the same commit.
+// Returns true if the Commit was created.
+func (m *MemoryDB) GetOrCreateCommit(commit *models.GitCommit) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.createCommit(commit), nil
+}
+
+// createCommit inserts the commit and sets its ID, unless a commit with the same url,
+// or the same author and message, exists. The ID of that commit is set then.
+// Returns true if the commit was created.
+func (m *MemoryDB) createCommit(commit *models.GitCommit) bool {
+	for _, existing := range m.commits {
+		if (existing.AuthorID == commit.AuthorID && existing.Message == commit.Message) || existing.URL == commit.URL {
+			commit.ID = existing.ID
+			return false
+		}
+	}
+
+	commit.ID = len(m.commits) + 1
+	m.commits = append(m.commits, stripCommit(*commit))
+	m.saveBands(commit.ID, 0, commit.Simhash)
+	return true
+}
+
+// SaveCommitBatch saves the commits, along with their Author and Repo, all at once.
+// Users, repos and commits that already exist are left untouched.
+// Returns the commits that were created.
+func (m *MemoryDB) SaveCommitBatch(commits models.GitCommits) (models.GitCommits, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	created := models.GitCommits{}
+
+	for _, commit := range commits {
+		m.getOrCreateUser(&commit.Author)
+		m.getOrCreateRepo(&commit.Repo)
+		commit.AuthorID = commit.Author.ID
+		commit.RepoID = commit.Repo.ID
+
+		// The same message by the same author is a duplicate, even in another repo.
+		duplicate := false
+		for _, existing := range m.commits {
+			if existing.AuthorID == commit.AuthorID && existing.Message == commit.Message {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		m.findCanonical(&commit)
+
+		if m.createCommit(&commit) {
+			created = append(created, commit)
+		}
+	}
+
+	return created, nil
+}
+
+// findCanonical looks for an earlier, valid commit with the same or a similar message,
+// and sets it as the commit's canonical commit, like the findCanonical of the SQL backends.
+func (m *MemoryDB) findCanonical(commit *models.GitCommit) {
+	commit.CanonicalID = sql.NullInt64{}
+
+	if !commit.Valid || commit.MessageHash == "" {
+		return
+	}
+
+	candidate := func(other models.GitCommit) bool {
+		return other.Valid && !other.CanonicalID.Valid && (commit.ID == 0 || other.ID < commit.ID)
+	}
+
+	// Look for the same normalized message.
+	for _, other := range m.commits {
+		if other.MessageHash == commit.MessageHash && candidate(other) {
+			commit.CanonicalID = sql.NullInt64{Int64: int64(other.ID), Valid: true}
+			return
+		}
+	}
+
+	if commit.Simhash == 0 {
+		return
+	}
+
+	// Look for a similar message, among the commits that share a band of the simhash.
+	// Pick the closest candidate, or the oldest one if there's a tie.
+	ids := map[int]bool{}
+	for _, key := range bandKeys(commit.Simhash) {
+		for id := range m.bands[key] {
+			ids[id] = true
+		}
+	}
+
+	sorted := []int{}
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Ints(sorted)
+
+	closest := utils.SimhashThreshold + 1
+	for _, id := range sorted {
+		other := m.commits[id-1]
+		if !candidate(other) {
+			continue
+		}
+		if distance := commit.SimhashDistance(other); distance < closest {
+			closest = distance
+			commit.CanonicalID = sql.NullInt64{Int64: int64(other.ID), Valid: true}
+		}
+	}
+}
+
+// saveBands moves the commit from the bands of its old simhash to the bands of the new one.
+// A commit without a simhash has no bands.
+func (m *MemoryDB) saveBands(commitID int, old, new int64) {
+	if old != 0 {
+		for _, key := range bandKeys(old) {
+			delete(m.bands[key], commitID)
+		}
+	}
+
+	if new == 0 {
+		return
+	}
+
+	for _, key := range bandKeys(new) {
+		if m.bands[key] == nil {
+			m.bands[key] = map[int]bool{}
+		}
+		m.bands[key][commitID] = true
+	}
+}
+
+// DedupeCommits recomputes the message hashes of all the commits, and clusters the
+// near-duplicates under their canonical commit, all at once.
+// Returns the number of duplicate commits.
+func (m *MemoryDB) DedupeCommits() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	duplicates := 0
+	for i := range m.commits {
+		commit := m.commits[i]
+		commit.SetMessageHash()
+		m.findCanonical(&commit)
+
+		if commit.CanonicalID.Valid {
+			duplicates++
+		}
+
+		m.saveBands(commit.ID, m.commits[i].Simhash, commit.Simhash)
+		m.commits[i].MessageHash = commit.MessageHash
+		m.commits[i].Simhash = commit.Simhash
+		m.commits[i].CanonicalID = commit.CanonicalID
+	}
+
+	return duplicates, nil
+}
+
+// ------------------------------------------------------------------
+// Methods to modify pipeline-related tables (PipelineRun)
+
+// CreatePipelineRun inserts a new PipelineRun row and sets the ID.
+func (m *MemoryDB) CreatePipelineRun(run *models.PipelineRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	run.ID = len(m.runs) + 1
+	m.runs = append(m.runs, *run)
+	return nil
+}
+
+// RecentPipelineRuns returns the most recent pipeline runs, newest first.
+func (m *MemoryDB) RecentPipelineRuns(limit int) (models.PipelineRuns, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	runs := append(models.PipelineRuns{}, m.runs...)
+	sort.SliceStable(runs, func(i, j int) bool {
+		if !runs[i].StartedAt.Equal(runs[j].StartedAt) {
+			return runs[i].StartedAt.After(runs[j].StartedAt)
+		}
+		return runs[i].ID > runs[j].ID
+	})
+
+	if limit >= 0 && limit < len(runs) {
+		runs = runs[:limit]
+	}
+
+	return runs, nil
+}
+
+// CreateSearchHistories inserts the SearchHistory rows all at once.
+func (m *MemoryDB) CreateSearchHistories(histories models.SearchHistories) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, history := range histories {
+		history.ID = len(m.histories) + 1
+		m.histories = append(m.histories, history)
+	}
+
+	return nil
+}
+
+// UpdateSearchTermStates upserts the SearchTermState rows all at once.
+// The watermark of a term only ever moves forward.
+func (m *MemoryDB) UpdateSearchTermStates(states models.SearchTermStates) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, state := range states {
+		if existing, ok := m.searchStates[state.Term]; ok && existing.Watermark.Valid {
+			if !state.Watermark.Valid || !state.Watermark.Time.After(existing.Watermark.Time) {
+				state.Watermark = existing.Watermark
+			}
+		}
+		m.searchStates[state.Term] = state
+	}
+
+	return nil
+}
+
+// ------------------------------------------------------------------
+// Methods to modify webhook-related tables (WebhookDelivery)
+
+// CreateWebhookDelivery inserts a new WebhookDelivery row and sets the ID.
+func (m *MemoryDB) CreateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delivery.ID = len(m.deliveries) + 1
+	m.deliveries = append(m.deliveries, *delivery)
+	return nil
+}
+
+// RecentWebhookDeliveries returns the most recent webhook deliveries, newest first.
+func (m *MemoryDB) RecentWebhookDeliveries(limit int) (models.WebhookDeliveries, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deliveries := make(models.WebhookDeliveries, 0, len(m.deliveries))
+	for i := len(m.deliveries) - 1; i >= 0 && (limit < 0 || len(deliveries) < limit); i-- {
+		deliveries = append(deliveries, m.deliveries[i])
+	}
+
+	return deliveries, nil
+}
+
+// ------------------------------------------------------------------
+// Methods to modify humor-related tables (CommitLabel, HumorModel)
+
+// LabelCommit records whether the commit is funny, replacing its previous label.
+func (m *MemoryDB) LabelCommit(commitID int, funny bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.labels[commitID] = models.CommitLabel{CommitID: commitID, Funny: funny, LabeledAt: time.Now().UTC()}
+	return nil
+}
+
+// CommitLabels returns all the labels, with the message of the labeled commit.
+func (m *MemoryDB) CommitLabels() (models.CommitLabels, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	labels := models.CommitLabels{}
+	for commitID, label := range m.labels {
+		if commitID < 1 || commitID > len(m.commits) {
+			continue
+		}
+		label.Message = m.commits[commitID-1].Message
+		labels = append(labels, label)
+	}
+
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].CommitID < labels[j].CommitID
+	})
+
+	return labels, nil
+}
+
+// SaveHumorModel inserts a new HumorModel row.
+func (m *MemoryDB) SaveHumorModel(model *models.HumorModel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	model.ID = len(m.humorModels) + 1
+	m.humorModels = append(m.humorModels, *model)
+	return nil
+}
+
+// LatestHumorModel returns the most recently trained model, or nil if none was trained yet.
+func (m *MemoryDB) LatestHumorModel() (*models.HumorModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.humorModels) == 0 {
+		return nil, nil
+	}
+
+	model := m.humorModels[len(m.humorModels)-1]
+	return &model, nil
+}
+
+// ------------------------------------------------------------------
+
+// Ensure the MemoryDB type satisfies the Database interface.
+var _ Database = &MemoryDB{}
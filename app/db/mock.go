@@ -1,20 +1,47 @@
 package db
 
-import "github.com/tunedmystic/commits.lol/app/models"
+import (
+	"time"
+
+	"github.com/tunedmystic/commits.lol/app/models"
+)
 
 // MockDB is an fake DB type that implements the Database interface.
 // Used for testing.
 type MockDB struct {
-	AllBadWordsMock       func() (models.BadWords, error)
-	AllGroupTermsMock     func() (models.GroupTerms, error)
-	RandomSearchTermsMock func() (models.SearchTerms, error)
+	AllBadWordsMock          func() (models.BadWords, error)
+	AllBlockWordsMock        func() (models.BlockWords, error)
+	CreateBlockWordMock      func(word *models.BlockWord) error
+	DeleteBlockWordMock      func(ID int) error
+	AllGroupTermsMock        func() (models.GroupTerms, error)
+	AllSearchTermsMock       func() (models.SearchTerms, error)
+	RandomSearchTermsMock    func() (models.SearchTermYields, error)
+	SearchTermYieldsMock     func(since time.Time) (models.SearchTermYields, error)
+	UpdateSearchTermRankMock func(ID, rank int) error
 
 	AllCommitsMock           func() (models.GitCommits, error)
 	UpdateCommitMock         func(commit *models.GitCommit) error
-	RecentCommitsByGroupMock func(group string) (models.GitCommits, error)
+	RecentCommitsByGroupMock func(group, lang string) (models.GitCommits, error)
+	GetCommitMock            func(ID int) (*models.GitCommit, error)
+	SearchCommitsMock        func(query string, filters models.SearchFilters, page int) (models.SearchResults, error)
 	GetOrCreateUserMock      func(user *models.GitUser) error
 	GetOrCreateRepoMock      func(repo *models.GitRepo) error
-	GetOrCreateCommitMock    func(commit *models.GitCommit) error
+	GetOrCreateCommitMock    func(commit *models.GitCommit) (bool, error)
+	SaveCommitBatchMock      func(commits models.GitCommits) (models.GitCommits, error)
+	DedupeCommitsMock        func() (int, error)
+
+	CreatePipelineRunMock      func(run *models.PipelineRun) error
+	RecentPipelineRunsMock     func(limit int) (models.PipelineRuns, error)
+	CreateSearchHistoriesMock  func(histories models.SearchHistories) error
+	UpdateSearchTermStatesMock func(states models.SearchTermStates) error
+
+	CreateWebhookDeliveryMock   func(delivery *models.WebhookDelivery) error
+	RecentWebhookDeliveriesMock func(limit int) (models.WebhookDeliveries, error)
+
+	LabelCommitMock      func(commitID int, funny bool) error
+	CommitLabelsMock     func() (models.CommitLabels, error)
+	SaveHumorModelMock   func(model *models.HumorModel) error
+	LatestHumorModelMock func() (*models.HumorModel, error)
 }
 
 // AllBadWords ...
@@ -22,16 +49,46 @@ func (m *MockDB) AllBadWords() (models.BadWords, error) {
 	return m.AllBadWordsMock()
 }
 
+// AllBlockWords ...
+func (m *MockDB) AllBlockWords() (models.BlockWords, error) {
+	return m.AllBlockWordsMock()
+}
+
+// CreateBlockWord ...
+func (m *MockDB) CreateBlockWord(word *models.BlockWord) error {
+	return m.CreateBlockWordMock(word)
+}
+
+// DeleteBlockWord ...
+func (m *MockDB) DeleteBlockWord(ID int) error {
+	return m.DeleteBlockWordMock(ID)
+}
+
 // AllGroupTerms ...
 func (m *MockDB) AllGroupTerms() (models.GroupTerms, error) {
 	return m.AllGroupTermsMock()
 }
 
+// AllSearchTerms ...
+func (m *MockDB) AllSearchTerms() (models.SearchTerms, error) {
+	return m.AllSearchTermsMock()
+}
+
 // RandomSearchTerms ...
-func (m *MockDB) RandomSearchTerms() (models.SearchTerms, error) {
+func (m *MockDB) RandomSearchTerms() (models.SearchTermYields, error) {
 	return m.RandomSearchTermsMock()
 }
 
+// SearchTermYields ...
+func (m *MockDB) SearchTermYields(since time.Time) (models.SearchTermYields, error) {
+	return m.SearchTermYieldsMock(since)
+}
+
+// UpdateSearchTermRank ...
+func (m *MockDB) UpdateSearchTermRank(ID, rank int) error {
+	return m.UpdateSearchTermRankMock(ID, rank)
+}
+
 // AllCommits ...
 func (m *MockDB) AllCommits() (models.GitCommits, error) {
 	return m.AllCommitsMock()
@@ -43,8 +100,18 @@ func (m *MockDB) UpdateCommit(commit *models.GitCommit) error {
 }
 
 // RecentCommitsByGroup ...
-func (m *MockDB) RecentCommitsByGroup(group string) (models.GitCommits, error) {
-	return m.RecentCommitsByGroupMock(group)
+func (m *MockDB) RecentCommitsByGroup(group, lang string) (models.GitCommits, error) {
+	return m.RecentCommitsByGroupMock(group, lang)
+}
+
+// GetCommit ...
+func (m *MockDB) GetCommit(ID int) (*models.GitCommit, error) {
+	return m.GetCommitMock(ID)
+}
+
+// SearchCommits ...
+func (m *MockDB) SearchCommits(query string, filters models.SearchFilters, page int) (models.SearchResults, error) {
+	return m.SearchCommitsMock(query, filters, page)
 }
 
 // GetOrCreateUser ...
@@ -58,10 +125,70 @@ func (m *MockDB) GetOrCreateRepo(repo *models.GitRepo) error {
 }
 
 // GetOrCreateCommit ...
-func (m *MockDB) GetOrCreateCommit(commit *models.GitCommit) error {
+func (m *MockDB) GetOrCreateCommit(commit *models.GitCommit) (bool, error) {
 	return m.GetOrCreateCommitMock(commit)
 }
 
+// SaveCommitBatch ...
+func (m *MockDB) SaveCommitBatch(commits models.GitCommits) (models.GitCommits, error) {
+	return m.SaveCommitBatchMock(commits)
+}
+
+// DedupeCommits ...
+func (m *MockDB) DedupeCommits() (int, error) {
+	return m.DedupeCommitsMock()
+}
+
+// CreatePipelineRun ...
+func (m *MockDB) CreatePipelineRun(run *models.PipelineRun) error {
+	return m.CreatePipelineRunMock(run)
+}
+
+// RecentPipelineRuns ...
+func (m *MockDB) RecentPipelineRuns(limit int) (models.PipelineRuns, error) {
+	return m.RecentPipelineRunsMock(limit)
+}
+
+// CreateSearchHistories ...
+func (m *MockDB) CreateSearchHistories(histories models.SearchHistories) error {
+	return m.CreateSearchHistoriesMock(histories)
+}
+
+// UpdateSearchTermStates ...
+func (m *MockDB) UpdateSearchTermStates(states models.SearchTermStates) error {
+	return m.UpdateSearchTermStatesMock(states)
+}
+
+// CreateWebhookDelivery ...
+func (m *MockDB) CreateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	return m.CreateWebhookDeliveryMock(delivery)
+}
+
+// RecentWebhookDeliveries ...
+func (m *MockDB) RecentWebhookDeliveries(limit int) (models.WebhookDeliveries, error) {
+	return m.RecentWebhookDeliveriesMock(limit)
+}
+
+// LabelCommit ...
+func (m *MockDB) LabelCommit(commitID int, funny bool) error {
+	return m.LabelCommitMock(commitID, funny)
+}
+
+// CommitLabels ...
+func (m *MockDB) CommitLabels() (models.CommitLabels, error) {
+	return m.CommitLabelsMock()
+}
+
+// SaveHumorModel ...
+func (m *MockDB) SaveHumorModel(model *models.HumorModel) error {
+	return m.SaveHumorModelMock(model)
+}
+
+// LatestHumorModel ...
+func (m *MockDB) LatestHumorModel() (*models.HumorModel, error) {
+	return m.LatestHumorModelMock()
+}
+
 // Close ...
 func (m *MockDB) Close() {}
 
@@ -0,0 +1,16 @@
+package db
+
+import "strings"
+
+// Open connects to the database at the URL, and returns it as a Database.
+// A postgres:// or postgresql:// URL connects to a PostgresDB. Anything else is the
+// name of an SQLite database, with an optional sqlite:// scheme.
+func Open(url string) Database {
+	if strings.HasPrefix(url, "postgres://") || strings.HasPrefix(url, "postgresql://") {
+		pdb := NewPostgresDB(url)
+		return &pdb
+	}
+
+	sdb := NewSqliteDB(strings.TrimPrefix(url, "sqlite://"))
+	return &sdb
+}
@@ -0,0 +1,27 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	u "github.com/tunedmystic/commits.lol/app/utils"
+)
+
+func Test_Open(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "db")
+	defer os.RemoveAll(dir)
+
+	s := Open("sqlite://" + filepath.Join(dir, "test.sqlite"))
+	defer s.Close()
+
+	_, ok := s.(*SqliteDB)
+	u.AssertEqual(t, ok, true)
+
+	s = Open(filepath.Join(dir, "other.sqlite"))
+	defer s.Close()
+
+	_, ok = s.(*SqliteDB)
+	u.AssertEqual(t, ok, true)
+}
@@ -0,0 +1,35 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ------------------------------------------------------------------
+// Helpers
+// ------------------------------------------------------------------
+
+// testPostgresDB creates a temporary schema in the database at the URL,
+// and connects to it, so the tables are created in it. The schema is dropped after the test.
+func testPostgresDB(t *testing.T, url string) PostgresDB {
+	name := fmt.Sprintf("test_%d", time.Now().UnixNano())
+
+	admin := sqlx.MustConnect("postgres", url)
+	admin.MustExec(fmt.Sprintf(`CREATE SCHEMA %s;`, name))
+	t.Cleanup(func() {
+		admin.MustExec(fmt.Sprintf(`DROP SCHEMA %s CASCADE;`, name))
+		admin.Close()
+	})
+
+	// Every connection of the pool uses the schema.
+	separator := "?"
+	if strings.Contains(url, "?") {
+		separator = "&"
+	}
+
+	return NewPostgresDB(url + separator + "search_path=" + name)
+}
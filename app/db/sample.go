@@ -0,0 +1,282 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tunedmystic/commits.lol/app/config"
+	"github.com/tunedmystic/commits.lol/app/models"
+)
+
+const (
+	// recentCommitsLength is the number of commits in a batch of the homepage.
+	recentCommitsLength = 33
+
+	// sampleWindows is how many times the window of a sample is doubled,
+	// before every commit is sampled.
+	sampleWindows = 5
+
+	// sampleRounds is how many rounds of random probes are made in a window,
+	// before the missing commits are scanned for.
+	sampleRounds = 3
+
+	// sampleMaxProbes is the most random IDs that are probed in a round.
+	sampleMaxProbes = 500
+)
+
+// sampleFilter is the WHERE clause of the commits that can be sampled, with its arguments.
+// Only the filters that are set are in the clause, so the bounds can use the date indexes.
+// The seek clause is the same, but the group is compared as an expression, so that the
+// probes and scans use the primary key, instead of the group index.
+type sampleFilter struct {
+	where string
+	seek  string
+	args  []interface{}
+}
+
+// newSampleFilter returns the filter of the valid, canonical commits since the given time.
+// The commits are filtered by group and language, if they're not empty.
+func newSampleFilter(since time.Time, group, lang string) sampleFilter {
+	f := sampleFilter{
+		where: "c.date > ? AND c.valid = TRUE AND c.canonical_id IS NULL",
+		args:  []interface{}{since},
+	}
+	f.seek = f.where
+
+	if group != "" {
+		f.where += " AND c.groupname = ?"
+		f.seek += " AND c.groupname || '' = ?"
+		f.args = append(f.args, group)
+	}
+	if lang != "" {
+		f.where += " AND c.lang = ?"
+		f.seek += " AND c.lang = ?"
+		f.args = append(f.args, lang)
+	}
+	return f
+}
+
+// sampleColumns are the columns of a sampled commit, with its Author.
+const sampleColumns = `
+	c.id,
+	c.author_id,
+	c.repo_id,
+	c.message,
+	c.message_censored,
+	c.body,
+	c.url,
+	c.color_bg,
+	c.color_fg,
+	c.score,
+
+	u.id AS "author.id",
+	u.url AS "author.url",
+	u.avatar_url AS "author.avatar_url"`
+
+// recentCommitsByGroup returns a batch of random commits, from the sample function.
+// If there's a ScoreBias, the commits are sampled from a larger pool, favoring high humor scores.
+// Every backend shares it, with its own sample function.
+func recentCommitsByGroup(sample func(n int) (models.GitCommits, error)) (models.GitCommits, error) {
+	pool := recentCommitsLength
+	if config.App.ScoreBias > 0 {
+		pool = recentCommitsLength * 4
+	}
+
+	commits, err := sample(pool)
+	if err != nil {
+		return nil, fmt.Errorf("db:RecentCommitsByGroup: %v", err)
+	}
+
+	if config.App.ScoreBias > 0 {
+		return commits.WeightedSample(recentCommitsLength, config.App.ScoreBias, rand.Float64), nil
+	}
+
+	return commits, nil
+}
+
+// sampleWindowStarts returns the start of every window of a sample, from the newest.
+// The first window is the last RecentDays, and it's doubled sampleWindows times,
+// before the last window, which has every commit.
+func sampleWindowStarts(now time.Time) []time.Time {
+	starts := make([]time.Time, 0, sampleWindows+2)
+	for i, days := 0, config.App.RecentDays; i <= sampleWindows; i, days = i+1, days*2 {
+		starts = append(starts, now.AddDate(0, 0, -days))
+	}
+	return append(starts, time.Time{})
+}
+
+// sampleCommits returns n random commits, or every commit if there are fewer than n.
+//
+// The commits are sampled from the last RecentDays. If there aren't enough of them,
+// the window is doubled, up to sampleWindows times, and then every commit is sampled.
+//
+// In each window, random IDs are picked between the lowest and highest IDs of the window,
+// and the ones of a commit that passes the filters are kept. These are lookups on the
+// primary key, so the sample doesn't scan the window, like `ORDER BY random()` would.
+// Every commit of the window is as likely to be probed, even after a gap in the IDs
+// (deleted commits, or commits of other groups). Seeking the next commit from a random ID
+// would favor the commits after the gaps instead.
+// If the probes keep missing, the window is scanned in ID order from a random ID,
+// to fill the sample. That scan does favor the commits after the gaps, but it's only
+// reached when the commits of the window are too sparse to be probed.
+func sampleCommits(db *sqlx.DB, group, lang string, n int) (models.GitCommits, error) {
+	commits := make(models.GitCommits, 0, n)
+	seen := make(map[int]bool, n)
+	add := func(found models.GitCommits) {
+		for _, c := range found {
+			if len(commits) < n && !seen[c.ID] {
+				seen[c.ID] = true
+				commits = append(commits, c)
+			}
+		}
+	}
+
+	for _, since := range sampleWindowStarts(time.Now().UTC()) {
+		filter := newSampleFilter(since, group, lang)
+
+		lo, hi, err := sampleBounds(db, filter)
+		if err != nil {
+			return nil, err
+		}
+		if hi < lo {
+			continue
+		}
+
+		// Twice the missing commits are probed at first, as some probes find
+		// a commit that was already sampled, or none at all.
+		probes := 2 * (n - len(commits))
+		for round := 0; round < sampleRounds && len(commits) < n; round++ {
+			ids := make([]int64, probes)
+			for i := range ids {
+				ids[i] = lo + rand.Int63n(hi-lo+1)
+			}
+
+			found, err := sampleProbe(db, filter, ids)
+			if err != nil {
+				return nil, err
+			}
+
+			// The commits are found in ID order, so they're shuffled before they're added.
+			rand.Shuffle(len(found), func(i, j int) {
+				found[i], found[j] = found[j], found[i]
+			})
+			add(found)
+
+			probes = nextSampleProbes(n-len(commits), len(ids), len(found))
+		}
+
+		if len(commits) < n {
+			start := lo + rand.Int63n(hi-lo+1)
+			found, err := sampleScan(db, filter, start, lo, hi, n-len(commits)+len(seen))
+			if err != nil {
+				return nil, err
+			}
+			add(found)
+		}
+
+		if len(commits) == n {
+			break
+		}
+	}
+
+	rand.Shuffle(len(commits), func(i, j int) {
+		commits[i], commits[j] = commits[j], commits[i]
+	})
+
+	return commits, nil
+}
+
+// sampleBounds returns the lowest and highest IDs of the commits that can be sampled since
+// the given time. The bounds are inverted (lo > hi) when there are no commits.
+func sampleBounds(db *sqlx.DB, filter sampleFilter) (int64, int64, error) {
+	var lo, hi sql.NullInt64
+
+	query := fmt.Sprintf(`
+		SELECT MIN(c.id), MAX(c.id)
+		FROM git_commit c
+		WHERE %s;`, filter.where)
+
+	if err := db.QueryRowx(db.Rebind(query), filter.args...).Scan(&lo, &hi); err != nil {
+		return 0, 0, err
+	}
+	if !lo.Valid || !hi.Valid {
+		return 1, 0, nil
+	}
+
+	return lo.Int64, hi.Int64, nil
+}
+
+// nextSampleProbes returns how many IDs to probe in the next round, for the missing commits.
+// The probes of a round hit a commit at the rate of the commits among the IDs of the window,
+// so the next round makes enough probes to find twice the missing commits, at that rate.
+func nextSampleProbes(missing, probed, hits int) int {
+	if hits == 0 {
+		hits = 1
+		probed *= 2
+	}
+
+	probes := 2 * missing * probed / hits
+	if probes > sampleMaxProbes {
+		probes = sampleMaxProbes
+	}
+	return probes
+}
+
+// sampleProbe returns the commits that pass the filters, among the probed IDs.
+func sampleProbe(db *sqlx.DB, filter sampleFilter, probes []int64) (models.GitCommits, error) {
+	commits := models.GitCommits{}
+
+	values := strings.TrimSuffix(strings.Repeat("?, ", len(probes)), ", ")
+	args := append([]interface{}{}, filter.args...)
+	for _, probe := range probes {
+		args = append(args, probe)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM git_commit c
+		INNER JOIN git_user u on u.id = c.author_id
+		WHERE %s AND c.id IN (%s)
+		ORDER BY c.id;`, sampleColumns, filter.seek, values)
+
+	if err := db.Select(&commits, db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+
+	return commits, nil
+}
+
+// sampleScan returns up to limit commits, in ID order, starting from the given ID.
+// It wraps around to the lowest ID of the window, if there aren't enough commits after the start.
+func sampleScan(db *sqlx.DB, filter sampleFilter, start, lo, hi int64, limit int) (models.GitCommits, error) {
+	commits := models.GitCommits{}
+
+	for _, bounds := range [][2]int64{{start, hi}, {lo, start - 1}} {
+		found := models.GitCommits{}
+
+		query := fmt.Sprintf(`
+			SELECT %s
+			FROM git_commit c
+			INNER JOIN git_user u on u.id = c.author_id
+			WHERE c.id BETWEEN ? AND ? AND %s
+			ORDER BY c.id
+			LIMIT ?;`, sampleColumns, filter.seek)
+
+		args := append([]interface{}{bounds[0], bounds[1]}, filter.args...)
+		args = append(args, limit-len(commits))
+		if err := db.Select(&found, db.Rebind(query), args...); err != nil {
+			return nil, err
+		}
+
+		commits = append(commits, found...)
+		if len(commits) == limit {
+			break
+		}
+	}
+
+	return commits, nil
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// The schemas of the backends, the migrations, and the default terms, embedded in the binary.
+//
+//go:embed sql
+var sqlFiles embed.FS
+
+// createSchema creates the tables that don't exist yet, from the embedded schema file,
+// and applies the migrations. If the database had no tables, the default terms are
+// inserted too, so a new database is ready to use. The tablesQuery counts the tables of the database.
+func createSchema(db *sqlx.DB, schemaFile, tablesQuery string) error {
+	tables := 0
+	if err := db.Get(&tables, tablesQuery); err != nil {
+		return fmt.Errorf("db:createSchema: %v", err)
+	}
+
+	schema, err := sqlFiles.ReadFile(schemaFile)
+	if err != nil {
+		return fmt.Errorf("db:createSchema: %v", err)
+	}
+
+	if _, err := db.Exec(string(schema)); err != nil {
+		return fmt.Errorf("db:createSchema: %v", err)
+	}
+
+	if err := migrate(db); err != nil {
+		return fmt.Errorf("db:createSchema: %v", err)
+	}
+
+	if tables > 0 {
+		return nil
+	}
+
+	defaults, err := sqlFiles.ReadFile("sql/defaults.sql")
+	if err != nil {
+		return fmt.Errorf("db:createSchema: %v", err)
+	}
+
+	if _, err := db.Exec(string(defaults)); err != nil {
+		return fmt.Errorf("db:createSchema: %v", err)
+	}
+
+	return nil
+}
+
+// migrate applies the migrations that weren't applied yet, in the order of their names.
+// Each migration runs in its own transaction, and is recorded in the schema_migration table.
+// The migrations are shared by the backends, so they must be portable SQL, except for
+// the ones named after a driver, like 003_name.postgres.sql, which only apply to it.
+// Every database goes through them, from the original tables of the schema file,
+// so a database from before the migrations is brought up to date like a new one.
+func migrate(db *sqlx.DB) error {
+	applied := []string{}
+	if err := db.Select(&applied, `SELECT name FROM schema_migration;`); err != nil {
+		return err
+	}
+
+	done := map[string]bool{}
+	for _, name := range applied {
+		done[name] = true
+	}
+
+	entries, err := sqlFiles.ReadDir("sql/migrations")
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if done[name] {
+			continue
+		}
+		if driver := migrationDriver(name); driver != "" && driver != db.DriverName() {
+			continue
+		}
+
+		migration, err := sqlFiles.ReadFile(path.Join("sql/migrations", name))
+		if err != nil {
+			return err
+		}
+
+		tx, err := db.Beginx()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(string(migration)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %s: %v", name, err)
+		}
+
+		query := tx.Rebind(`INSERT INTO schema_migration ("name", "applied_at") VALUES (?, ?);`)
+		if _, err := tx.Exec(query, name, time.Now().UTC()); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %s: %v", name, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// migrationDriver returns the driver that the migration is named after,
+// or an empty string if the migration applies to every driver.
+// Example:  003_source_text.postgres.sql  ->  postgres
+func migrationDriver(name string) string {
+	return strings.TrimPrefix(path.Ext(strings.TrimSuffix(name, ".sql")), ".")
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tunedmystic/commits.lol/app/models"
+)
+
+// searchCommits returns a page of the valid, canonical commits that pass the match clause
+// and the filters, newest first, with their Author and Repo. The queries are rebound for
+// the driver, so every backend shares it, with its own match clause.
+func searchCommits(db *sqlx.DB, match string, matchArgs []interface{}, query string, filters models.SearchFilters, page int) (models.SearchResults, error) {
+	if page < 1 {
+		page = 1
+	}
+	results := models.SearchResults{Query: query, Page: page, Commits: models.GitCommits{}}
+
+	sqlQuery := fmt.Sprintf(`
+		SELECT
+			c.*,
+			u.id AS "author.id",
+			u.source AS "author.source",
+			u.username AS "author.username",
+			u.url AS "author.url",
+			u.avatar_url AS "author.avatar_url",
+			r.id AS "repo.id",
+			r.source AS "repo.source",
+			r.name AS "repo.name",
+			r.description AS "repo.description",
+			r.url AS "repo.url"
+		FROM git_commit c
+		INNER JOIN git_user u ON u.id = c.author_id
+		INNER JOIN git_repo r ON r.id = c.repo_id
+		WHERE
+			%s AND
+			c.valid = TRUE AND
+			c.canonical_id IS NULL AND
+			(? = '' OR c.groupname = ?) AND
+			(? = '' OR c.lang = ?)
+		ORDER BY c.date DESC, c.id DESC
+		LIMIT ? OFFSET ?;`, match)
+
+	// One more commit than the page size is fetched, to know if there's a next page.
+	args := append(matchArgs, filters.Group, filters.Group, filters.Lang, filters.Lang)
+	args = append(args, models.SearchPageSize+1, (page-1)*models.SearchPageSize)
+
+	if err := db.Select(&results.Commits, db.Rebind(sqlQuery), args...); err != nil {
+		return results, fmt.Errorf("db:SearchCommits: %v", err)
+	}
+
+	if len(results.Commits) > models.SearchPageSize {
+		results.Commits = results.Commits[:models.SearchPageSize]
+		results.HasNext = true
+	}
+
+	return results, nil
+}
+
+// ftsQuery returns an FTS5 query that matches the commits with every word.
+// Each word is quoted, so the FTS5 syntax in the words is matched as text.
+// Example:  [fixed "bug]  ->  "fixed" """bug"
+func ftsQuery(words []string) string {
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		quoted = append(quoted, `"`+strings.ReplaceAll(word, `"`, `""`)+`"`)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// likePattern returns a LIKE pattern that matches the word anywhere in the text.
+// The wildcards in the word are escaped with a backslash.
+func likePattern(word string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + replacer.Replace(word) + "%"
+}
@@ -0,0 +1,15 @@
+-- The default terms of a new database. They're only inserted when the tables are created.
+
+-- Bad words are matched anywhere in a word, so short words like 'ass' would censor 'class'.
+INSERT INTO config_badword (text) VALUES
+    ('fuck'), ('shit'), ('damn'), ('bitch'), ('bastard'), ('asshole');
+
+INSERT INTO config_searchterm (text, rank) VALUES
+    ('fuck', 1), ('shit', 1), ('wtf', 1), ('damn', 1), ('crap', 1),
+    ('poop', 1), ('holy', 1), ('omg', 1), ('jesus', 1), ('god', 1),
+    ('lol', 1), ('ugh', 1), ('oops', 1), ('stupid', 1), ('please work', 1);
+
+-- The groups are the buttons of the homepage.
+INSERT INTO config_groupterm (text, groupname) VALUES
+    ('shit', 'poop'), ('crap', 'poop'), ('poop', 'poop'), ('turd', 'poop'),
+    ('holy', 'holy'), ('omg', 'holy'), ('jesus', 'holy'), ('god', 'holy'), ('damn', 'holy');
@@ -0,0 +1,12 @@
+-- The columns of git_commit that were added after the original schema, and their index.
+-- The types are understood by both backends: SQLite maps them to its own.
+
+ALTER TABLE git_commit ADD COLUMN body TEXT NOT NULL DEFAULT '';
+ALTER TABLE git_commit ADD COLUMN body_censored TEXT NOT NULL DEFAULT '';
+ALTER TABLE git_commit ADD COLUMN lang VARCHAR(8) NOT NULL DEFAULT '';
+ALTER TABLE git_commit ADD COLUMN score DOUBLE PRECISION NULL;
+ALTER TABLE git_commit ADD COLUMN message_hash VARCHAR(40) NOT NULL DEFAULT '';
+ALTER TABLE git_commit ADD COLUMN simhash BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE git_commit ADD COLUMN canonical_id INTEGER NULL REFERENCES git_commit(id);
+
+CREATE INDEX IF NOT EXISTS git_commit_message_hash ON git_commit(message_hash);
@@ -0,0 +1,51 @@
+-- Indexes for the hot commit queries, and a unique constraint on the author and message.
+-- Concurrent workers could save the same commit twice before. The duplicates are kept,
+-- as duplicates of the oldest one (their canonical commit), and their labels are moved to it.
+-- The constraint only covers the canonical commits, so the duplicates can stay.
+
+-- The commits that pointed at a duplicate point at the oldest one instead.
+UPDATE git_commit SET canonical_id = (
+    SELECT MIN(k.id) FROM git_commit d
+    INNER JOIN git_commit k ON k.author_id = d.author_id AND k.message = d.message
+    WHERE d.id = git_commit.canonical_id
+)
+WHERE canonical_id IS NOT NULL;
+
+UPDATE git_commit SET canonical_id = (
+    SELECT MIN(k.id) FROM git_commit k
+    WHERE k.author_id = git_commit.author_id AND k.message = git_commit.message
+)
+WHERE id != (
+    SELECT MIN(k.id) FROM git_commit k
+    WHERE k.author_id = git_commit.author_id AND k.message = git_commit.message
+);
+
+-- The oldest commit may be a duplicate itself, so its duplicates point at its canonical commit.
+UPDATE git_commit SET canonical_id = (
+    SELECT p.canonical_id FROM git_commit p WHERE p.id = git_commit.canonical_id
+)
+WHERE canonical_id IN (SELECT id FROM git_commit WHERE canonical_id IS NOT NULL);
+
+-- The oldest commit gets the label of its first labeled duplicate, if it has none.
+INSERT INTO commit_label (commit_id, funny, labeled_at)
+SELECT k.id, l.funny, l.labeled_at
+FROM commit_label l
+INNER JOIN git_commit c ON c.id = l.commit_id
+INNER JOIN git_commit k ON k.id = (
+    SELECT MIN(g.id) FROM git_commit g
+    WHERE g.author_id = c.author_id AND g.message = c.message
+)
+WHERE l.commit_id = (
+    SELECT MIN(gl.commit_id) FROM commit_label gl
+    INNER JOIN git_commit g ON g.id = gl.commit_id
+    WHERE g.author_id = c.author_id AND g.message = c.message
+)
+AND l.commit_id != k.id;
+
+DELETE FROM commit_label
+WHERE commit_id NOT IN (SELECT MIN(id) FROM git_commit GROUP BY author_id, message);
+
+CREATE UNIQUE INDEX IF NOT EXISTS git_commit_author_message ON git_commit(author_id, message) WHERE canonical_id IS NULL;
+CREATE INDEX IF NOT EXISTS git_commit_sha ON git_commit(sha);
+CREATE INDEX IF NOT EXISTS git_commit_date ON git_commit(date) WHERE valid = TRUE AND canonical_id IS NULL;
+CREATE INDEX IF NOT EXISTS git_commit_group_date ON git_commit(groupname, date) WHERE valid = TRUE AND canonical_id IS NULL;
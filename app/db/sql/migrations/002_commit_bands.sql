@@ -0,0 +1,27 @@
+-- The bands of the commit simhashes, so the near-duplicates of a commit are found with an index.
+-- A band is stored as its position times 256, plus its 8 bits, so only the same band matches.
+
+CREATE TABLE IF NOT EXISTS git_commit_band (
+    commit_id INTEGER NOT NULL REFERENCES git_commit(id),
+    band INTEGER NOT NULL,
+    PRIMARY KEY (commit_id, band)
+);
+
+CREATE INDEX IF NOT EXISTS git_commit_band_band ON git_commit_band(band, commit_id);
+
+INSERT INTO git_commit_band (commit_id, band)
+SELECT id, 0 * 256 + ((simhash >> 0) & 255) FROM git_commit WHERE simhash != 0;
+INSERT INTO git_commit_band (commit_id, band)
+SELECT id, 1 * 256 + ((simhash >> 8) & 255) FROM git_commit WHERE simhash != 0;
+INSERT INTO git_commit_band (commit_id, band)
+SELECT id, 2 * 256 + ((simhash >> 16) & 255) FROM git_commit WHERE simhash != 0;
+INSERT INTO git_commit_band (commit_id, band)
+SELECT id, 3 * 256 + ((simhash >> 24) & 255) FROM git_commit WHERE simhash != 0;
+INSERT INTO git_commit_band (commit_id, band)
+SELECT id, 4 * 256 + ((simhash >> 32) & 255) FROM git_commit WHERE simhash != 0;
+INSERT INTO git_commit_band (commit_id, band)
+SELECT id, 5 * 256 + ((simhash >> 40) & 255) FROM git_commit WHERE simhash != 0;
+INSERT INTO git_commit_band (commit_id, band)
+SELECT id, 6 * 256 + ((simhash >> 48) & 255) FROM git_commit WHERE simhash != 0;
+INSERT INTO git_commit_band (commit_id, band)
+SELECT id, 7 * 256 + ((simhash >> 56) & 255) FROM git_commit WHERE simhash != 0;
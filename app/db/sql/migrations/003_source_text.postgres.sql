@@ -0,0 +1,15 @@
+-- The values from the sources use TEXT on PostgreSQL, like in the schema file, so a long value,
+-- or a SHA-256 commit hash, doesn't fail the whole batch. SQLite doesn't enforce the lengths.
+
+ALTER TABLE git_user ALTER COLUMN username TYPE TEXT;
+ALTER TABLE git_user ALTER COLUMN url TYPE TEXT;
+ALTER TABLE git_user ALTER COLUMN avatar_url TYPE TEXT;
+
+ALTER TABLE git_repo ALTER COLUMN name TYPE TEXT;
+ALTER TABLE git_repo ALTER COLUMN description TYPE TEXT;
+ALTER TABLE git_repo ALTER COLUMN url TYPE TEXT;
+
+ALTER TABLE git_commit ALTER COLUMN message TYPE TEXT;
+ALTER TABLE git_commit ALTER COLUMN message_censored TYPE TEXT;
+ALTER TABLE git_commit ALTER COLUMN sha TYPE TEXT;
+ALTER TABLE git_commit ALTER COLUMN url TYPE TEXT;
@@ -0,0 +1,129 @@
+-- The PostgreSQL version of schema.sql. Keep the two in sync.
+-- PostgreSQL enforces the length of a VARCHAR, unlike SQLite, so the users, repos
+-- and commits from the sources use TEXT, as a long value would fail the whole batch.
+
+CREATE TABLE IF NOT EXISTS config_badword (
+    id SERIAL PRIMARY KEY,
+    text VARCHAR(50) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS config_blockword (
+    id SERIAL PRIMARY KEY,
+    text VARCHAR(50) UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS config_groupterm (
+    id SERIAL PRIMARY KEY,
+    text VARCHAR(50) NOT NULL,
+    groupname VARCHAR(50) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS config_searchterm (
+    id SERIAL PRIMARY KEY,
+    text VARCHAR(50) NOT NULL,
+    rank INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS git_user (
+    id SERIAL PRIMARY KEY,
+    source INTEGER NOT NULL,
+    username TEXT NOT NULL,
+    url TEXT UNIQUE NOT NULL,
+    avatar_url TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS git_repo (
+    id SERIAL PRIMARY KEY,
+    source INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL,
+    url TEXT UNIQUE NOT NULL
+);
+
+-- The original table. The columns added since are in migrations/000_commit_columns.sql,
+-- so the databases from before get them too.
+CREATE TABLE IF NOT EXISTS git_commit (
+    id SERIAL PRIMARY KEY,
+    source INTEGER NOT NULL,
+    author_id INTEGER NOT NULL,
+    repo_id INTEGER NOT NULL,
+    message TEXT NOT NULL,
+    message_censored TEXT NOT NULL DEFAULT '',
+    sha TEXT NOT NULL,
+    url TEXT UNIQUE NOT NULL,
+    date TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    valid BOOLEAN NOT NULL DEFAULT TRUE,
+    groupname VARCHAR(50) NOT NULL,
+    color_bg VARCHAR(10) NOT NULL,
+    color_fg VARCHAR(10) NOT NULL,
+    FOREIGN KEY(repo_id) REFERENCES git_repo(id),
+    FOREIGN KEY(author_id) REFERENCES git_user(id)
+);
+
+CREATE INDEX IF NOT EXISTS git_commit_search ON git_commit USING GIN (to_tsvector('simple', message));
+
+CREATE TABLE IF NOT EXISTS pipeline_run (
+    id SERIAL PRIMARY KEY,
+    triggered_by VARCHAR(20) NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ NOT NULL,
+    from_date VARCHAR(10) NOT NULL DEFAULT '',
+    to_date VARCHAR(10) NOT NULL DEFAULT '',
+    terms TEXT NOT NULL DEFAULT '[]',
+    sources TEXT NOT NULL DEFAULT '[]',
+    fetched INTEGER NOT NULL DEFAULT 0,
+    saved INTEGER NOT NULL DEFAULT 0,
+    duplicates INTEGER NOT NULL DEFAULT 0,
+    rejected INTEGER NOT NULL DEFAULT 0,
+    errors TEXT NOT NULL DEFAULT '[]',
+    api_calls INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS search_history (
+    id SERIAL PRIMARY KEY,
+    run_id INTEGER NOT NULL,
+    term VARCHAR(50) NOT NULL,
+    searched_at TIMESTAMPTZ NOT NULL,
+    api_calls INTEGER NOT NULL,
+    saved INTEGER NOT NULL
+    -- No foreign key on run_id: the histories are kept, even if the run failed to save (run_id 0).
+);
+
+CREATE TABLE IF NOT EXISTS searchterm_state (
+    term VARCHAR(50) PRIMARY KEY,
+    last_searched_at TIMESTAMPTZ NOT NULL,
+    watermark TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS commit_label (
+    commit_id INTEGER PRIMARY KEY,
+    funny BOOLEAN NOT NULL,
+    labeled_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY(commit_id) REFERENCES git_commit(id)
+);
+
+CREATE TABLE IF NOT EXISTS humor_model (
+    id SERIAL PRIMARY KEY,
+    trained_at TIMESTAMPTZ NOT NULL,
+    examples INTEGER NOT NULL,
+    data TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+    id SERIAL PRIMARY KEY,
+    url VARCHAR(200) NOT NULL,
+    event VARCHAR(20) NOT NULL,
+    payload TEXT NOT NULL,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    delivered BOOLEAN NOT NULL DEFAULT FALSE,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- The migrations in sql/migrations that were applied.
+CREATE TABLE IF NOT EXISTS schema_migration (
+    name VARCHAR(100) PRIMARY KEY,
+    applied_at TIMESTAMPTZ NOT NULL
+);
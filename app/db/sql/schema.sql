@@ -0,0 +1,123 @@
+CREATE TABLE IF NOT EXISTS config_badword (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    text VARCHAR(50) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS config_blockword (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    text VARCHAR(50) UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS config_groupterm (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    text VARCHAR(50) NOT NULL,
+    groupname VARCHAR(50) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS config_searchterm (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    text VARCHAR(50) NOT NULL,
+    rank INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS git_user (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source INTEGER NOT NULL,
+    username VARCHAR(50) NOT NULL,
+    url VARCHAR(100) UNIQUE NOT NULL,
+    avatar_url VARCHAR(200) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS git_repo (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source INTEGER NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    description VARCHAR(100) NOT NULL,
+    url VARCHAR(200) UNIQUE NOT NULL
+);
+
+-- The original table. The columns added since are in migrations/000_commit_columns.sql,
+-- so the databases from before get them too.
+CREATE TABLE IF NOT EXISTS git_commit (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source INTEGER NOT NULL,
+    author_id INTEGER NOT NULL,
+    repo_id INTEGER NOT NULL,
+    message VARCHAR(500) NOT NULL,
+    message_censored VARCHAR(500) NOT NULL DEFAULT '',
+    sha VARCHAR(40) NOT NULL,
+    url VARCHAR(200) UNIQUE NOT NULL,
+    date DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    valid BOOL NOT NULL DEFAULT TRUE,
+    groupname VARCHAR(50) NOT NULL,
+    color_bg VARCHAR(10) NOT NULL,
+    color_fg VARCHAR(10) NOT NULL,
+    FOREIGN KEY(repo_id) REFERENCES git_repo(id),
+    FOREIGN KEY(author_id) REFERENCES git_user(id)
+);
+
+CREATE TABLE IF NOT EXISTS pipeline_run (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    triggered_by VARCHAR(20) NOT NULL,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME NOT NULL,
+    from_date VARCHAR(10) NOT NULL DEFAULT '',
+    to_date VARCHAR(10) NOT NULL DEFAULT '',
+    terms TEXT NOT NULL DEFAULT '[]',
+    sources TEXT NOT NULL DEFAULT '[]',
+    fetched INTEGER NOT NULL DEFAULT 0,
+    saved INTEGER NOT NULL DEFAULT 0,
+    duplicates INTEGER NOT NULL DEFAULT 0,
+    rejected INTEGER NOT NULL DEFAULT 0,
+    errors TEXT NOT NULL DEFAULT '[]',
+    api_calls INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS search_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id INTEGER NOT NULL,
+    term VARCHAR(50) NOT NULL,
+    searched_at DATETIME NOT NULL,
+    api_calls INTEGER NOT NULL,
+    saved INTEGER NOT NULL,
+    FOREIGN KEY(run_id) REFERENCES pipeline_run(id)
+);
+
+CREATE TABLE IF NOT EXISTS searchterm_state (
+    term VARCHAR(50) PRIMARY KEY,
+    last_searched_at DATETIME NOT NULL,
+    watermark DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS commit_label (
+    commit_id INTEGER PRIMARY KEY,
+    funny BOOL NOT NULL,
+    labeled_at DATETIME NOT NULL,
+    FOREIGN KEY(commit_id) REFERENCES git_commit(id)
+);
+
+CREATE TABLE IF NOT EXISTS humor_model (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    trained_at DATETIME NOT NULL,
+    examples INTEGER NOT NULL,
+    data TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    url VARCHAR(200) NOT NULL,
+    event VARCHAR(20) NOT NULL,
+    payload TEXT NOT NULL,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    delivered BOOL NOT NULL DEFAULT FALSE,
+    error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+
+-- The migrations in sql/migrations that were applied.
+CREATE TABLE IF NOT EXISTS schema_migration (
+    name VARCHAR(100) PRIMARY KEY,
+    applied_at DATETIME NOT NULL
+);
@@ -0,0 +1,17 @@
+-- The FTS5 index of the commit messages, kept in sync with git_commit by the triggers.
+-- It's only created when SQLite is built with FTS5 (the sqlite_fts5 build tag).
+
+CREATE VIRTUAL TABLE IF NOT EXISTS git_commit_fts USING fts5(message, content='git_commit', content_rowid='id');
+
+CREATE TRIGGER IF NOT EXISTS git_commit_fts_insert AFTER INSERT ON git_commit BEGIN
+    INSERT INTO git_commit_fts (rowid, message) VALUES (new.id, new.message);
+END;
+
+CREATE TRIGGER IF NOT EXISTS git_commit_fts_delete AFTER DELETE ON git_commit BEGIN
+    INSERT INTO git_commit_fts (git_commit_fts, rowid, message) VALUES ('delete', old.id, old.message);
+END;
+
+CREATE TRIGGER IF NOT EXISTS git_commit_fts_update AFTER UPDATE OF message ON git_commit BEGIN
+    INSERT INTO git_commit_fts (git_commit_fts, rowid, message) VALUES ('delete', old.id, old.message);
+    INSERT INTO git_commit_fts (rowid, message) VALUES (new.id, new.message);
+END;
@@ -3,33 +3,118 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"math/rand"
+	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3" // sqlite
 
+	"github.com/tunedmystic/commits.lol/app/config"
 	"github.com/tunedmystic/commits.lol/app/models"
+	"github.com/tunedmystic/commits.lol/app/utils"
 )
 
 // SqliteDB is an sqlite-backed type that implements the Database interface.
 type SqliteDB struct {
 	DB *sqlx.DB
+
+	// Whether the commit messages have an FTS5 index.
+	fts bool
 }
 
 // NewSqliteDB connects to the database, and returns a new *SqliteDB type.
+// The tables are created if they don't exist, along with the default terms if the
+// database is new. The name can be a file, or :memory: for a temporary database.
 func NewSqliteDB(name string) SqliteDB {
 	sdb := SqliteDB{
-		DB: sqlx.MustConnect("sqlite3", name),
+		DB: sqlx.MustConnect("sqlite3", sqliteDSN(name)),
+	}
+
+	// Every connection to an in-memory database gets its own database, so keep to one.
+	if strings.HasPrefix(name, ":memory:") || strings.Contains(name, "mode=memory") {
+		sdb.DB.SetMaxOpenConns(1)
+	}
+
+	if err := createSchema(sdb.DB, "sql/schema.sql", sqliteTablesQuery); err != nil {
+		panic(err)
 	}
+
+	fts, err := createSearchIndex(sdb.DB)
+	if err != nil {
+		panic(err)
+	}
+	sdb.fts = fts
+
 	return sdb
 }
 
+// sqliteTablesQuery counts the tables of the database.
+const sqliteTablesQuery = `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table';`
+
+// createSearchIndex creates the FTS5 index of the commit messages, and fills it if it's new.
+// Returns false if SQLite wasn't built with FTS5. The triggers that keep the index in sync
+// are dropped then, as they can't write to it, and the index is rebuilt once FTS5 is back.
+func createSearchIndex(db *sqlx.DB) (bool, error) {
+	enabled := false
+	if err := db.Get(&enabled, `SELECT sqlite_compileoption_used('ENABLE_FTS5');`); err != nil {
+		return false, fmt.Errorf("db:createSearchIndex: %v", err)
+	}
+
+	triggers := []string{"git_commit_fts_insert", "git_commit_fts_delete", "git_commit_fts_update"}
+
+	if !enabled {
+		for _, trigger := range triggers {
+			if _, err := db.Exec(`DROP TRIGGER IF EXISTS ` + trigger + `;`); err != nil {
+				return false, fmt.Errorf("db:createSearchIndex: %v", err)
+			}
+		}
+		return false, nil
+	}
+
+	existing := 0
+	query := `SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name IN (?, ?, ?);`
+	if err := db.Get(&existing, query, triggers[0], triggers[1], triggers[2]); err != nil {
+		return false, fmt.Errorf("db:createSearchIndex: %v", err)
+	}
+
+	search, err := sqlFiles.ReadFile("sql/search.sqlite.sql")
+	if err != nil {
+		return false, fmt.Errorf("db:createSearchIndex: %v", err)
+	}
+
+	if _, err := db.Exec(string(search)); err != nil {
+		return false, fmt.Errorf("db:createSearchIndex: %v", err)
+	}
+
+	// The index is out of sync, if any of the triggers was missing.
+	if existing < len(triggers) {
+		if _, err := db.Exec(`INSERT INTO git_commit_fts (git_commit_fts) VALUES ('rebuild');`); err != nil {
+			return false, fmt.Errorf("db:createSearchIndex: %v", err)
+		}
+	}
+
+	return true, nil
+}
+
+// sqliteDSN adds the connection options for concurrent writers to the database name.
+// Writers wait for the lock instead of failing with "database is locked", and
+// transactions take the write lock upfront, so they can't deadlock each other.
+func sqliteDSN(name string) string {
+	separator := "?"
+	if strings.Contains(name, "?") {
+		separator = "&"
+	}
+	return name + separator + "_busy_timeout=10000&_txlock=immediate"
+}
+
 // Close the database connection.
 func (s *SqliteDB) Close() {
 	s.DB.Close()
 }
 
 // ------------------------------------------------------------------
-// Methods to modify config-related tables (BadWWord, GroupTerm, SearchTerm)
+// Methods to modify config-related tables (BadWWord, BlockWord, GroupTerm, SearchTerm)
 
 // AllBadWords returns all the bad words.
 func (s *SqliteDB) AllBadWords() (models.BadWords, error) {
@@ -42,6 +127,39 @@ func (s *SqliteDB) AllBadWords() (models.BadWords, error) {
 	return models.BadWords(values), nil
 }
 
+// AllBlockWords returns all the block words.
+func (s *SqliteDB) AllBlockWords() (models.BlockWords, error) {
+	values := []models.BlockWord{}
+
+	if err := s.DB.Select(&values, `SELECT * FROM config_blockword ORDER BY text;`); err != nil {
+		return nil, err
+	}
+
+	return models.BlockWords(values), nil
+}
+
+// CreateBlockWord inserts a new BlockWord row.
+func (s *SqliteDB) CreateBlockWord(word *models.BlockWord) error {
+	query := `INSERT INTO config_blockword ("text") VALUES (:text);`
+
+	row, err := s.DB.NamedExec(query, word)
+	if err != nil {
+		return fmt.Errorf("error inserting block word: %v", err)
+	}
+
+	id, _ := row.LastInsertId()
+	word.ID = int(id)
+	return nil
+}
+
+// DeleteBlockWord deletes the BlockWord with the given ID.
+func (s *SqliteDB) DeleteBlockWord(ID int) error {
+	if _, err := s.DB.Exec(`DELETE FROM config_blockword WHERE id = ?;`, ID); err != nil {
+		return fmt.Errorf("error deleting block word: %v", err)
+	}
+	return nil
+}
+
 // AllGroupTerms returns all the group terms.
 func (s *SqliteDB) AllGroupTerms() (models.GroupTerms, error) {
 	values := []models.GroupTerm{}
@@ -53,65 +171,88 @@ func (s *SqliteDB) AllGroupTerms() (models.GroupTerms, error) {
 	return models.GroupTerms(values), nil
 }
 
-// randomSearchTermsByRank returns a list of randomly selected terms of a specified rank.
-func (s *SqliteDB) randomSearchTermsByRank(rank, amount int) (models.SearchTerms, error) {
-	terms := make(models.SearchTerms, 0, amount)
-	query := `SELECT * FROM config_searchterm WHERE rank = ? ORDER BY random() LIMIT ?;`
+// AllSearchTerms returns all the search terms.
+func (s *SqliteDB) AllSearchTerms() (models.SearchTerms, error) {
+	values := []models.SearchTerm{}
 
-	if err := s.DB.Select(&terms, query, rank, amount); err != nil {
+	if err := s.DB.Select(&values, `SELECT * FROM config_searchterm ORDER BY rank, id;`); err != nil {
 		return nil, err
 	}
-	return terms, nil
+
+	return models.SearchTerms(values), nil
 }
 
-// RandomSearchTerms returns a list of randomly selected terms of predetermined rank.
-func (s *SqliteDB) RandomSearchTerms() (models.SearchTerms, error) {
-	rank1Amount := 8
-	rank2Amount := 4
-	rank3Amount := 4
-	rank4Amount := 2
-	totalTerms := rank1Amount + rank2Amount + rank3Amount + rank4Amount
-	terms := make(models.SearchTerms, 0, totalTerms)
+// RandomSearchTerms returns a list of randomly selected terms.
+// The terms that went the longest without being searched are picked first,
+// and then sampled by their recent yield.
+func (s *SqliteDB) RandomSearchTerms() (models.SearchTermYields, error) {
+	amount := 18
+	since := time.Now().UTC().AddDate(0, 0, -config.App.TermYieldDays)
 
-	// Get terms of Rank 1.
-	t1, err := s.randomSearchTermsByRank(1, rank1Amount)
+	yields, err := s.SearchTermYields(since)
 	if err != nil {
 		return nil, fmt.Errorf("db:RandomSearchTerms: %v", err)
 	}
-	terms = append(terms, t1...)
 
-	// Get terms of Rank 2.
-	t2, err := s.randomSearchTermsByRank(2, rank2Amount)
-	if err != nil {
-		return nil, fmt.Errorf("db:RandomSearchTerms: %v", err)
-	}
-	terms = append(terms, t2...)
+	return yields.Stalest(amount*2).WeightedSample(amount, rand.Float64), nil
+}
 
-	// Get terms of Rank 3.
-	t3, err := s.randomSearchTermsByRank(3, rank3Amount)
-	if err != nil {
-		return nil, fmt.Errorf("db:RandomSearchTerms: %v", err)
+// SearchTermYields returns every search term, with its search results since the given time.
+func (s *SqliteDB) SearchTermYields(since time.Time) (models.SearchTermYields, error) {
+	yields := models.SearchTermYields{}
+
+	query := `
+		SELECT
+			t.id,
+			t.text,
+			t.rank,
+			COUNT(h.id) AS searches,
+			COALESCE(SUM(h.api_calls), 0) AS api_calls,
+			COALESCE(SUM(h.saved), 0) AS saved,
+			st.last_searched_at,
+			st.watermark
+		FROM config_searchterm t
+		LEFT JOIN search_history h ON h.term = t.text AND h.searched_at > ?
+		LEFT JOIN searchterm_state st ON st.term = t.text
+		GROUP BY t.id
+		ORDER BY t.rank, t.id;`
+
+	if err := s.DB.Select(&yields, query, since); err != nil {
+		return nil, err
 	}
-	terms = append(terms, t3...)
 
-	// Get terms of Rank 4.
-	t4, err := s.randomSearchTermsByRank(4, rank4Amount)
-	if err != nil {
-		return nil, fmt.Errorf("db:RandomSearchTerms: %v", err)
+	return yields, nil
+}
+
+// UpdateSearchTermRank ...
+func (s *SqliteDB) UpdateSearchTermRank(ID, rank int) error {
+	if _, err := s.DB.Exec(`UPDATE config_searchterm SET rank = ? WHERE id = ?;`, rank, ID); err != nil {
+		return fmt.Errorf("error updating search term: %v", err)
 	}
-	terms = append(terms, t4...)
 
-	return terms, nil
+	return nil
 }
 
 // ------------------------------------------------------------------
 // Methods to modify git-related tables (GitCommit, GitRepo, GitUser)
 
-// AllCommits returns all the commits.
+// AllCommits returns all the commits, with their Author.
 func (s *SqliteDB) AllCommits() (models.GitCommits, error) {
 	commits := make(models.GitCommits, 0, 1000)
 
-	if err := s.DB.Select(&commits, `SELECT * FROM git_commit;`); err != nil {
+	query := `
+		SELECT
+			c.*,
+			u.id AS "author.id",
+			u.source AS "author.source",
+			u.username AS "author.username",
+			u.url AS "author.url",
+			u.avatar_url AS "author.avatar_url"
+		FROM git_commit c
+		INNER JOIN git_user u ON u.id = c.author_id
+		ORDER BY c.id;`
+
+	if err := s.DB.Select(&commits, query); err != nil {
 		return nil, err
 	}
 
@@ -125,176 +266,601 @@ func (s *SqliteDB) UpdateCommit(commit *models.GitCommit) error {
 		SET
 			source = :source, author_id = :author_id, repo_id = :repo_id,
 			message = :message, message_censored = :message_censored,
+			body = :body, body_censored = :body_censored,
 			sha = :sha, url = :url, date = :date, created_at = :created_at,
-			valid = :valid, groupname = :groupname,
-			color_bg = :color_bg, color_fg = :color_fg
+			valid = :valid, groupname = :groupname, lang = :lang, score = :score,
+			color_bg = :color_bg, color_fg = :color_fg,
+			message_hash = :message_hash, simhash = :simhash,
+			canonical_id = :canonical_id
 		WHERE id = :id;`
 
-	_, err := s.DB.NamedExec(query, commit)
-
+	tx, err := s.DB.Beginx()
 	if err != nil {
 		return fmt.Errorf("error inserting commit: %v", err)
 	}
 
-	return nil
+	if _, err := tx.NamedExec(query, commit); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error inserting commit: %v", err)
+	}
+
+	// The bands follow the simhash, which changes with the message.
+	if err := saveBands(tx, commit.ID, commit.Simhash); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error inserting commit: %v", err)
+	}
+
+	return tx.Commit()
 }
 
-// RecentCommitsByGroup returns the most recent commits.
-func (s *SqliteDB) RecentCommitsByGroup(group string) (models.GitCommits, error) {
-	length := 33
-	commits := make(models.GitCommits, 0, length)
+// RecentCommitsByGroup returns a batch of random commits, from the last RecentDays,
+// or from older commits when the recent ones are too few.
+// The commits are filtered by group and language, if they're not empty.
+func (s *SqliteDB) RecentCommitsByGroup(group, lang string) (models.GitCommits, error) {
+	return recentCommitsByGroup(func(n int) (models.GitCommits, error) {
+		return sampleCommits(s.DB, group, lang, n)
+	})
+}
+
+// GetCommit returns the valid commit with the given ID, with its Author and Repo.
+// Returns nil if there's no such commit.
+func (s *SqliteDB) GetCommit(ID int) (*models.GitCommit, error) {
+	commit := models.GitCommit{}
 
 	query := `
 		SELECT
-			c.id,
-			c.author_id,
-			c.repo_id,
-			c.message,
-			c.message_censored,
-			c.url,
-			c.color_bg,
-			c.color_fg,
-
+			c.*,
 			u.id AS "author.id",
+			u.source AS "author.source",
+			u.username AS "author.username",
 			u.url AS "author.url",
-			u.avatar_url AS "author.avatar_url"
-
+			u.avatar_url AS "author.avatar_url",
+			r.id AS "repo.id",
+			r.source AS "repo.source",
+			r.name AS "repo.name",
+			r.description AS "repo.description",
+			r.url AS "repo.url"
 		FROM git_commit c
-		INNER JOIN git_user u on u.id = c.author_id
-		WHERE (
-			c.date > datetime('now', '-14 days') AND
-			c.valid = TRUE AND
-			c.id % abs(random() % 10) = 0 AND
-			(
-				($1 != '' AND c.groupname = $1)
-				OR
-				($1 = '' AND c.groupname IS NOT NULL)
-			)
-		)
-		ORDER BY random()
-		LIMIT $2;`
-
-	rows, err := s.DB.Queryx(query, group, length)
+		INNER JOIN git_user u ON u.id = c.author_id
+		INNER JOIN git_repo r ON r.id = c.repo_id
+		WHERE c.id = ? AND c.valid = TRUE;`
 
+	err := s.DB.Get(&commit, query, ID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var c models.GitCommit
-		err := rows.StructScan(&c)
+	return &commit, nil
+}
+
+// SearchCommits returns a page of the valid commits with every word of the query, newest first.
+// The words are matched with the FTS5 index if there's one, or anywhere in the messages otherwise.
+func (s *SqliteDB) SearchCommits(query string, filters models.SearchFilters, page int) (models.SearchResults, error) {
+	words := models.SearchWords(query)
+	if len(words) == 0 {
+		return models.SearchResults{Query: query, Page: 1, Commits: models.GitCommits{}}, nil
+	}
+
+	if s.fts {
+		match := `c.id IN (SELECT rowid FROM git_commit_fts WHERE git_commit_fts MATCH ?)`
+		return searchCommits(s.DB, match, []interface{}{ftsQuery(words)}, query, filters, page)
+	}
+
+	clauses := []string{}
+	args := []interface{}{}
+	for _, word := range words {
+		clauses = append(clauses, `c.message LIKE ? ESCAPE '\'`)
+		args = append(args, likePattern(word))
+	}
+
+	return searchCommits(s.DB, strings.Join(clauses, " AND "), args, query, filters, page)
+}
+
+// GetOrCreateUser is a convenience method to get the provided User,
+// or create it if it doesn't exist.
+func (s *SqliteDB) GetOrCreateUser(user *models.GitUser) error {
+	query := `
+		INSERT INTO git_user ("source", "username", "url", "avatar_url")
+		VALUES (:source, :username, :url, :avatar_url)
+		ON CONFLICT ("url") DO NOTHING;`
+
+	if _, err := s.DB.NamedExec(query, user); err != nil {
+		return fmt.Errorf("error inserting user: %v", err)
+	}
+
+	return s.DB.Get(&user.ID, `SELECT id FROM git_user WHERE url = ?;`, user.URL)
+}
+
+// GetOrCreateRepo is a convenience method to get the provided Repo,
+// or create it if it doesn't exist.
+func (s *SqliteDB) GetOrCreateRepo(repo *models.GitRepo) error {
+	query := `
+		INSERT INTO git_repo ("source", "name", "description", "url")
+		VALUES (:source, :name, :description, :url)
+		ON CONFLICT ("url") DO NOTHING;`
+
+	if _, err := s.DB.NamedExec(query, repo); err != nil {
+		return fmt.Errorf("error inserting repo: %v", err)
+	}
+
+	return s.DB.Get(&repo.ID, `SELECT id FROM git_repo WHERE url = ?;`, repo.URL)
+}
+
+// GetOrCreateCommit is a convenience method to get the provided Commit,
+// or create it if it doesn't exist. The same message by the same author,
+// or the same url, is the same commit.
+// Returns true if the Commit was created.
+func (s *SqliteDB) GetOrCreateCommit(commit *models.GitCommit) (bool, error) {
+	result, err := s.DB.NamedExec(sqliteCommitInsert+" ON CONFLICT DO NOTHING;", commit)
+	if err != nil {
+		return false, fmt.Errorf("error inserting commit: %v", err)
+	}
+
+	if n, _ := result.RowsAffected(); n > 0 {
+		id, _ := result.LastInsertId()
+		commit.ID = int(id)
+		return true, saveBands(s.DB, commit.ID, commit.Simhash)
+	}
+
+	query := `SELECT id FROM git_commit WHERE (author_id = ? AND message = ?) OR url = ? ORDER BY id LIMIT 1;`
+	return false, s.DB.Get(&commit.ID, query, commit.AuthorID, commit.Message, commit.URL)
+}
+
+// sqliteCommitInsert inserts a GitCommit row. It's completed with a
+// conflict clause by the callers.
+const sqliteCommitInsert = `
+	INSERT INTO git_commit (
+		"source", "author_id", "repo_id", "message", "message_censored",
+		"body", "body_censored", "sha", "url", "date", "created_at",
+		"valid", "groupname", "lang", "score",
+		"color_bg", "color_fg", "message_hash", "simhash", "canonical_id"
+	)
+	VALUES (
+		:source, :author_id, :repo_id, :message, :message_censored,
+		:body, :body_censored, :sha, :url, :date, :created_at,
+		:valid, :groupname, :lang, :score,
+		:color_bg, :color_fg, :message_hash, :simhash, :canonical_id
+	)`
+
+// SaveCommitBatch saves the commits, along with their Author and Repo, in a single transaction.
+// Users, repos and commits that already exist are left untouched.
+// Returns the commits that were created.
+func (s *SqliteDB) SaveCommitBatch(commits models.GitCommits) (models.GitCommits, error) {
+	created := models.GitCommits{}
+
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("db:SaveCommitBatch: %v", err)
+	}
+
+	for _, commit := range commits {
+		ok, err := saveCommit(tx, &commit)
 		if err != nil {
-			return nil, err
+			tx.Rollback()
+			return nil, fmt.Errorf("db:SaveCommitBatch: %v", err)
+		}
+		if ok {
+			created = append(created, commit)
 		}
-		commits = append(commits, c)
 	}
 
-	return commits, nil
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("db:SaveCommitBatch: %v", err)
+	}
+
+	return created, nil
 }
 
-// createUser inserts a new User row and returns the ID.
-func (s *SqliteDB) createUser(user *models.GitUser) error {
-	query := `
+// saveCommit upserts the commit's Author and Repo, and inserts the commit
+// if it doesn't exist yet. Returns true if the commit was created.
+func saveCommit(tx *sqlx.Tx, commit *models.GitCommit) (bool, error) {
+	userQuery := `
 		INSERT INTO git_user ("source", "username", "url", "avatar_url")
-		VALUES (:source, :username, :url, :avatar_url);`
+		VALUES (:source, :username, :url, :avatar_url)
+		ON CONFLICT ("url") DO NOTHING;`
+
+	if _, err := tx.NamedExec(userQuery, commit.Author); err != nil {
+		return false, fmt.Errorf("error inserting user: %v", err)
+	}
+	if err := tx.Get(&commit.AuthorID, `SELECT id FROM git_user WHERE url = ?;`, commit.Author.URL); err != nil {
+		return false, err
+	}
+
+	repoQuery := `
+		INSERT INTO git_repo ("source", "name", "description", "url")
+		VALUES (:source, :name, :description, :url)
+		ON CONFLICT ("url") DO NOTHING;`
+
+	if _, err := tx.NamedExec(repoQuery, commit.Repo); err != nil {
+		return false, fmt.Errorf("error inserting repo: %v", err)
+	}
+	if err := tx.Get(&commit.RepoID, `SELECT id FROM git_repo WHERE url = ?;`, commit.Repo.URL); err != nil {
+		return false, err
+	}
+
+	commit.Author.ID = commit.AuthorID
+	commit.Repo.ID = commit.RepoID
+
+	// The same message by the same author is a duplicate, even in another repo.
+	err := tx.Get(&commit.ID, `SELECT id FROM git_commit WHERE author_id = ? AND message = ? ORDER BY id LIMIT 1;`, commit.AuthorID, commit.Message)
+	if err == nil {
+		return false, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, err
+	}
 
-	row, err := s.DB.NamedExec(query, user)
+	if err := findCanonical(tx, commit); err != nil {
+		return false, fmt.Errorf("error finding canonical commit: %v", err)
+	}
 
+	// The url, or the author and message, can be saved by another batch in the meantime.
+	result, err := tx.NamedExec(sqliteCommitInsert+" ON CONFLICT DO NOTHING;", commit)
 	if err != nil {
-		return fmt.Errorf("error inserting user: %v", err)
+		return false, fmt.Errorf("error inserting commit: %v", err)
 	}
 
-	id, _ := row.LastInsertId()
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	id, _ := result.LastInsertId()
+	commit.ID = int(id)
+	return true, saveBands(tx, commit.ID, commit.Simhash)
+}
+
+// findCanonical looks for an earlier, valid commit with the same or a similar message,
+// and sets it as the commit's canonical commit. Saved commits are only compared to
+// the commits that were created before them.
+// The queries are rebound for the transaction's driver, so every backend shares it.
+func findCanonical(tx *sqlx.Tx, commit *models.GitCommit) error {
+	commit.CanonicalID = sql.NullInt64{}
+
+	if !commit.Valid || commit.MessageHash == "" {
+		return nil
+	}
+
+	// Look for the same normalized message.
+	query := `
+		SELECT id FROM git_commit
+		WHERE
+			message_hash = ? AND
+			valid = TRUE AND
+			canonical_id IS NULL AND
+			(? = 0 OR id < ?)
+		ORDER BY id
+		LIMIT 1;`
+
+	var canonicalID int64
+	err := tx.Get(&canonicalID, tx.Rebind(query), commit.MessageHash, commit.ID, commit.ID)
+	if err == nil {
+		commit.CanonicalID = sql.NullInt64{Int64: canonicalID, Valid: true}
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	if commit.Simhash == 0 {
+		return nil
+	}
+
+	// Look for a similar message. Near-duplicates share at least one band of their simhash,
+	// so the indexed bands narrow down the candidates, before comparing the whole simhash.
+	args := []interface{}{}
+	for _, key := range bandKeys(commit.Simhash) {
+		args = append(args, key)
+	}
+	args = append(args, commit.ID, commit.ID)
+
+	query = fmt.Sprintf(`
+		SELECT id, simhash FROM git_commit
+		WHERE
+			id IN (SELECT commit_id FROM git_commit_band WHERE band IN (%s)) AND
+			valid = TRUE AND
+			canonical_id IS NULL AND
+			(? = 0 OR id < ?)
+		ORDER BY id;`, strings.TrimSuffix(strings.Repeat("?, ", len(args)-2), ", "))
+
+	candidates := models.GitCommits{}
+	if err := tx.Select(&candidates, tx.Rebind(query), args...); err != nil {
+		return err
+	}
+
+	// Pick the closest candidate, or the oldest one if there's a tie.
+	closest := utils.SimhashThreshold + 1
+	for _, candidate := range candidates {
+		if distance := commit.SimhashDistance(candidate); distance < closest {
+			closest = distance
+			commit.CanonicalID = sql.NullInt64{Int64: int64(candidate.ID), Valid: true}
+		}
+	}
 
-	user.ID = int(id)
 	return nil
 }
 
-// GetOrCreateUser is a convenience method to get the provided User,
-// or create it if it doesn't exist.
-func (s *SqliteDB) GetOrCreateUser(user *models.GitUser) error {
-	query := `SELECT id FROM git_user WHERE url = ?;`
+// bandKeys returns the bands of the simhash, as they're stored in the git_commit_band table.
+// The position of a band is part of its key, so only the same band matches.
+func bandKeys(simhash int64) []int {
+	keys := []int{}
+	for i, band := range utils.SimhashBands(uint64(simhash)) {
+		keys = append(keys, i*256+int(band))
+	}
+	return keys
+}
 
-	err := s.DB.QueryRow(query, user.URL).Scan(&user.ID)
+// saveBands replaces the bands of the commit in the git_commit_band table.
+// A commit without a simhash has no bands.
+// The queries are rebound for the driver, so every backend shares it.
+func saveBands(e sqlx.Ext, commitID int, simhash int64) error {
+	if _, err := e.Exec(e.Rebind(`DELETE FROM git_commit_band WHERE commit_id = ?;`), commitID); err != nil {
+		return fmt.Errorf("error deleting bands: %v", err)
+	}
 
-	if err == sql.ErrNoRows {
-		return s.createUser(user)
+	if simhash == 0 {
+		return nil
+	}
+
+	values := []string{}
+	args := []interface{}{}
+	for _, key := range bandKeys(simhash) {
+		values = append(values, "(?, ?)")
+		args = append(args, commitID, key)
+	}
+
+	query := `INSERT INTO git_commit_band (commit_id, band) VALUES ` + strings.Join(values, ", ") + `;`
+	if _, err := e.Exec(e.Rebind(query), args...); err != nil {
+		return fmt.Errorf("error inserting bands: %v", err)
 	}
 
-	return err
+	return nil
 }
 
-// createRepo inserts a new Repo row and returns the ID.
-func (s *SqliteDB) createRepo(repo *models.GitRepo) error {
+// DedupeCommits recomputes the message hashes of all the commits, and clusters the
+// near-duplicates under their canonical commit, in a single transaction.
+// Returns the number of duplicate commits.
+func (s *SqliteDB) DedupeCommits() (int, error) {
+	commits, err := s.AllCommits()
+	if err != nil {
+		return 0, fmt.Errorf("db:DedupeCommits: %v", err)
+	}
+
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("db:DedupeCommits: %v", err)
+	}
+
 	query := `
-		INSERT INTO git_repo ("source", "name", "description", "url")
-		VALUES (:source, :name, :description, :url);`
+		UPDATE git_commit
+		SET message_hash = :message_hash, simhash = :simhash, canonical_id = :canonical_id
+		WHERE id = :id;`
+
+	duplicates := 0
+	for _, commit := range commits {
+		commit.SetMessageHash()
+
+		if err := findCanonical(tx, &commit); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("db:DedupeCommits: %v", err)
+		}
+		if commit.CanonicalID.Valid {
+			duplicates++
+		}
+
+		if _, err := tx.NamedExec(query, commit); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("db:DedupeCommits: %v", err)
+		}
+		if err := saveBands(tx, commit.ID, commit.Simhash); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("db:DedupeCommits: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("db:DedupeCommits: %v", err)
+	}
+
+	return duplicates, nil
+}
+
+// ------------------------------------------------------------------
+// Methods to modify pipeline-related tables (PipelineRun)
+
+// CreatePipelineRun inserts a new PipelineRun row and sets the ID.
+func (s *SqliteDB) CreatePipelineRun(run *models.PipelineRun) error {
+	query := `
+		INSERT INTO pipeline_run (
+			"triggered_by", "started_at", "finished_at", "from_date", "to_date",
+			"terms", "sources", "fetched", "saved", "duplicates", "rejected",
+			"errors", "api_calls"
+		)
+		VALUES (
+			:triggered_by, :started_at, :finished_at, :from_date, :to_date,
+			:terms, :sources, :fetched, :saved, :duplicates, :rejected,
+			:errors, :api_calls
+		);`
 
-	row, err := s.DB.NamedExec(query, repo)
+	row, err := s.DB.NamedExec(query, run)
 
 	if err != nil {
-		return fmt.Errorf("error inserting repo: %v", err)
+		return fmt.Errorf("error inserting pipeline run: %v", err)
 	}
 
 	id, _ := row.LastInsertId()
 
-	repo.ID = int(id)
+	run.ID = int(id)
 	return nil
 }
 
-// GetOrCreateRepo is a convenience method to get the provided Repo,
-// or create it if it doesn't exist.
-func (s *SqliteDB) GetOrCreateRepo(repo *models.GitRepo) error {
-	query := `SELECT id FROM git_repo WHERE url = ?;`
+// RecentPipelineRuns returns the most recent pipeline runs, newest first.
+func (s *SqliteDB) RecentPipelineRuns(limit int) (models.PipelineRuns, error) {
+	runs := make(models.PipelineRuns, 0, limit)
 
-	err := s.DB.QueryRow(query, repo.URL).Scan(&repo.ID)
+	query := `SELECT * FROM pipeline_run ORDER BY started_at DESC, id DESC LIMIT ?;`
 
-	if err == sql.ErrNoRows {
-		return s.createRepo(repo)
+	if err := s.DB.Select(&runs, query, limit); err != nil {
+		return nil, err
 	}
 
-	return err
+	return runs, nil
 }
 
-// createCommit inserts a new Commit row and returns the ID.
-func (s *SqliteDB) createCommit(commit *models.GitCommit) error {
+// CreateSearchHistories inserts the SearchHistory rows in a single transaction.
+func (s *SqliteDB) CreateSearchHistories(histories models.SearchHistories) error {
 	query := `
-		INSERT INTO git_commit (
-			"source", "author_id", "repo_id", "message", "message_censored",
-			"sha", "url", "date", "created_at", "valid", "groupname",
-			"color_bg", "color_fg"
+		INSERT INTO search_history ("run_id", "term", "searched_at", "api_calls", "saved")
+		VALUES (:run_id, :term, :searched_at, :api_calls, :saved);`
+
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	for _, history := range histories {
+		if _, err := tx.NamedExec(query, history); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error inserting search history: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdateSearchTermStates upserts the SearchTermState rows in a single transaction.
+// The watermark of a term only ever moves forward.
+func (s *SqliteDB) UpdateSearchTermStates(states models.SearchTermStates) error {
+	query := `
+		INSERT INTO searchterm_state ("term", "last_searched_at", "watermark")
+		VALUES (:term, :last_searched_at, :watermark)
+		ON CONFLICT ("term") DO UPDATE SET
+			last_searched_at = excluded.last_searched_at,
+			watermark = CASE
+				WHEN watermark IS NULL OR excluded.watermark > watermark THEN excluded.watermark
+				ELSE watermark
+			END;`
+
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return err
+	}
+
+	for _, state := range states {
+		if _, err := tx.NamedExec(query, state); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error updating search term state: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ------------------------------------------------------------------
+// Methods to modify webhook-related tables (WebhookDelivery)
+
+// CreateWebhookDelivery inserts a new WebhookDelivery row and sets the ID.
+func (s *SqliteDB) CreateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_delivery (
+			"url", "event", "payload", "status_code", "attempts",
+			"delivered", "error", "created_at"
 		)
 		VALUES (
-			:source, :author_id, :repo_id, :message, :message_censored,
-			:sha, :url, :date, :created_at, :valid, :groupname,
-			:color_bg, :color_fg
+			:url, :event, :payload, :status_code, :attempts,
+			:delivered, :error, :created_at
 		);`
 
-	row, err := s.DB.NamedExec(query, commit)
-
+	row, err := s.DB.NamedExec(query, delivery)
 	if err != nil {
-		return fmt.Errorf("error inserting commit: %v", err)
+		return fmt.Errorf("error inserting webhook delivery: %v", err)
 	}
 
 	id, _ := row.LastInsertId()
+	delivery.ID = int(id)
+	return nil
+}
+
+// RecentWebhookDeliveries returns the most recent webhook deliveries, newest first.
+func (s *SqliteDB) RecentWebhookDeliveries(limit int) (models.WebhookDeliveries, error) {
+	deliveries := make(models.WebhookDeliveries, 0, limit)
+
+	query := `SELECT * FROM webhook_delivery ORDER BY id DESC LIMIT ?;`
+
+	if err := s.DB.Select(&deliveries, query, limit); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// ------------------------------------------------------------------
+// Methods to modify humor-related tables (CommitLabel, HumorModel)
+
+// LabelCommit records whether the commit is funny, replacing its previous label.
+func (s *SqliteDB) LabelCommit(commitID int, funny bool) error {
+	query := `
+		INSERT INTO commit_label ("commit_id", "funny", "labeled_at")
+		VALUES (?, ?, ?)
+		ON CONFLICT ("commit_id") DO UPDATE SET
+			funny = excluded.funny,
+			labeled_at = excluded.labeled_at;`
+
+	if _, err := s.DB.Exec(query, commitID, funny, time.Now().UTC()); err != nil {
+		return fmt.Errorf("error labeling commit: %v", err)
+	}
 
-	commit.ID = int(id)
 	return nil
 }
 
-// GetOrCreateCommit is a convenience method to get the provided Commit,
-// or create it if it doesn't exist.
-func (s *SqliteDB) GetOrCreateCommit(commit *models.GitCommit) error {
-	query := `SELECT id FROM git_commit WHERE author_id = ? AND message = ?;`
+// CommitLabels returns all the labels, with the message of the labeled commit.
+func (s *SqliteDB) CommitLabels() (models.CommitLabels, error) {
+	labels := models.CommitLabels{}
+
+	query := `
+		SELECT l.commit_id, l.funny, l.labeled_at, c.message
+		FROM commit_label l
+		INNER JOIN git_commit c ON c.id = l.commit_id
+		ORDER BY l.commit_id;`
 
-	err := s.DB.QueryRow(query, commit.AuthorID, commit.Message).Scan(&commit.ID)
+	if err := s.DB.Select(&labels, query); err != nil {
+		return nil, err
+	}
+
+	return labels, nil
+}
+
+// SaveHumorModel inserts a new HumorModel row.
+func (s *SqliteDB) SaveHumorModel(model *models.HumorModel) error {
+	query := `
+		INSERT INTO humor_model ("trained_at", "examples", "data")
+		VALUES (:trained_at, :examples, :data);`
+
+	row, err := s.DB.NamedExec(query, model)
+	if err != nil {
+		return fmt.Errorf("error inserting humor model: %v", err)
+	}
 
+	id, _ := row.LastInsertId()
+	model.ID = int(id)
+	return nil
+}
+
+// LatestHumorModel returns the most recently trained model, or nil if none was trained yet.
+func (s *SqliteDB) LatestHumorModel() (*models.HumorModel, error) {
+	model := models.HumorModel{}
+
+	err := s.DB.Get(&model, `SELECT * FROM humor_model ORDER BY id DESC LIMIT 1;`)
 	if err == sql.ErrNoRows {
-		return s.createCommit(commit)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return err
+	return &model, nil
 }
 
 // ------------------------------------------------------------------
@@ -1,7 +1,15 @@
 package db
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tunedmystic/commits.lol/app/models"
+	u "github.com/tunedmystic/commits.lol/app/utils"
 )
 
 func Test_Something(t *testing.T) {
@@ -9,3 +17,235 @@ func Test_Something(t *testing.T) {
 		t.Error("something went wrong")
 	}
 }
+
+func Test_NewSqliteDB(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "db")
+	defer os.RemoveAll(dir)
+
+	// A new database gets the tables, and the default terms.
+	s := NewSqliteDB(filepath.Join(dir, "test.sqlite"))
+	terms, err := s.AllSearchTerms()
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(terms) > 0, true)
+
+	badWords, _ := s.AllBadWords()
+	u.AssertEqual(t, len(badWords) > 0, true)
+
+	groupTerms, _ := s.AllGroupTerms()
+	u.AssertEqual(t, len(groupTerms) > 0, true)
+
+	// The terms are left alone when the database is opened again.
+	u.AssertEqual(t, s.UpdateSearchTermRank(terms[0].ID, models.RankDead), nil)
+	s.DB.MustExec(`DELETE FROM config_badword;`)
+	s.Close()
+
+	s = NewSqliteDB(filepath.Join(dir, "test.sqlite"))
+	defer s.Close()
+
+	reopened, _ := s.AllSearchTerms()
+	u.AssertEqual(t, len(reopened), len(terms))
+	u.AssertEqual(t, reopened[len(reopened)-1].Rank, models.RankDead)
+
+	badWords, _ = s.AllBadWords()
+	u.AssertEqual(t, len(badWords), 0)
+}
+
+func Test_NewSqliteDB_memory(t *testing.T) {
+	s := NewSqliteDB(":memory:")
+	defer s.Close()
+
+	created, err := s.SaveCommitBatch(models.GitCommits{testCommit("alice", "gems", "fixed a bug")})
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(created), 1)
+
+	// Every query sees the same in-memory database.
+	commits, err := s.AllCommits()
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(commits), 1)
+}
+
+func Test_migrate(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "db")
+	defer os.RemoveAll(dir)
+
+	// A database with the original schema, and a duplicate commit.
+	legacy := sqlx.MustConnect("sqlite3", filepath.Join(dir, "test.sqlite"))
+	legacy.MustExec(baselineSchema)
+	legacy.MustExec(`INSERT INTO git_user (source, username, url, avatar_url) VALUES (1, 'alice', 'https://github.com/alice', '');`)
+	legacy.MustExec(`INSERT INTO git_repo (source, name, description, url) VALUES (1, 'gems', '', 'https://github.com/alice/gems');`)
+	for _, url := range []string{"https://github.com/alice/gems/commit/1", "https://github.com/alice/gems/commit/2"} {
+		legacy.MustExec(`
+			INSERT INTO git_commit (source, author_id, repo_id, message, sha, url, date, created_at, groupname, color_bg, color_fg)
+			VALUES (1, 1, 1, 'fixed a bug', '1', ?, '2021-01-01', '2021-01-01', '', '', '');`, url)
+	}
+
+	// The duplicate was labeled, by a version with the labels.
+	legacy.MustExec(`CREATE TABLE commit_label (commit_id INTEGER PRIMARY KEY, funny BOOL NOT NULL, labeled_at DATETIME NOT NULL);`)
+	legacy.MustExec(`INSERT INTO commit_label (commit_id, funny, labeled_at) VALUES (2, TRUE, '2021-01-02');`)
+	legacy.Close()
+
+	// The migrations run when the database is opened, and only once.
+	s := NewSqliteDB(filepath.Join(dir, "test.sqlite"))
+	s.Close()
+
+	s = NewSqliteDB(filepath.Join(dir, "test.sqlite"))
+	defer s.Close()
+
+	// The duplicate is kept, as a duplicate of the oldest commit, which gets its label.
+	commits, _ := s.AllCommits()
+	u.AssertEqual(t, len(commits), 2)
+	u.AssertEqual(t, commits[0].CanonicalID.Valid, false)
+	u.AssertEqual(t, commits[1].CanonicalID.Int64, int64(1))
+
+	labels, _ := s.CommitLabels()
+	u.AssertEqual(t, len(labels), 1)
+	u.AssertEqual(t, labels[0].CommitID, 1)
+	u.AssertEqual(t, labels[0].Funny, true)
+
+	applied := 0
+	s.DB.Get(&applied, `SELECT COUNT(*) FROM schema_migration;`)
+	u.AssertEqual(t, applied > 0, true)
+
+	// The new columns are there.
+	commit := testCommit("bob", "gems", "added a feature")
+	commit.Body = "with a body"
+	created, err := s.SaveCommitBatch(models.GitCommits{commit})
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(created), 1)
+
+	commits, _ = s.AllCommits()
+	u.AssertEqual(t, len(commits), 3)
+	u.AssertEqual(t, commits[2].Body, "with a body")
+
+	// The unique index is there, for the canonical commits.
+	_, err = s.DB.Exec(`
+		INSERT INTO git_commit (source, author_id, repo_id, message, sha, url, date, created_at, groupname, color_bg, color_fg)
+		VALUES (1, 1, 1, 'fixed a bug', '1', 'https://github.com/alice/gems/commit/3', '2021-01-01', '2021-01-01', '', '', '');`)
+	u.AssertEqual(t, err != nil, true)
+}
+
+func Test_migrationDriver(t *testing.T) {
+	u.AssertEqual(t, migrationDriver("000_commit_columns.sql"), "")
+	u.AssertEqual(t, migrationDriver("003_source_text.postgres.sql"), "postgres")
+}
+
+func Test_createSearchIndex(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "db")
+	defer os.RemoveAll(dir)
+
+	s := NewSqliteDB(filepath.Join(dir, "test.sqlite"))
+	if !s.fts {
+		t.Skip("SQLite is built without FTS5. Run the tests with -tags sqlite_fts5")
+	}
+
+	// A commit saved while the index was out of sync.
+	s.DB.MustExec(`DROP TRIGGER git_commit_fts_insert;`)
+	s.SaveCommitBatch(models.GitCommits{testCommit("alice", "gems", "fixed a bug")})
+	s.Close()
+
+	// The index is rebuilt when the database is opened again.
+	s = NewSqliteDB(filepath.Join(dir, "test.sqlite"))
+	defer s.Close()
+
+	results, err := s.SearchCommits("bug", models.SearchFilters{}, 1)
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(results.Commits), 1)
+}
+
+func Test_sampleCommits_gaps(t *testing.T) {
+	s := testDB(t)
+	defer s.Close()
+
+	commits := models.GitCommits{}
+	for i := 0; i < 10; i++ {
+		commits = append(commits, testCommit("alice", "gems", fmt.Sprintf("fixed bug %d", i)))
+	}
+	s.SaveCommitBatch(commits)
+
+	// The last commits come after a gap in the IDs.
+	s.DB.MustExec(`UPDATE git_commit SET id = id + 100 WHERE id > 5;`)
+
+	// Every commit is as likely to be sampled, even the first one after the gap.
+	picks := map[int]int{}
+	for i := 0; i < 500; i++ {
+		sample, err := sampleCommits(s.DB, "", "", 1)
+		u.AssertEqual(t, err, nil)
+		u.AssertEqual(t, len(sample), 1)
+		picks[sample[0].ID]++
+	}
+
+	u.AssertEqual(t, len(picks), 10)
+	u.AssertEqual(t, picks[106] < 100, true)
+}
+
+func Test_sqliteDSN(t *testing.T) {
+	u.AssertEqual(t, sqliteDSN("test.sqlite"), "test.sqlite?_busy_timeout=10000&_txlock=immediate")
+	u.AssertEqual(t, sqliteDSN("file:test.sqlite?mode=rw"), "file:test.sqlite?mode=rw&_busy_timeout=10000&_txlock=immediate")
+}
+
+// ------------------------------------------------------------------
+// Helpers
+// ------------------------------------------------------------------
+
+// testDB creates a temporary database, with the schema applied.
+func testDB(t *testing.T) SqliteDB {
+	dir, _ := ioutil.TempDir("", "db")
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return NewSqliteDB(filepath.Join(dir, "test.sqlite"))
+}
+
+// baselineSchema is the schema of the databases from before the migrations.
+const baselineSchema = `
+CREATE TABLE IF NOT EXISTS config_badword (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    text VARCHAR(50) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS config_groupterm (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    text VARCHAR(50) NOT NULL,
+    groupname VARCHAR(50) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS config_searchterm (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    text VARCHAR(50) NOT NULL,
+    rank INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS git_user (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source INTEGER NOT NULL,
+    username VARCHAR(50) NOT NULL,
+    url VARCHAR(100) UNIQUE NOT NULL,
+    avatar_url VARCHAR(200) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS git_repo (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source INTEGER NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    description VARCHAR(100) NOT NULL,
+    url VARCHAR(200) UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS git_commit (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source INTEGER NOT NULL,
+    author_id INTEGER NOT NULL,
+    repo_id INTEGER NOT NULL,
+    message VARCHAR(500) NOT NULL,
+    message_censored VARCHAR(500) NOT NULL DEFAULT '',
+    sha VARCHAR(40) NOT NULL,
+    url VARCHAR(200) UNIQUE NOT NULL,
+    date DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    valid BOOL NOT NULL DEFAULT TRUE,
+    groupname VARCHAR(50) NOT NULL,
+    color_bg VARCHAR(10) NOT NULL,
+    color_fg VARCHAR(10) NOT NULL,
+    FOREIGN KEY(repo_id) REFERENCES git_repo(id),
+    FOREIGN KEY(author_id) REFERENCES git_user(id)
+);
+`
@@ -1,6 +1,10 @@
 package models
 
 import (
+	"database/sql"
+	"html"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/tunedmystic/commits.lol/app/utils"
@@ -30,17 +34,25 @@ type GitCommit struct {
 	Source          int       `db:"source"`
 	AuthorID        int       `db:"author_id"`
 	RepoID          int       `db:"repo_id"`
-	Message         string    `db:"message"`
+	Message         string    `db:"message"` // The subject, which is the first line of the commit message.
 	MessageCensored string    `db:"message_censored"`
+	Body            string    `db:"body"` // The rest of the commit message, if it has more than one line.
+	BodyCensored    string    `db:"body_censored"`
 	SHA             string    `db:"sha"`
 	URL             string    `db:"url"`
 	Date            time.Time `db:"date"`
 
-	CreatedAt       time.Time `db:"created_at"`
-	Valid           bool      `db:"valid"`
-	Group           string    `db:"groupname"`
-	ColorBackground string    `db:"color_bg"`
-	ColorForeground string    `db:"color_fg"`
+	CreatedAt       time.Time       `db:"created_at"`
+	Valid           bool            `db:"valid"`
+	Group           string          `db:"groupname"`
+	Lang            string          `db:"lang"`  // The detected language code, or empty if not detected.
+	Score           sql.NullFloat64 `db:"score"` // The probability that the commit is funny, if it was scored.
+	ColorBackground string          `db:"color_bg"`
+	ColorForeground string          `db:"color_fg"`
+
+	MessageHash string        `db:"message_hash"`
+	Simhash     int64         `db:"simhash"`      // The uint64 simhash, stored as a signed integer.
+	CanonicalID sql.NullInt64 `db:"canonical_id"` // Set if the commit is a duplicate of another commit.
 
 	Author GitUser `db:"author"`
 	Repo   GitRepo `db:"repo"`
@@ -50,14 +62,16 @@ type GitCommit struct {
 type GitCommits []GitCommit
 
 // SetCensoredMessage cleans the commit message and sets it as the `MessageCensored` field.
+// The message is escaped first, as the censored message is rendered as HTML.
 // Returns true if message was censored.
 // Returns false if there were no bad words to be cleaned.
 func (c *GitCommit) SetCensoredMessage(cl utils.Cleaner) bool {
-	cleanedMsg, _ := cl.Clean(c.Message)
+	escaped := html.EscapeString(c.Message)
+	cleanedMsg, _ := cl.Clean(escaped)
 
-	// If the cleaned message is the same as the commit's message, then nothing was cleaned.
+	// If the cleaned message is the same as the escaped message, then nothing was cleaned.
 	// In any of these cases, return false to express that the Commit was not updated.
-	if cleanedMsg == c.Message {
+	if cleanedMsg == escaped {
 		return false
 	}
 
@@ -65,6 +79,42 @@ func (c *GitCommit) SetCensoredMessage(cl utils.Cleaner) bool {
 	return true
 }
 
+// SetCensoredBody cleans every line of the commit body and sets it as the `BodyCensored` field.
+// The body is escaped first, as the censored body is rendered as HTML.
+// Returns true if the body was censored.
+func (c *GitCommit) SetCensoredBody(cl utils.Cleaner) bool {
+	escaped := html.EscapeString(c.Body)
+
+	lines := strings.Split(escaped, "\n")
+	for i, line := range lines {
+		lines[i], _ = cl.Clean(line)
+	}
+	cleanedBody := strings.Join(lines, "\n")
+
+	if cleanedBody == escaped {
+		return false
+	}
+
+	c.BodyCensored = cleanedBody
+	return true
+}
+
+// SplitMessage splits a multi-line commit message into its subject, which stays
+// in the `Message` field, and its body, which is set as the `Body` field.
+// Returns false if the message has a single line.
+func (c *GitCommit) SplitMessage() bool {
+	message := strings.TrimSpace(strings.Replace(c.Message, "\r\n", "\n", -1))
+
+	lines := strings.SplitN(message, "\n", 2)
+	if len(lines) == 1 {
+		return false
+	}
+
+	c.Message = strings.TrimSpace(lines[0])
+	c.Body = strings.TrimSpace(lines[1])
+	return true
+}
+
 // SetGroup assigns the commit to a group based on the commit message.
 func (c *GitCommit) SetGroup(g utils.Grouper) bool {
 	commitGroup := g.Group(c.Message)
@@ -79,6 +129,52 @@ func (c *GitCommit) SetGroup(g utils.Grouper) bool {
 	return true
 }
 
+// SetMessageHash sets the hashes that identify duplicates of the commit message.
+func (c *GitCommit) SetMessageHash() {
+	c.MessageHash = utils.MessageHash(c.Message)
+	c.Simhash = int64(utils.Simhash(c.Message))
+}
+
+// IsNearDuplicate checks if the commit message is a near-duplicate of the other commit's message.
+func (c *GitCommit) IsNearDuplicate(other GitCommit) bool {
+	if c.MessageHash == other.MessageHash {
+		return true
+	}
+	if c.Simhash == 0 || other.Simhash == 0 {
+		return false
+	}
+	return c.SimhashDistance(other) <= utils.SimhashThreshold
+}
+
+// SimhashDistance returns the Hamming distance between the simhashes of the commits.
+func (c *GitCommit) SimhashDistance(other GitCommit) int {
+	return utils.HammingDistance(uint64(c.Simhash), uint64(other.Simhash))
+}
+
+// ScoreWeight returns the sampling weight of the commit, from its humor score.
+// The bias sharpens the weights: 0 weighs every commit the same, and higher
+// values favor the high scores more. Unscored commits are weighed as neutral.
+func (c *GitCommit) ScoreWeight(bias float64) float64 {
+	score := 0.5
+	if c.Score.Valid {
+		score = c.Score.Float64
+	}
+	return math.Max(math.Pow(score, bias), 0.001)
+}
+
+// WeightedSample picks n commits at random, favoring the ones with higher humor scores.
+func (c GitCommits) WeightedSample(n int, bias float64, random func() float64) GitCommits {
+	weight := func(i int) float64 {
+		return c[i].ScoreWeight(bias)
+	}
+
+	commits := make(GitCommits, 0, n)
+	for _, i := range utils.WeightedSample(len(c), n, weight, random) {
+		commits = append(commits, c[i])
+	}
+	return commits
+}
+
 // SetColorTheme sets the background and foreground color based on
 // various attributes of the given Commit.
 func (c *GitCommit) SetColorTheme() {
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"database/sql"
+	"math/rand"
+	"strings"
 	"testing"
 
 	u "github.com/tunedmystic/commits.lol/app/utils"
@@ -48,6 +51,19 @@ func Test_SetCensoredMessage_not_updated(t *testing.T) {
 	u.AssertEqual(t, commit.MessageCensored, "")
 }
 
+func Test_SetCensoredMessage_escaped(t *testing.T) {
+	c := u.NewMessageCleaner([]string{"crap"})
+
+	commit := GitCommit{Message: "crappy <script>alert(1)</script>"}
+	u.AssertEqual(t, commit.SetCensoredMessage(c), true)
+	u.AssertEqual(t, commit.MessageCensored, `<span class="censored">c<span class="word">#%@$!</span></span> &lt;script&gt;alert(1)&lt;/script&gt;`)
+
+	// Escaping alone doesn't censor the message.
+	commit = GitCommit{Message: "fixed <b>bug</b> & moved on"}
+	u.AssertEqual(t, commit.SetCensoredMessage(c), false)
+	u.AssertEqual(t, commit.MessageCensored, "")
+}
+
 type MockGrouper struct {
 	MockGroup func(text string) string
 }
@@ -105,3 +121,83 @@ func Test_SetColorTheme_updated(t *testing.T) {
 	u.AssertEqual(t, commit.ColorBackground, "#ffd300")
 	u.AssertEqual(t, commit.ColorForeground, "#000000")
 }
+
+func Test_SetMessageHash(t *testing.T) {
+	commit := GitCommit{Message: "Fix stupid typo (#12)"}
+	commit.SetMessageHash()
+
+	u.AssertEqual(t, commit.MessageHash, u.MessageHash("fix stupid typo"))
+	u.AssertEqual(t, commit.Simhash, int64(u.Simhash("fix stupid typo")))
+}
+
+func Test_IsNearDuplicate(t *testing.T) {
+	commits := GitCommits{
+		{Message: "oops forgot to add the file"},
+		{Message: "Oops, forgot to add the files."},
+		{Message: "i hate javascript"},
+		{Message: "fix typo"},
+		{Message: "fix type"},
+	}
+	for i := range commits {
+		commits[i].SetMessageHash()
+	}
+
+	u.AssertEqual(t, commits[0].IsNearDuplicate(commits[1]), true)
+	u.AssertEqual(t, commits[0].IsNearDuplicate(commits[2]), false)
+
+	// Short messages are only duplicates if they're the same.
+	u.AssertEqual(t, commits[3].IsNearDuplicate(commits[3]), true)
+	u.AssertEqual(t, commits[3].IsNearDuplicate(commits[4]), false)
+}
+
+func Test_GitCommits_WeightedSample(t *testing.T) {
+	funny := GitCommit{ID: 1, Score: sql.NullFloat64{Float64: 0.9, Valid: true}}
+	boring := GitCommit{ID: 2, Score: sql.NullFloat64{Float64: 0.1, Valid: true}}
+	unscored := GitCommit{ID: 3}
+
+	u.AssertEqual(t, unscored.ScoreWeight(2), 0.25)
+	u.AssertEqual(t, boring.ScoreWeight(0), 1.0)
+
+	commits := GitCommits{funny, boring, unscored}
+
+	// Sampling more commits than there are returns all of them.
+	u.AssertEqual(t, len(commits.WeightedSample(10, 2, rand.Float64)), 3)
+
+	// The funniest commit is picked far more often than the others.
+	r := rand.New(rand.NewSource(1))
+	picks := map[int]int{}
+	for i := 0; i < 1000; i++ {
+		picks[commits.WeightedSample(1, 2, r.Float64)[0].ID]++
+	}
+
+	u.AssertEqual(t, picks[1] > 600, true)
+	u.AssertEqual(t, picks[3] > picks[2], true)
+}
+
+func Test_SplitMessage(t *testing.T) {
+	commit := GitCommit{Message: "Fixed a bug\r\n\r\nIt was a feature.\r\nNot anymore.\n"}
+	u.AssertEqual(t, commit.SplitMessage(), true)
+	u.AssertEqual(t, commit.Message, "Fixed a bug")
+	u.AssertEqual(t, commit.Body, "It was a feature.\nNot anymore.")
+
+	// The split message stays the same.
+	u.AssertEqual(t, commit.SplitMessage(), false)
+	u.AssertEqual(t, commit.Message, "Fixed a bug")
+	u.AssertEqual(t, commit.Body, "It was a feature.\nNot anymore.")
+}
+
+func Test_SetCensoredBody(t *testing.T) {
+	c := &MockCleaner{
+		MockClean: func(text string) (string, int) {
+			return strings.Replace(text, "crappy", "c####", -1), 1
+		},
+	}
+
+	commit := GitCommit{Body: "a crappy <b>bug</b>\nthe end"}
+	u.AssertEqual(t, commit.SetCensoredBody(c), true)
+	u.AssertEqual(t, commit.BodyCensored, "a c#### &lt;b&gt;bug&lt;/b&gt;\nthe end")
+
+	commit = GitCommit{Body: "a bug\nthe end"}
+	u.AssertEqual(t, commit.SetCensoredBody(c), false)
+	u.AssertEqual(t, commit.BodyCensored, "")
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/tunedmystic/commits.lol/app/utils"
+)
+
+// CommitLabel is the model for the commit_label table.
+// It records whether a person found the commit funny.
+type CommitLabel struct {
+	CommitID  int       `db:"commit_id"`
+	Funny     bool      `db:"funny"`
+	LabeledAt time.Time `db:"labeled_at"`
+
+	Message string `db:"message"` // The message of the labeled commit.
+}
+
+// CommitLabels is a slice of CommitLabel values.
+type CommitLabels []CommitLabel
+
+// ToExamples returns the labels as training examples.
+func (l CommitLabels) ToExamples() []utils.HumorExample {
+	examples := make([]utils.HumorExample, 0, len(l))
+	for _, label := range l {
+		examples = append(examples, utils.HumorExample{Message: label.Message, Funny: label.Funny})
+	}
+	return examples
+}
+
+// HumorModel is the model for the humor_model table.
+// It stores a trained HumorClassifier, as JSON.
+type HumorModel struct {
+	ID        int       `db:"id"`
+	TrainedAt time.Time `db:"trained_at"`
+	Examples  int       `db:"examples"`
+	Data      string    `db:"data"`
+}
+
+// SetClassifier stores the classifier as JSON.
+func (m *HumorModel) SetClassifier(c *utils.HumorClassifier) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	m.Data = string(data)
+	m.Examples = c.FunnyDocs + c.NotFunnyDocs
+	return nil
+}
+
+// Classifier returns the stored classifier.
+func (m *HumorModel) Classifier() (*utils.HumorClassifier, error) {
+	c := utils.HumorClassifier{}
+	if err := json.Unmarshal([]byte(m.Data), &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+
+	u "github.com/tunedmystic/commits.lol/app/utils"
+)
+
+func Test_CommitLabels_ToExamples(t *testing.T) {
+	labels := CommitLabels{
+		{CommitID: 1, Funny: true, Message: "why does this even work"},
+		{CommitID: 2, Funny: false, Message: "update dependencies"},
+	}
+
+	examples := labels.ToExamples()
+	u.AssertEqual(t, len(examples), 2)
+	u.AssertEqual(t, examples[0].Message, "why does this even work")
+	u.AssertEqual(t, examples[0].Funny, true)
+	u.AssertEqual(t, examples[1].Funny, false)
+}
+
+func Test_HumorModel_Classifier(t *testing.T) {
+	c := u.TrainHumorClassifier([]u.HumorExample{
+		{Message: "why does this even work", Funny: true},
+		{Message: "update dependencies", Funny: false},
+	})
+
+	m := HumorModel{}
+	u.AssertEqual(t, m.SetClassifier(c), nil)
+	u.AssertEqual(t, m.Examples, 2)
+
+	loaded, err := m.Classifier()
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, loaded.Score("why"), c.Score("why"))
+
+	_, err = (&HumorModel{Data: "nope"}).Classifier()
+	u.AssertEqual(t, err != nil, true)
+}
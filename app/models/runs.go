@@ -0,0 +1,90 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PipelineRun is the model for the pipeline_run table.
+type PipelineRun struct {
+	ID         int       `db:"id"`
+	Trigger    string    `db:"triggered_by"`
+	StartedAt  time.Time `db:"started_at"`
+	FinishedAt time.Time `db:"finished_at"`
+	FromDate   string    `db:"from_date"`
+	ToDate     string    `db:"to_date"`
+	Terms      string    `db:"terms"`   // JSON list of the searched terms
+	Sources    string    `db:"sources"` // JSON list of the source names
+	Fetched    int       `db:"fetched"`
+	Saved      int       `db:"saved"`
+	Duplicates int       `db:"duplicates"`
+	Rejected   int       `db:"rejected"`
+	Errors     string    `db:"errors"` // JSON list of the error messages
+	APICalls   int       `db:"api_calls"`
+}
+
+// PipelineRuns is a slice of PipelineRun values.
+type PipelineRuns []PipelineRun
+
+// SetTerms stores the terms as a JSON list.
+func (r *PipelineRun) SetTerms(terms []string) {
+	r.Terms = toJSONList(terms)
+}
+
+// SetSources stores the source names as a JSON list.
+func (r *PipelineRun) SetSources(sources []string) {
+	r.Sources = toJSONList(sources)
+}
+
+// SetErrors stores the error messages as a JSON list.
+func (r *PipelineRun) SetErrors(errors []string) {
+	r.Errors = toJSONList(errors)
+}
+
+// TermList returns the searched terms.
+func (r *PipelineRun) TermList() []string {
+	return fromJSONList(r.Terms)
+}
+
+// SourceList returns the source names.
+func (r *PipelineRun) SourceList() []string {
+	return fromJSONList(r.Sources)
+}
+
+// ErrorList returns the error messages.
+func (r *PipelineRun) ErrorList() []string {
+	return fromJSONList(r.Errors)
+}
+
+// Duration returns how long the run took.
+func (r *PipelineRun) Duration() time.Duration {
+	return r.FinishedAt.Sub(r.StartedAt).Round(time.Millisecond)
+}
+
+// SearchHistory is the model for the search_history table.
+// It records the results of a search term, in a pipeline run.
+type SearchHistory struct {
+	ID         int       `db:"id"`
+	RunID      int       `db:"run_id"`
+	Term       string    `db:"term"`
+	SearchedAt time.Time `db:"searched_at"`
+	APICalls   int       `db:"api_calls"`
+	Saved      int       `db:"saved"`
+}
+
+// SearchHistories is a slice of SearchHistory values.
+type SearchHistories []SearchHistory
+
+func toJSONList(values []string) string {
+	if values == nil {
+		values = []string{}
+	}
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+func fromJSONList(data string) []string {
+	values := []string{}
+	json.Unmarshal([]byte(data), &values)
+	return values
+}
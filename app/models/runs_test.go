@@ -0,0 +1,32 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	u "github.com/tunedmystic/commits.lol/app/utils"
+)
+
+func Test_PipelineRun_lists(t *testing.T) {
+	run := PipelineRun{}
+	run.SetTerms([]string{"bug", "lol, wat"})
+	run.SetSources([]string{"github"})
+	run.SetErrors(nil)
+
+	u.AssertEqual(t, run.Terms, `["bug","lol, wat"]`)
+	u.AssertEqual(t, run.Errors, `[]`)
+	u.AssertEqual(t, len(run.TermList()), 2)
+	u.AssertEqual(t, run.TermList()[1], "lol, wat")
+	u.AssertEqual(t, run.SourceList()[0], "github")
+	u.AssertEqual(t, len(run.ErrorList()), 0)
+
+	// Invalid values are read as empty lists.
+	u.AssertEqual(t, len((&PipelineRun{}).TermList()), 0)
+}
+
+func Test_PipelineRun_Duration(t *testing.T) {
+	now := time.Now()
+	run := PipelineRun{StartedAt: now, FinishedAt: now.Add(1500 * time.Millisecond)}
+
+	u.AssertEqual(t, run.Duration(), 1500*time.Millisecond)
+}
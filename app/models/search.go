@@ -0,0 +1,38 @@
+package models
+
+import "strings"
+
+// SearchPageSize is the amount of commits in a page of search results.
+const SearchPageSize = 30
+
+// SearchFilters narrows down a commit search. The empty filters match every commit.
+type SearchFilters struct {
+	Group string
+	Lang  string
+}
+
+// SearchResults is a page of the commits that matched a search, newest first.
+type SearchResults struct {
+	Query   string
+	Page    int
+	Commits GitCommits
+	HasNext bool
+}
+
+// SearchWords splits the search query into the words that the commits must have.
+// Quotes are dropped, and the amount of words is capped, to keep the searches cheap.
+// Example:  `fixed "the" Bug`  ->  [fixed the bug]
+func SearchWords(query string) []string {
+	words := []string{}
+	for _, field := range strings.Fields(strings.ToLower(query)) {
+		word := strings.Trim(field, `"`)
+		if word == "" {
+			continue
+		}
+		words = append(words, word)
+		if len(words) == 10 {
+			break
+		}
+	}
+	return words
+}
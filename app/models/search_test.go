@@ -0,0 +1,14 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	u "github.com/tunedmystic/commits.lol/app/utils"
+)
+
+func Test_SearchWords(t *testing.T) {
+	u.AssertEqual(t, strings.Join(SearchWords(`fixed "the"  Bug`), ","), "fixed,the,bug")
+	u.AssertEqual(t, len(SearchWords(`""  `)), 0)
+	u.AssertEqual(t, len(SearchWords("a b c d e f g h i j k l")), 10)
+}
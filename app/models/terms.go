@@ -1,11 +1,27 @@
 package models
 
+import (
+	"database/sql"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/tunedmystic/commits.lol/app/utils"
+)
+
 // BadWord is the model for the config_badword table.
 type BadWord struct {
 	ID   int    `db:"id"`
 	Text string `db:"text"`
 }
 
+// BlockWord is the model for the config_blockword table.
+// A commit message with a block word is rejected, instead of censored.
+type BlockWord struct {
+	ID   int    `db:"id"`
+	Text string `db:"text"`
+}
+
 // GroupTerm is the model for the config_groupterm table.
 type GroupTerm struct {
 	ID    int    `db:"id"`
@@ -32,6 +48,18 @@ func (b BadWords) ToStrings() []string {
 	return values
 }
 
+// BlockWords is a slice of BlockWord values.
+type BlockWords []BlockWord
+
+// ToStrings converts the BlockWords slice into a slice of strings.
+func (b BlockWords) ToStrings() []string {
+	values := make([]string, 0, len(b))
+	for _, blockword := range b {
+		values = append(values, blockword.Text)
+	}
+	return values
+}
+
 // GroupTerms is a slice of GroupTerm values.
 type GroupTerms []GroupTerm
 
@@ -55,3 +83,148 @@ func (t SearchTerms) ToStrings() []string {
 	}
 	return values
 }
+
+// Rank of the search terms that stopped yielding commits.
+const RankDead = 5
+
+// rankProbe is the lowest live rank. Dead terms get it back when their searches
+// fall out of the yield period, so they're probed again.
+const rankProbe = 4
+
+// Weights used when sampling search terms.
+const (
+	minTermWeight  = 0.1  // floor, so new and unproductive terms still get searched
+	deadTermWeight = 0.01 // dead terms are only searched once in a blue moon
+)
+
+// minTermSearches is the amount of searches needed before a term's yield is trusted.
+const minTermSearches = 3
+
+// SearchTermState is the model for the searchterm_state table.
+// It records when a term was last searched, and the author date
+// of the newest commit it found (the watermark).
+type SearchTermState struct {
+	Term           string       `db:"term"`
+	LastSearchedAt time.Time    `db:"last_searched_at"`
+	Watermark      sql.NullTime `db:"watermark"`
+}
+
+// SearchTermStates is a slice of SearchTermState values.
+type SearchTermStates []SearchTermState
+
+// SearchTermYield is a search term, with its search results over a period of time,
+// and its search state.
+type SearchTermYield struct {
+	SearchTerm
+	Searches       int          `db:"searches"`
+	APICalls       int          `db:"api_calls"`
+	Saved          int          `db:"saved"`
+	LastSearchedAt sql.NullTime `db:"last_searched_at"`
+	Watermark      sql.NullTime `db:"watermark"`
+}
+
+// Yield returns the amount of commits saved per API call.
+func (y SearchTermYield) Yield() float64 {
+	if y.APICalls == 0 {
+		return 0
+	}
+	return float64(y.Saved) / float64(y.APICalls)
+}
+
+// Weight returns how likely the term is to be sampled, relative to other terms.
+func (y SearchTermYield) Weight() float64 {
+	if y.Rank == RankDead {
+		return deadTermWeight
+	}
+	return math.Max(y.Yield(), minTermWeight)
+}
+
+// SearchTermYields is a slice of SearchTermYield values.
+type SearchTermYields []SearchTermYield
+
+// Stalest returns the n terms that were searched the longest time ago.
+// Terms that were never searched come first.
+func (y SearchTermYields) Stalest(n int) SearchTermYields {
+	terms := make(SearchTermYields, len(y))
+	copy(terms, y)
+
+	sort.SliceStable(terms, func(i, j int) bool {
+		a, b := terms[i].LastSearchedAt, terms[j].LastSearchedAt
+		if !a.Valid || !b.Valid {
+			return !a.Valid && b.Valid
+		}
+		return a.Time.Before(b.Time)
+	})
+
+	if n > len(terms) {
+		n = len(terms)
+	}
+	return terms[:n]
+}
+
+// Watermarks returns the watermark of every term that has one, by term text.
+func (y SearchTermYields) Watermarks() map[string]time.Time {
+	watermarks := map[string]time.Time{}
+	for _, term := range y {
+		if term.Watermark.Valid {
+			watermarks[term.Text] = term.Watermark.Time
+		}
+	}
+	return watermarks
+}
+
+// ToStrings converts the SearchTermYields slice into a slice of strings.
+func (y SearchTermYields) ToStrings() []string {
+	values := make([]string, 0, len(y))
+	for _, term := range y {
+		values = append(values, term.Text)
+	}
+	return values
+}
+
+// WeightedSample returns n terms, sampled without replacement by their weight.
+// The random func returns numbers in [0.0, 1.0), like rand.Float64.
+func (y SearchTermYields) WeightedSample(n int, random func() float64) SearchTermYields {
+	weight := func(i int) float64 {
+		return y[i].Weight()
+	}
+
+	terms := make(SearchTermYields, 0, n)
+	for _, i := range utils.WeightedSample(len(y), n, weight, random) {
+		terms = append(terms, y[i])
+	}
+	return terms
+}
+
+// Ranks calculates the rank of every term from its yield.
+// Terms that were searched enough are ranked 1-4 by their yield (1 is the best),
+// or marked as dead if they saved nothing. Other terms keep their current rank,
+// except for the dead ones, which are probed again at the lowest live rank.
+// A dead term is rarely searched, so it would never be searched enough to be re-ranked.
+func (y SearchTermYields) Ranks() map[int]int {
+	ranks := make(map[int]int, len(y))
+	live := SearchTermYields{}
+
+	for _, term := range y {
+		switch {
+		case term.Searches < minTermSearches && term.Rank == RankDead:
+			ranks[term.ID] = rankProbe
+		case term.Searches < minTermSearches:
+			ranks[term.ID] = term.Rank
+		case term.Saved == 0:
+			ranks[term.ID] = RankDead
+		default:
+			live = append(live, term)
+		}
+	}
+
+	sort.SliceStable(live, func(i, j int) bool {
+		return live[i].Yield() > live[j].Yield()
+	})
+
+	for i, term := range live {
+		ranks[term.ID] = 1 + i*4/len(live)
+	}
+
+	return ranks
+}
@@ -1,7 +1,10 @@
 package models
 
 import (
+	"database/sql"
+	"math/rand"
 	"testing"
+	"time"
 
 	u "github.com/tunedmystic/commits.lol/app/utils"
 )
@@ -71,3 +74,108 @@ func Test_GroupTerms_ToMap(t *testing.T) {
 	_, ok = terms["not-here"]
 	u.AssertEqual(t, ok, false)
 }
+
+func Test_SearchTermYield_Weight(t *testing.T) {
+	productive := testYield(1, "lol", 1, 5, 10, 20)
+	poor := testYield(2, "meh", 2, 5, 10, 0)
+	dead := testYield(3, "zzz", RankDead, 5, 10, 0)
+	unsearched := testYield(4, "new", 1, 0, 0, 0)
+
+	u.AssertEqual(t, productive.Yield(), 2.0)
+	u.AssertEqual(t, productive.Weight(), 2.0)
+	u.AssertEqual(t, poor.Weight(), minTermWeight)
+	u.AssertEqual(t, dead.Weight(), deadTermWeight)
+	u.AssertEqual(t, unsearched.Yield(), 0.0)
+	u.AssertEqual(t, unsearched.Weight(), minTermWeight)
+}
+
+func Test_SearchTermYields_WeightedSample(t *testing.T) {
+	yields := SearchTermYields{
+		testYield(1, "lol", 1, 5, 10, 50),
+		testYield(2, "meh", 2, 5, 10, 0),
+		testYield(3, "zzz", RankDead, 5, 10, 0),
+	}
+
+	// Sampling more terms than there are returns all of them.
+	u.AssertEqual(t, len(yields.WeightedSample(10, rand.Float64)), 3)
+
+	// The most productive term is picked far more often than the others.
+	r := rand.New(rand.NewSource(1))
+	picks := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		terms := yields.WeightedSample(1, r.Float64)
+		picks[terms[0].Text]++
+	}
+
+	u.AssertEqual(t, picks["lol"] > 900, true)
+	u.AssertEqual(t, picks["meh"] > picks["zzz"], true)
+}
+
+func Test_SearchTermYields_Ranks(t *testing.T) {
+	yields := SearchTermYields{
+		testYield(1, "a", 4, 5, 10, 40),
+		testYield(2, "b", 1, 5, 10, 1),
+		testYield(3, "c", 3, 5, 10, 20),
+		testYield(4, "d", 2, 5, 10, 10),
+		testYield(5, "dead", 1, 5, 10, 0),
+		testYield(6, "new", 2, 1, 2, 0),
+		testYield(7, "probed", RankDead, 1, 2, 0),
+		testYield(8, "still dead", RankDead, 3, 6, 0),
+	}
+
+	ranks := yields.Ranks()
+
+	u.AssertEqual(t, ranks[1], 1) // promoted
+	u.AssertEqual(t, ranks[3], 2)
+	u.AssertEqual(t, ranks[4], 3)
+	u.AssertEqual(t, ranks[2], 4) // demoted
+	u.AssertEqual(t, ranks[5], RankDead)
+	u.AssertEqual(t, ranks[6], 2) // not searched enough, so the rank is kept
+	u.AssertEqual(t, ranks[7], 4) // dead, but not searched enough, so it's probed again
+	u.AssertEqual(t, ranks[8], RankDead)
+}
+
+func Test_SearchTermYields_Stalest(t *testing.T) {
+	now := time.Now()
+	yields := SearchTermYields{
+		testYield(1, "recent", 1, 0, 0, 0),
+		testYield(2, "old", 1, 0, 0, 0),
+		testYield(3, "never", 1, 0, 0, 0),
+	}
+	yields[0].LastSearchedAt = sql.NullTime{Time: now, Valid: true}
+	yields[1].LastSearchedAt = sql.NullTime{Time: now.AddDate(0, 0, -2), Valid: true}
+
+	stalest := yields.Stalest(2)
+
+	u.AssertEqual(t, len(stalest), 2)
+	u.AssertEqual(t, stalest[0].Text, "never")
+	u.AssertEqual(t, stalest[1].Text, "old")
+	u.AssertEqual(t, len(yields.Stalest(10)), 3)
+
+	// The original order is untouched.
+	u.AssertEqual(t, yields[0].Text, "recent")
+}
+
+func Test_SearchTermYields_Watermarks(t *testing.T) {
+	now := time.Now()
+	yields := SearchTermYields{
+		testYield(1, "seen", 1, 0, 0, 0),
+		testYield(2, "unseen", 1, 0, 0, 0),
+	}
+	yields[0].Watermark = sql.NullTime{Time: now, Valid: true}
+
+	watermarks := yields.Watermarks()
+
+	u.AssertEqual(t, len(watermarks), 1)
+	u.AssertEqual(t, watermarks["seen"], now)
+}
+
+// testYield creates a SearchTermYield, without a search state.
+func testYield(ID int, text string, rank, searches, calls, saved int) SearchTermYield {
+	return SearchTermYield{
+		SearchTerm: SearchTerm{ID, text, rank},
+		Searches:   searches,
+		APICalls:   calls,
+		Saved:      saved,
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// WebhookDelivery is the model for the webhook_delivery table.
+// It records the delivery of an event to a webhook URL.
+type WebhookDelivery struct {
+	ID         int       `db:"id"`
+	URL        string    `db:"url"`
+	Event      string    `db:"event"`
+	Payload    string    `db:"payload"`     // The JSON body that was sent.
+	StatusCode int       `db:"status_code"` // The status code of the last attempt, or 0 if there was no response.
+	Attempts   int       `db:"attempts"`
+	Delivered  bool      `db:"delivered"`
+	Error      string    `db:"error"` // The error of the last attempt, if the delivery failed.
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// WebhookDeliveries is a slice of WebhookDelivery values.
+type WebhookDeliveries []WebhookDelivery
@@ -2,6 +2,7 @@ package pipeline
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/getsentry/sentry-go"
@@ -18,17 +19,35 @@ import (
 type CommitPipeline struct {
 	db db.Database
 
-	client  github.Client
+	sources []Source
 	options github.CommitSearchOptions
-	cleaner utils.Cleaner
-	grouper utils.Grouper
+	stages  []Stage
 
-	jobs  chan string
-	done  chan bool
-	terms []string
-	now   time.Time
+	terms   []string
+	trigger string
+	now     time.Time
+
+	// The amount of API calls that a run can make, and the calls that are left.
+	// A budget of 0 means no limit.
+	budget    int
+	remaining *utils.CallBudget
+
+	// The subscribers of the run's events.
+	events *EventBus
+
+	// The newest commit date seen for each term, and
+	// whether the terms are searched from them.
+	watermarks    map[string]time.Time
+	useWatermarks bool
 }
 
+// Enums for what triggered a pipeline run.
+const (
+	TriggerCron  = "cron"
+	TriggerCLI   = "cli"
+	TriggerAdmin = "admin"
+)
+
 // Commits creates and returns a CommitPipeline type.
 func Commits(db db.Database) CommitPipeline {
 	badWords, err := db.AllBadWords()
@@ -36,19 +55,35 @@ func Commits(db db.Database) CommitPipeline {
 		panic(err)
 	}
 
+	blockWords, err := db.AllBlockWords()
+	if err != nil {
+		panic(err)
+	}
+
 	groupTerms, err := db.AllGroupTerms()
 	if err != nil {
 		panic(err)
 	}
 
+	stages := DefaultStages(
+		utils.NewMessageCleaner(badWords.ToStrings()),
+		utils.NewMessageBlocker(blockWords.ToStrings()),
+		utils.NewCommitGrouper(groupTerms.ToMap()),
+	)
+
+	// Score the commits, once a humor model was trained.
+	if scorer := LatestScorer(db); scorer != nil {
+		stages = append(stages, &ScoreStage{Scorer: scorer})
+	}
+
 	return CommitPipeline{
 		db:      db,
-		client:  github.NewClient(),
-		cleaner: utils.NewMessageCleaner(badWords.ToStrings()),
-		grouper: utils.NewCommitGrouper(groupTerms.ToMap()),
-		jobs:    make(chan string),
-		done:    make(chan bool),
+		sources: []Source{NewGithubSource()},
+		stages:  stages,
+		trigger: TriggerCLI,
 		now:     time.Now().UTC(),
+		budget:  config.App.APICallBudget,
+		events:  &EventBus{},
 	}
 }
 
@@ -60,6 +95,7 @@ func (c *CommitPipeline) WithRandomSearchTerms() CommitPipeline {
 		zap.S().Warn(err.Error())
 	}
 	c.terms = append(c.terms, randomTerms.ToStrings()...)
+	c.watermarks = randomTerms.Watermarks()
 	return *c
 }
 
@@ -70,6 +106,55 @@ func (c *CommitPipeline) WithSearchTerms(terms ...string) CommitPipeline {
 	return *c
 }
 
+// WithSources sets the sources that commits are fetched from.
+func (c *CommitPipeline) WithSources(sources ...Source) CommitPipeline {
+	c.sources = []Source{}
+	c.sources = append(c.sources, sources...)
+	return *c
+}
+
+// WithStages sets the stages that every commit goes through, in order.
+func (c *CommitPipeline) WithStages(stages ...Stage) CommitPipeline {
+	c.stages = []Stage{}
+	c.stages = append(c.stages, stages...)
+	return *c
+}
+
+// AddStages appends stages to the end of the pipeline's stages.
+func (c *CommitPipeline) AddStages(stages ...Stage) CommitPipeline {
+	c.stages = append(c.stages, stages...)
+	return *c
+}
+
+// WithWatermarks narrows the date range of every term, so that it
+// starts from the newest commit that was seen for the term.
+// Only the watermarks of the random search terms are known.
+func (c *CommitPipeline) WithWatermarks() CommitPipeline {
+	c.useWatermarks = true
+	return *c
+}
+
+// WithTrigger records what started the pipeline run.
+func (c *CommitPipeline) WithTrigger(trigger string) CommitPipeline {
+	c.trigger = trigger
+	return *c
+}
+
+// WithBudget sets the amount of API calls that a run can make.
+// Once the budget is spent, the search that spent it stops, and the remaining searches are skipped.
+func (c *CommitPipeline) WithBudget(budget int) CommitPipeline {
+	c.budget = budget
+	return *c
+}
+
+// WithSubscribers subscribes to the events of the pipeline runs.
+func (c *CommitPipeline) WithSubscribers(subscribers ...Subscriber) CommitPipeline {
+	for _, subscriber := range subscribers {
+		c.events.Subscribe(subscriber)
+	}
+	return *c
+}
+
 // WithOptions ...
 func (c *CommitPipeline) WithOptions(options github.CommitSearchOptions) CommitPipeline {
 	options.QueryText = ""
@@ -78,149 +163,243 @@ func (c *CommitPipeline) WithOptions(options github.CommitSearchOptions) CommitP
 	return *c
 }
 
-// Run ...
-func (c *CommitPipeline) Run() {
+// Run fetches and saves the commits of every term, from every source.
+// It returns once all the jobs are done, with a report of the run.
+// The report is also saved to the database, as the history of the runs.
+func (c *CommitPipeline) Run() Report {
 	zap.S().Info("pipeline.Run")
-	sentry.CaptureMessage("pipeline.Run")
+
+	report := Report{
+		Trigger:   c.trigger,
+		FromDate:  c.options.FromDate,
+		ToDate:    c.options.ToDate,
+		StartedAt: time.Now().UTC(),
+		Terms:     []*TermReport{},
+		Budget:    c.budget,
+	}
+	c.remaining = utils.NewCallBudget(c.budget)
+	c.events.Publish(newRunEvent(EventRunStarted, report.ToModel()))
 
 	// Exit if there are no terms.
 	if len(c.terms) == 0 {
 		zap.S().Warn("no terms in pipeline. exiting.")
-		return
+		return c.finish(report)
 	}
 
-	// Start the workers.
-	for i := 0; i < config.WorkerSize; i++ {
-		go c.worker(i)
+	// Exit if there are no sources.
+	if len(c.sources) == 0 {
+		zap.S().Warn("no sources in pipeline. exiting.")
+		return c.finish(report)
 	}
 
-	// Write jobs to the jobs channel.
-	go c.writeJobs()
+	// The crawlers cover the date range of the run, for every term.
+	for _, source := range c.sources {
+		if crawler, ok := source.(Crawler); ok {
+			crawler.SetRunOptions(c.options)
+		}
+	}
 
-	// Wait for all goroutines to finish.
-	for i := 0; i < len(c.terms); i++ {
-		<-c.done
+	// Every term is searched in every source, and each
+	// job reports its results into its own TermReport.
+	pending := make([]job, 0, len(c.terms)*len(c.sources))
+	for _, term := range c.terms {
+		for _, source := range c.sources {
+			termReport := newTermReport(term, source.Name())
+			report.Terms = append(report.Terms, termReport)
+			pending = append(pending, job{source: source, options: c.termOptions(term), report: termReport})
+		}
 	}
 
-	close(c.done)
-}
+	jobs := make(chan job)
 
-// writeJobs sends jobs to the jobs channel and then closes the channel.
-func (c *CommitPipeline) writeJobs() {
-	for _, term := range c.terms {
-		c.jobs <- term
+	// Start the workers. There's at least one, so the jobs are always consumed.
+	workers := config.App.WorkerSize
+	if workers < 1 {
+		workers = 1
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(ID int) {
+			defer wg.Done()
+			c.worker(ID, jobs)
+		}(i)
 	}
-	close(c.jobs)
+
+	// Write jobs to the jobs channel.
+	go c.writeJobs(jobs, pending)
+
+	// Wait for all workers to finish.
+	wg.Wait()
+
+	// The crawls are shared by the terms, and so are their API calls.
+	for _, source := range c.sources {
+		if _, ok := source.(Crawler); ok {
+			report.spreadCalls(source.Name())
+		}
+	}
+
+	return c.finish(report)
 }
 
-// worker consumes jobs from the jobs channel, and executes the work.
-func (c *CommitPipeline) worker(ID int) {
-	zap.S().Infof("worker %d started", ID)
-	for term := range c.jobs {
-		// Copy the options, and add the term.
-		options := c.options
-		options.QueryText = term
+// finish completes the report, and saves it as a PipelineRun.
+func (c *CommitPipeline) finish(report Report) Report {
+	report.FinishedAt = time.Now().UTC()
+	zap.S().Infof("pipeline.Run report\n%s", report)
 
-		// Perform the commit search.
-		commitItems, err := c.client.CommitSearchPaginated(options)
+	run := report.ToModel()
+	if err := c.db.CreatePipelineRun(&run); err != nil {
+		errMsg := fmt.Errorf("pipeline.finish:CreatePipelineRun: %v", err)
+		zap.S().Error(errMsg.Error())
+		sentry.CaptureException(errMsg)
+	}
+	c.events.Publish(newRunEvent(EventRunFinished, run))
 
-		if err != nil {
-			errMsg := fmt.Errorf("Error with pipeline.worker %d: %v", ID, err.Error())
-			zap.S().Errorf(errMsg.Error())
+	// Record when the terms were searched, and the newest commits they found.
+	if states := report.ToSearchTermStates(); len(states) > 0 {
+		if err := c.db.UpdateSearchTermStates(states); err != nil {
+			errMsg := fmt.Errorf("pipeline.finish:UpdateSearchTermStates: %v", err)
+			zap.S().Error(errMsg.Error())
 			sentry.CaptureException(errMsg)
+		}
+	}
 
-			// c.done <- true
-			continue
+	// Record the yield of the terms, for ranking them later on.
+	if histories := report.ToSearchHistories(run.ID); len(histories) > 0 {
+		if err := c.db.CreateSearchHistories(histories); err != nil {
+			errMsg := fmt.Errorf("pipeline.finish:CreateSearchHistories: %v", err)
+			zap.S().Error(errMsg.Error())
+			sentry.CaptureException(errMsg)
 		}
+	}
 
-		// Save commitItems to the database.
-		for _, commitItem := range commitItems {
-			err := c.save(commitItem)
+	return report
+}
 
-			if err == nil {
-				continue
-			}
+// termOptions returns the search options for the term.
+// With watermarks, the term is searched from the day of the newest commit
+// it has found, as long as that day falls within the date range of the options.
+func (c *CommitPipeline) termOptions(term string) github.CommitSearchOptions {
+	options := c.options
 
-			// If it's not a validation error, then it might
-			// be serious, so capture it with Sentry.
-			if _, ok := err.(github.ValidationError); !ok {
-				sentry.CaptureException(err)
-			}
-		}
+	watermark, ok := c.watermarks[term]
+	if !c.useWatermarks || !ok || !utils.IsValidDate(options.FromDate) || !utils.IsValidDate(options.ToDate) {
+		return options
+	}
 
-		c.done <- true
+	from := watermark.UTC().Format("2006-01-02")
+	if from > options.FromDate && from <= options.ToDate {
+		options.FromDate = from
 	}
-	zap.S().Infof("worker %d done", ID)
+
+	return options
+}
+
+// job is a search term to be fetched from a source.
+// The term is kept in the job's report.
+type job struct {
+	source  Source
+	options github.CommitSearchOptions
+	report  *TermReport
+}
+
+// writeJobs sends jobs to the jobs channel and then closes the channel.
+func (c *CommitPipeline) writeJobs(jobs chan<- job, pending []job) {
+	for _, j := range pending {
+		jobs <- j
+	}
+	close(jobs)
 }
 
-func (c *CommitPipeline) save(commitItem github.CommitItem) error {
-	// Skip if commitItem is not valid.
-	if err := commitItem.Validate(); err != nil {
-		return err
+// worker consumes jobs from the jobs channel, and executes the work.
+func (c *CommitPipeline) worker(ID int, jobs <-chan job) {
+	zap.S().Infof("worker %d started", ID)
+	for j := range jobs {
+		c.process(ID, j)
 	}
+	zap.S().Infof("worker %d done", ID)
+}
 
-	// Transfer data from response struct into model struct.
-	author := c.toAuthor(commitItem)
-	repo := c.toRepo(commitItem)
-	commit := c.toCommit(commitItem)
+// process searches the job's term in the job's source, and saves the results.
+func (c *CommitPipeline) process(ID int, j job) {
+	report := j.report
 
-	// GetOrCreate Author
-	if err := c.db.GetOrCreateUser(&author); err != nil {
-		return fmt.Errorf("pipeline.save:GetOrCreateUser: %v", err)
+	// Skip the search once the run has spent its budget.
+	if c.remaining.Spent() {
+		report.Skipped = true
+		return
 	}
 
-	// GetOrCreate Repo
-	if err := c.db.GetOrCreateRepo(&repo); err != nil {
-		return fmt.Errorf("pipeline.save:GetOrCreateRepo: %v", err)
+	// Perform the commit search.
+	commits, calls, err := j.source.Search(report.Term, j.options, c.remaining)
+	report.APICalls = calls
+
+	if err != nil {
+		errMsg := fmt.Errorf("Error with pipeline.worker %d (%s): %v", ID, j.source.Name(), err.Error())
+		zap.S().Errorf(errMsg.Error())
+		sentry.CaptureException(errMsg)
+		report.Errors = append(report.Errors, err.Error())
+		return
 	}
 
-	// Set the Commit's Author and Repo
-	commit.AuthorID = author.ID
-	commit.RepoID = repo.ID
+	report.Fetched = len(commits)
+	for _, commit := range commits {
+		if commit.Date.After(report.Newest) {
+			report.Newest = commit.Date
+		}
+	}
 
-	// Calculate commit colors (for frontend).
-	commit.SetColorTheme()
+	// Prepare the commits, and save the valid ones in a single batch.
+	batch := make(models.GitCommits, 0, len(commits))
+	for _, commit := range commits {
+		prepared, err := c.prepare(commit)
 
-	// Calculate the commit group.
-	commit.SetGroup(c.grouper)
+		if err == nil {
+			batch = append(batch, prepared)
+			continue
+		}
 
-	// Censor the commit message if necessary.
-	commit.SetCensoredMessage(c.cleaner)
+		if validationErr, ok := err.(github.ValidationError); ok {
+			report.Rejected[validationErr.Error()]++
+			continue
+		}
 
-	// Get or create commit.
-	if err := c.db.GetOrCreateCommit(&commit); err != nil {
-		return fmt.Errorf("pipeline.save:GetOrCreateCommit: %v", err)
+		// If it's not a validation error, then it might
+		// be serious, so capture it with Sentry.
+		sentry.CaptureException(err)
+		report.Errors = append(report.Errors, err.Error())
 	}
 
-	return nil
-}
+	if len(batch) == 0 {
+		return
+	}
 
-func (c *CommitPipeline) toAuthor(item github.CommitItem) models.GitUser {
-	return models.GitUser{
-		Source:    config.SourceGithub,
-		Username:  item.Author.Login,
-		URL:       item.Author.URL,
-		AvatarURL: item.Author.AvatarURL,
+	created, err := c.db.SaveCommitBatch(batch)
+	if err != nil {
+		errMsg := fmt.Errorf("pipeline.process:SaveCommitBatch: %v", err)
+		zap.S().Errorf(errMsg.Error())
+		sentry.CaptureException(errMsg)
+		report.Errors = append(report.Errors, errMsg.Error())
+		return
 	}
-}
 
-func (c *CommitPipeline) toRepo(item github.CommitItem) models.GitRepo {
-	return models.GitRepo{
-		Source:      config.SourceGithub,
-		Name:        item.Repo.Name,
-		Description: item.Repo.Description,
-		URL:         item.Repo.URL,
+	report.Saved += len(created)
+
+	for _, commit := range created {
+		c.events.Publish(newCommitEvent(commit))
 	}
+	report.Duplicates += len(batch) - len(created)
 }
 
-func (c *CommitPipeline) toCommit(item github.CommitItem) models.GitCommit {
-	return models.GitCommit{
-		Source:    config.SourceGithub,
-		Message:   item.Commit.Message,
-		SHA:       item.SHA,
-		URL:       item.URL,
-		Date:      item.Commit.Author.Date,
-		CreatedAt: c.now,
-		Valid:     true,
+// prepare passes the commit through the pipeline's stages, so it's ready to be saved.
+func (c *CommitPipeline) prepare(commit models.GitCommit) (models.GitCommit, error) {
+	if err := runStages(c.stages, &commit); err != nil {
+		return commit, err
 	}
+
+	commit.CreatedAt = c.now
+	commit.Valid = true
+
+	return commit, nil
 }
@@ -0,0 +1,536 @@
+package pipeline
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tunedmystic/commits.lol/app/clients/github"
+	"github.com/tunedmystic/commits.lol/app/config"
+	"github.com/tunedmystic/commits.lol/app/db"
+	"github.com/tunedmystic/commits.lol/app/models"
+	u "github.com/tunedmystic/commits.lol/app/utils"
+)
+
+// MockSource is a fake Source, used for testing.
+type MockSource struct {
+	NameMock   string
+	SearchMock func(term string, options github.CommitSearchOptions, budget *u.CallBudget) (models.GitCommits, int, error)
+}
+
+func (s *MockSource) Name() string {
+	return s.NameMock
+}
+
+func (s *MockSource) Search(term string, options github.CommitSearchOptions, budget *u.CallBudget) (models.GitCommits, int, error) {
+	return s.SearchMock(term, options, budget)
+}
+
+func mockCommit(message string) models.GitCommit {
+	return models.GitCommit{
+		Message: message,
+		URL:     "https://github.com/alice/gems/commit/" + message,
+		Author:  models.GitUser{Username: "alice", URL: "https://github.com/alice"},
+		Repo:    models.GitRepo{Name: "gems", URL: "https://github.com/alice/gems"},
+	}
+}
+
+func mockPipelineDB() *db.MockDB {
+	return &db.MockDB{
+		AllBadWordsMock: func() (models.BadWords, error) {
+			return models.BadWords{}, nil
+		},
+		AllBlockWordsMock: func() (models.BlockWords, error) {
+			return models.BlockWords{}, nil
+		},
+		AllGroupTermsMock: func() (models.GroupTerms, error) {
+			return models.GroupTerms{}, nil
+		},
+		LatestHumorModelMock: func() (*models.HumorModel, error) {
+			return nil, nil
+		},
+		SaveCommitBatchMock: func(commits models.GitCommits) (models.GitCommits, error) {
+			// Commits that mention "again" already exist.
+			created := models.GitCommits{}
+			for _, commit := range commits {
+				if !strings.Contains(commit.Message, "again") {
+					created = append(created, commit)
+				}
+			}
+			return created, nil
+		},
+		CreatePipelineRunMock: func(run *models.PipelineRun) error {
+			return nil
+		},
+		CreateSearchHistoriesMock: func(histories models.SearchHistories) error {
+			return nil
+		},
+		UpdateSearchTermStatesMock: func(states models.SearchTermStates) error {
+			return nil
+		},
+	}
+}
+
+func Test_Run_report(t *testing.T) {
+	source := &MockSource{
+		NameMock: "mock",
+		SearchMock: func(term string, options github.CommitSearchOptions, budget *u.CallBudget) (models.GitCommits, int, error) {
+			return models.GitCommits{
+				mockCommit("fixed a bug"),
+				mockCommit("fixed a bug again"),
+				mockCommit("fixed [a] bug"),
+				mockCommit("1 fixed a bug"),
+			}, 2, nil
+		},
+	}
+
+	p := Commits(mockPipelineDB())
+	p.WithSources(source)
+	p.WithSearchTerms("bug")
+	report := p.Run()
+
+	u.AssertEqual(t, len(report.Terms), 1)
+
+	termReport := report.Terms[0]
+	u.AssertEqual(t, termReport.Term, "bug")
+	u.AssertEqual(t, termReport.Source, "mock")
+	u.AssertEqual(t, termReport.Fetched, 4)
+	u.AssertEqual(t, termReport.Saved, 1)
+	u.AssertEqual(t, termReport.Duplicates, 1)
+	u.AssertEqual(t, termReport.RejectedCount(), 2)
+	u.AssertEqual(t, termReport.Rejected[github.ErrMessageFormat.Error()], 2)
+	u.AssertEqual(t, len(termReport.Errors), 0)
+	u.AssertEqual(t, termReport.APICalls, 2)
+}
+
+func Test_Run_with_memory_db(t *testing.T) {
+	mem := db.NewMemoryDB()
+	mem.AddBadWord("crap")
+	mem.AddGroupTerm("bug", "poop")
+	mem.AddSearchTerm("bug", 1)
+
+	source := &MockSource{
+		NameMock: "mock",
+		SearchMock: func(term string, options github.CommitSearchOptions, budget *u.CallBudget) (models.GitCommits, int, error) {
+			commits := models.GitCommits{
+				mockCommit("fixed a crap bug"),
+				mockCommit("Fixed a crap bug!!"), // same normalized message
+				mockCommit("fixed [a] bug"),
+			}
+			for i := range commits {
+				commits[i].Date = time.Now().UTC()
+			}
+			return commits, 1, nil
+		},
+	}
+
+	p := Commits(mem)
+	p.WithSources(source)
+	p.WithRandomSearchTerms()
+	report := p.Run()
+
+	u.AssertEqual(t, report.Terms[0].Term, "bug")
+	u.AssertEqual(t, report.Terms[0].Saved, 2)
+
+	// The commits are saved censored and grouped, and the near-duplicate is clustered.
+	commits, _ := mem.AllCommits()
+	u.AssertEqual(t, len(commits), 2)
+	u.AssertEqual(t, strings.Contains(commits[0].MessageCensored, `<span class="censored">`), true)
+	u.AssertEqual(t, commits[0].Group, "poop")
+	u.AssertEqual(t, commits[1].CanonicalID.Int64, int64(commits[0].ID))
+
+	// Only the canonical commit is on the homepage.
+	recent, _ := mem.RecentCommitsByGroup("poop", "")
+	u.AssertEqual(t, len(recent), 1)
+	u.AssertEqual(t, recent[0].ID, commits[0].ID)
+
+	// The run and the yield of the term are recorded.
+	runs, _ := mem.RecentPipelineRuns(10)
+	u.AssertEqual(t, len(runs), 1)
+	u.AssertEqual(t, runs[0].Saved, 2)
+
+	yields, _ := mem.SearchTermYields(time.Now().UTC().AddDate(0, 0, -1))
+	u.AssertEqual(t, yields[0].Searches, 1)
+	u.AssertEqual(t, yields[0].Saved, 2)
+	u.AssertEqual(t, yields[0].LastSearchedAt.Valid, true)
+
+	// The commits that were saved before are duplicates on the next run.
+	p = Commits(mem)
+	p.WithSources(source)
+	p.WithSearchTerms("bug")
+	report = p.Run()
+
+	u.AssertEqual(t, report.Terms[0].Saved, 0)
+	u.AssertEqual(t, report.Terms[0].Duplicates, 2)
+
+	commits, _ = mem.AllCommits()
+	u.AssertEqual(t, len(commits), 2)
+}
+
+func Test_Run_batch_error(t *testing.T) {
+	source := &MockSource{
+		NameMock: "mock",
+		SearchMock: func(term string, options github.CommitSearchOptions, budget *u.CallBudget) (models.GitCommits, int, error) {
+			return models.GitCommits{mockCommit("fixed a bug"), mockCommit("fixed a bug again")}, 1, nil
+		},
+	}
+
+	batches := 0
+	mockDB := mockPipelineDB()
+	mockDB.SaveCommitBatchMock = func(commits models.GitCommits) (models.GitCommits, error) {
+		batches++
+
+		// The commits are prepared before they're saved.
+		u.AssertEqual(t, commits[0].Valid, true)
+		u.AssertEqual(t, commits[0].MessageHash, u.MessageHash("fixed a bug"))
+		return nil, errors.New("database is locked")
+	}
+
+	p := Commits(mockDB)
+	p.WithSources(source)
+	p.WithSearchTerms("bug")
+	report := p.Run()
+
+	// The commits of a search are saved in a single batch.
+	u.AssertEqual(t, batches, 1)
+
+	termReport := report.Terms[0]
+	u.AssertEqual(t, termReport.Fetched, 2)
+	u.AssertEqual(t, termReport.Saved, 0)
+	u.AssertEqual(t, termReport.Errors[0], "pipeline.process:SaveCommitBatch: database is locked")
+}
+
+func Test_Run_saves_pipeline_run(t *testing.T) {
+	source := &MockSource{
+		NameMock: "mock",
+		SearchMock: func(term string, options github.CommitSearchOptions, budget *u.CallBudget) (models.GitCommits, int, error) {
+			if term == "boom" {
+				return nil, 1, errors.New("search failed")
+			}
+			return models.GitCommits{mockCommit("fixed a bug"), mockCommit("fixed [a] bug")}, 3, nil
+		},
+	}
+
+	runs := models.PipelineRuns{}
+	histories := models.SearchHistories{}
+	mockDB := mockPipelineDB()
+	mockDB.CreatePipelineRunMock = func(run *models.PipelineRun) error {
+		run.ID = 9
+		runs = append(runs, *run)
+		return nil
+	}
+	mockDB.CreateSearchHistoriesMock = func(h models.SearchHistories) error {
+		histories = append(histories, h...)
+		return nil
+	}
+
+	p := Commits(mockDB)
+	p.WithSources(source)
+	p.WithSearchTerms("bug", "boom")
+	p.WithOptions(github.CommitSearchOptions{FromDate: "2020-12-01", ToDate: "2020-12-03"})
+	p.WithTrigger(TriggerCron)
+	p.Run()
+
+	u.AssertEqual(t, len(runs), 1)
+
+	run := runs[0]
+	u.AssertEqual(t, run.Trigger, TriggerCron)
+	u.AssertEqual(t, run.FromDate, "2020-12-01")
+	u.AssertEqual(t, run.ToDate, "2020-12-03")
+	u.AssertEqual(t, run.Terms, `["bug","boom"]`)
+	u.AssertEqual(t, run.Sources, `["mock"]`)
+	u.AssertEqual(t, run.Fetched, 2)
+	u.AssertEqual(t, run.Saved, 1)
+	u.AssertEqual(t, run.Rejected, 1)
+	u.AssertEqual(t, run.Errors, `["search failed"]`)
+	u.AssertEqual(t, run.APICalls, 4)
+	u.AssertEqual(t, run.FinishedAt.Before(run.StartedAt), false)
+
+	// The yield of every term is recorded with the run.
+	u.AssertEqual(t, len(histories), 2)
+	u.AssertEqual(t, histories[0].RunID, 9)
+	u.AssertEqual(t, histories[0].Term, "bug")
+	u.AssertEqual(t, histories[0].APICalls, 3)
+	u.AssertEqual(t, histories[0].Saved, 1)
+	u.AssertEqual(t, histories[1].Term, "boom")
+	u.AssertEqual(t, histories[1].Saved, 0)
+}
+
+func Test_Run_with_watermarks(t *testing.T) {
+	mu := sync.Mutex{}
+	fromDates := map[string]string{}
+	source := &MockSource{
+		NameMock: "mock",
+		SearchMock: func(term string, options github.CommitSearchOptions, budget *u.CallBudget) (models.GitCommits, int, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			fromDates[term] = options.FromDate
+			return models.GitCommits{}, 1, nil
+		},
+	}
+
+	mockDB := mockPipelineDB()
+	mockDB.RandomSearchTermsMock = func() (models.SearchTermYields, error) {
+		yields := models.SearchTermYields{
+			{SearchTerm: models.SearchTerm{ID: 1, Text: "seen"}},
+			{SearchTerm: models.SearchTerm{ID: 2, Text: "unseen"}},
+			{SearchTerm: models.SearchTerm{ID: 3, Text: "seen long ago"}},
+		}
+		yields[0].Watermark = sql.NullTime{Time: time.Date(2020, 12, 10, 23, 0, 0, 0, time.UTC), Valid: true}
+		yields[2].Watermark = sql.NullTime{Time: time.Date(2020, 11, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+		return yields, nil
+	}
+
+	p := Commits(mockDB)
+	p.WithSources(source)
+	p.WithOptions(github.CommitSearchOptions{FromDate: "2020-12-01", ToDate: "2020-12-14"})
+	p.WithRandomSearchTerms()
+	p.WithWatermarks()
+	p.Run()
+
+	u.AssertEqual(t, fromDates["seen"], "2020-12-10")
+	u.AssertEqual(t, fromDates["unseen"], "2020-12-01")
+	u.AssertEqual(t, fromDates["seen long ago"], "2020-12-01")
+}
+
+// MockCrawler is a fake Crawler, used for testing.
+type MockCrawler struct {
+	MockSource
+}
+
+func (s *MockCrawler) SetRunOptions(options github.CommitSearchOptions) {}
+
+func Test_Run_with_crawler(t *testing.T) {
+	once := sync.Once{}
+	source := &MockCrawler{MockSource{
+		NameMock: "crawler",
+		SearchMock: func(term string, options github.CommitSearchOptions, budget *u.CallBudget) (models.GitCommits, int, error) {
+			// The first search crawls, with all the calls.
+			calls := 0
+			once.Do(func() { calls = 5 })
+			return models.GitCommits{}, calls, nil
+		},
+	}}
+
+	p := Commits(mockPipelineDB())
+	p.WithSearchTerms("bug", "lol", "wip")
+	p.WithSources(source)
+	report := p.Run()
+
+	// The calls of the crawl are split across the terms.
+	u.AssertEqual(t, report.Totals().APICalls, 5)
+	for _, term := range report.Terms {
+		u.AssertEqual(t, term.APICalls >= 1, true)
+	}
+	u.AssertEqual(t, len(report.ToSearchHistories(1)), 3)
+}
+
+func Test_Report_ToSearchTermStates(t *testing.T) {
+	started := time.Date(2020, 12, 14, 10, 0, 0, 0, time.UTC)
+	newest := time.Date(2020, 12, 13, 10, 0, 0, 0, time.UTC)
+	report := Report{
+		StartedAt: started,
+		Terms: []*TermReport{
+			{Term: "bug", Source: "github", Fetched: 2, APICalls: 1, Newest: newest.AddDate(0, 0, -1)},
+			{Term: "bug", Source: "gitlab", Fetched: 1, APICalls: 1, Newest: newest},
+			{Term: "lol", Source: "github", APICalls: 1},
+			{Term: "boom", Source: "github", APICalls: 1, Errors: []string{"search failed"}},
+			{Term: "local", Source: "local", Fetched: 3, Newest: newest},
+		},
+	}
+
+	states := report.ToSearchTermStates()
+
+	u.AssertEqual(t, len(states), 2)
+	u.AssertEqual(t, states[0].Term, "bug")
+	u.AssertEqual(t, states[0].LastSearchedAt, started)
+	u.AssertEqual(t, states[0].Watermark.Time, newest)
+
+	// Searches without results don't move the watermark.
+	u.AssertEqual(t, states[1].Term, "lol")
+	u.AssertEqual(t, states[1].Watermark.Valid, false)
+}
+
+func Test_Report_ToSearchHistories(t *testing.T) {
+	report := Report{
+		Terms: []*TermReport{
+			{Term: "bug", Source: "github", Saved: 2, APICalls: 3},
+			{Term: "bug", Source: "gitlab", Saved: 1, APICalls: 2},
+			{Term: "bug", Source: "local", Saved: 9},
+			{Term: "lol", Source: "local", Saved: 4},
+		},
+	}
+
+	histories := report.ToSearchHistories(1)
+
+	// Searches without API calls have no yield.
+	u.AssertEqual(t, len(histories), 1)
+	u.AssertEqual(t, histories[0].Saved, 3)
+	u.AssertEqual(t, histories[0].APICalls, 5)
+}
+
+func Test_Run_completes_when_searches_fail(t *testing.T) {
+	source := &MockSource{
+		NameMock: "mock",
+		SearchMock: func(term string, options github.CommitSearchOptions, budget *u.CallBudget) (models.GitCommits, int, error) {
+			if term == "boom" {
+				return nil, 1, errors.New("search failed")
+			}
+			return models.GitCommits{mockCommit("fixed a bug")}, 1, nil
+		},
+	}
+
+	p := Commits(mockPipelineDB())
+	p.WithSources(source)
+	p.WithSearchTerms("boom", "bug", "boom", "bugs", "boom", "more bugs")
+
+	// Run in a goroutine, so a hanging run fails the test instead of blocking it.
+	done := make(chan Report)
+	go func() {
+		done <- p.Run()
+	}()
+
+	select {
+	case report := <-done:
+		totals := report.Totals()
+		u.AssertEqual(t, len(report.Terms), 6)
+		u.AssertEqual(t, totals.Saved, 3)
+		u.AssertEqual(t, len(totals.Errors), 3)
+		u.AssertEqual(t, report.Terms[0].Errors[0], "search failed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("pipeline.Run did not complete")
+	}
+}
+
+func Test_Run_with_budget(t *testing.T) {
+	// A single worker, so the searches run one after the other.
+	config.App.WorkerSize = 1
+	defer func() { config.App.WorkerSize = 4 }()
+
+	searches := 0
+	source := &MockSource{
+		NameMock: "mock",
+		SearchMock: func(term string, options github.CommitSearchOptions, budget *u.CallBudget) (models.GitCommits, int, error) {
+			searches++
+
+			// Each search fetches 3 pages, until the budget is spent.
+			calls := 0
+			for page := 0; page < 3 && budget.Spend(); page++ {
+				calls++
+			}
+			return models.GitCommits{mockCommit("fixed a " + term)}, calls, nil
+		},
+	}
+
+	var saved models.PipelineRun
+	mockDB := mockPipelineDB()
+	mockDB.CreatePipelineRunMock = func(run *models.PipelineRun) error {
+		saved = *run
+		return nil
+	}
+
+	p := Commits(mockDB)
+	p.WithSources(source)
+	p.WithSearchTerms("bug", "typo", "mess", "hack")
+	p.WithBudget(5)
+	report := p.Run()
+
+	// The run stops searching once it spent its budget,
+	// and the search that spent it stops early.
+	u.AssertEqual(t, searches, 2)
+	u.AssertEqual(t, report.Totals().APICalls <= 5, true)
+	u.AssertEqual(t, report.Terms[1].APICalls, 2)
+	u.AssertEqual(t, report.Skipped(), 2)
+	u.AssertEqual(t, report.Terms[2].Skipped, true)
+	u.AssertEqual(t, len(report.ToSearchTermStates()), 2)
+	u.AssertEqual(t, strings.Contains(report.String(), "the API call budget of 5 was spent, 2 searches were skipped"), true)
+	u.AssertEqual(t, saved.ErrorList()[0], "the API call budget of 5 was spent, 2 searches were skipped")
+
+	// Without a budget, every term is searched.
+	searches = 0
+	p.WithBudget(0)
+	report = p.Run()
+	u.AssertEqual(t, searches, 4)
+	u.AssertEqual(t, report.Skipped(), 0)
+}
+
+// MockSubscriber records the events that it receives.
+type MockSubscriber struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (s *MockSubscriber) Notify(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+func Test_Run_publishes_events(t *testing.T) {
+	source := &MockSource{
+		NameMock: "mock",
+		SearchMock: func(term string, options github.CommitSearchOptions, budget *u.CallBudget) (models.GitCommits, int, error) {
+			return models.GitCommits{mockCommit("fixed a bug"), mockCommit("fixed a bug again")}, 1, nil
+		},
+	}
+
+	mockDB := mockPipelineDB()
+	mockDB.CreatePipelineRunMock = func(run *models.PipelineRun) error {
+		run.ID = 9
+		return nil
+	}
+
+	subscriber := &MockSubscriber{}
+
+	p := Commits(mockDB)
+	p.WithSources(source)
+	p.WithSearchTerms("bug")
+	p.WithTrigger(TriggerCron)
+	p.WithSubscribers(subscriber)
+	p.Run()
+
+	// Only the commits that were saved are published.
+	u.AssertEqual(t, len(subscriber.events), 3)
+	u.AssertEqual(t, subscriber.events[0].Type, EventRunStarted)
+	u.AssertEqual(t, subscriber.events[0].Run.Trigger, TriggerCron)
+	u.AssertEqual(t, subscriber.events[1].Type, EventCommitSaved)
+	u.AssertEqual(t, subscriber.events[1].Commit.Message, "fixed a bug")
+	u.AssertEqual(t, subscriber.events[2].Type, EventRunFinished)
+	u.AssertEqual(t, subscriber.events[2].Run.ID, 9)
+	u.AssertEqual(t, subscriber.events[2].Run.Saved, 1)
+}
+
+func Test_Run_no_terms(t *testing.T) {
+	saved := false
+	mockDB := mockPipelineDB()
+	mockDB.CreatePipelineRunMock = func(run *models.PipelineRun) error {
+		saved = true
+		return nil
+	}
+
+	p := Commits(mockDB)
+	report := p.Run()
+
+	u.AssertEqual(t, len(report.Terms), 0)
+	u.AssertEqual(t, report.Trigger, TriggerCLI)
+
+	// Empty runs are recorded too.
+	u.AssertEqual(t, saved, true)
+}
+
+func Test_Report_String(t *testing.T) {
+	report := Report{
+		Terms: []*TermReport{
+			{Term: "bug", Source: "github", Fetched: 3, Saved: 1, Rejected: map[string]int{"too long": 2}},
+			{Term: "lol", Source: "github", Errors: []string{"boom"}},
+		},
+	}
+
+	output := report.String()
+
+	u.AssertEqual(t, strings.Contains(output, "TOTAL"), true)
+	u.AssertEqual(t, strings.Contains(output, "rejected    2: too long"), true)
+}
@@ -0,0 +1,125 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tunedmystic/commits.lol/app/models"
+)
+
+// Enums for the events that the pipeline emits.
+const (
+	EventRunStarted  = "run.started"
+	EventRunFinished = "run.finished"
+	EventCommitSaved = "commit.saved"
+)
+
+// Event is something that happened in a pipeline run.
+// It's sent to the subscribers, and to the webhooks as JSON.
+type Event struct {
+	Type   string         `json:"event"`
+	Time   time.Time      `json:"time"`
+	Run    *RunPayload    `json:"run,omitempty"`
+	Commit *CommitPayload `json:"commit,omitempty"`
+}
+
+// RunPayload describes a pipeline run, in an Event.
+// The ID and the results are only set when the run is finished.
+type RunPayload struct {
+	ID         int      `json:"id,omitempty"`
+	Trigger    string   `json:"trigger"`
+	FromDate   string   `json:"from_date,omitempty"`
+	ToDate     string   `json:"to_date,omitempty"`
+	Fetched    int      `json:"fetched"`
+	Saved      int      `json:"saved"`
+	Duplicates int      `json:"duplicates"`
+	Rejected   int      `json:"rejected"`
+	APICalls   int      `json:"api_calls"`
+	Errors     []string `json:"errors"`
+}
+
+// CommitPayload describes a saved commit, in an Event.
+type CommitPayload struct {
+	ID        int       `json:"id"`
+	Message   string    `json:"message"`
+	Body      string    `json:"body,omitempty"`
+	Censored  bool      `json:"censored"` // The message has bad words.
+	URL       string    `json:"url"`
+	Date      time.Time `json:"date"`
+	Author    string    `json:"author"`
+	AuthorURL string    `json:"author_url"`
+	Repo      string    `json:"repo"`
+	RepoURL   string    `json:"repo_url"`
+	Group     string    `json:"group"`
+	Lang      string    `json:"lang,omitempty"`
+}
+
+// newRunEvent returns an Event of the given type, for the pipeline run.
+func newRunEvent(eventType string, run models.PipelineRun) Event {
+	return Event{
+		Type: eventType,
+		Time: time.Now().UTC(),
+		Run: &RunPayload{
+			ID:         run.ID,
+			Trigger:    run.Trigger,
+			FromDate:   run.FromDate,
+			ToDate:     run.ToDate,
+			Fetched:    run.Fetched,
+			Saved:      run.Saved,
+			Duplicates: run.Duplicates,
+			Rejected:   run.Rejected,
+			APICalls:   run.APICalls,
+			Errors:     run.ErrorList(),
+		},
+	}
+}
+
+// newCommitEvent returns a commit.saved Event, for the saved commit.
+func newCommitEvent(commit models.GitCommit) Event {
+	return Event{
+		Type: EventCommitSaved,
+		Time: time.Now().UTC(),
+		Commit: &CommitPayload{
+			ID:        commit.ID,
+			Message:   commit.Message,
+			Body:      commit.Body,
+			Censored:  commit.MessageCensored != "" || commit.BodyCensored != "",
+			URL:       commit.URL,
+			Date:      commit.Date,
+			Author:    commit.Author.Username,
+			AuthorURL: commit.Author.URL,
+			Repo:      commit.Repo.Name,
+			RepoURL:   commit.Repo.URL,
+			Group:     commit.Group,
+			Lang:      commit.Lang,
+		},
+	}
+}
+
+// Subscriber defines behavior for a receiver of the pipeline events.
+// The events are published from the workers, so Notify must be safe for concurrent use.
+type Subscriber interface {
+	Notify(event Event)
+}
+
+// EventBus sends the published events to every subscriber.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers []Subscriber
+}
+
+// Subscribe adds a subscriber to the bus.
+func (b *EventBus) Subscribe(subscriber Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, subscriber)
+}
+
+// Publish sends the event to every subscriber, in the order they subscribed.
+func (b *EventBus) Publish(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, subscriber := range b.subscribers {
+		subscriber.Notify(event)
+	}
+}
@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tunedmystic/commits.lol/app/db"
+	"github.com/tunedmystic/commits.lol/app/models"
+	"github.com/tunedmystic/commits.lol/app/utils"
+	"go.uber.org/zap"
+)
+
+// TrainHumorModel trains a new humor classifier from the labeled commits, and saves it.
+func TrainHumorModel(database db.Database) (*models.HumorModel, error) {
+	labels, err := database.CommitLabels()
+	if err != nil {
+		return nil, fmt.Errorf("pipeline.TrainHumorModel: %v", err)
+	}
+
+	if len(labels) == 0 {
+		return nil, fmt.Errorf("pipeline.TrainHumorModel: there are no labeled commits")
+	}
+
+	model := models.HumorModel{TrainedAt: time.Now().UTC()}
+	if err := model.SetClassifier(utils.TrainHumorClassifier(labels.ToExamples())); err != nil {
+		return nil, fmt.Errorf("pipeline.TrainHumorModel: %v", err)
+	}
+
+	if err := database.SaveHumorModel(&model); err != nil {
+		return nil, fmt.Errorf("pipeline.TrainHumorModel: %v", err)
+	}
+
+	return &model, nil
+}
+
+// LatestScorer returns the classifier of the most recently trained humor model,
+// or nil if there's no model yet.
+func LatestScorer(database db.Database) utils.Scorer {
+	model, err := database.LatestHumorModel()
+	if err != nil {
+		zap.S().Warnf("pipeline.LatestScorer: %v", err)
+		return nil
+	}
+	if model == nil {
+		return nil
+	}
+
+	classifier, err := model.Classifier()
+	if err != nil {
+		zap.S().Warnf("pipeline.LatestScorer: %v", err)
+		return nil
+	}
+	return classifier
+}
@@ -0,0 +1,41 @@
+package pipeline
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tunedmystic/commits.lol/app/config"
+	"github.com/tunedmystic/commits.lol/app/db"
+	"go.uber.org/zap"
+)
+
+// RankSearchTerms re-ranks the search terms by their recent yield.
+// Productive terms are promoted, and terms that stopped yielding commits are marked as dead.
+// Returns the amount of terms whose rank changed.
+func RankSearchTerms(database db.Database) (int, error) {
+	since := time.Now().UTC().AddDate(0, 0, -config.App.TermYieldDays)
+
+	yields, err := database.SearchTermYields(since)
+	if err != nil {
+		return 0, fmt.Errorf("pipeline.RankSearchTerms: %v", err)
+	}
+
+	ranks := yields.Ranks()
+	changed := 0
+
+	for _, term := range yields {
+		rank := ranks[term.ID]
+		if rank == term.Rank {
+			continue
+		}
+
+		if err := database.UpdateSearchTermRank(term.ID, rank); err != nil {
+			return changed, fmt.Errorf("pipeline.RankSearchTerms: %v", err)
+		}
+
+		zap.S().Infof("  Term [%s], rank %d -> %d (yield %.2f over %d searches)", term.Text, term.Rank, rank, term.Yield(), term.Searches)
+		changed++
+	}
+
+	return changed, nil
+}
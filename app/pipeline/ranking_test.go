@@ -0,0 +1,50 @@
+package pipeline
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tunedmystic/commits.lol/app/db"
+	"github.com/tunedmystic/commits.lol/app/models"
+	u "github.com/tunedmystic/commits.lol/app/utils"
+)
+
+func Test_RankSearchTerms(t *testing.T) {
+	updated := map[int]int{}
+	mockDB := db.MockDB{
+		SearchTermYieldsMock: func(since time.Time) (models.SearchTermYields, error) {
+			return models.SearchTermYields{
+				{SearchTerm: models.SearchTerm{ID: 1, Text: "lol", Rank: 3}, Searches: 4, APICalls: 8, Saved: 16},
+				{SearchTerm: models.SearchTerm{ID: 2, Text: "meh", Rank: 1}, Searches: 4, APICalls: 8, Saved: 0},
+				{SearchTerm: models.SearchTerm{ID: 3, Text: "new", Rank: 2}},
+			}, nil
+		},
+		UpdateSearchTermRankMock: func(ID, rank int) error {
+			updated[ID] = rank
+			return nil
+		},
+	}
+
+	changed, err := RankSearchTerms(&mockDB)
+
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, changed, 2)
+	u.AssertEqual(t, updated[1], 1)
+	u.AssertEqual(t, updated[2], models.RankDead)
+
+	// Unchanged ranks are not updated.
+	_, ok := updated[3]
+	u.AssertEqual(t, ok, false)
+}
+
+func Test_RankSearchTerms_DB_error(t *testing.T) {
+	mockDB := db.MockDB{
+		SearchTermYieldsMock: func(since time.Time) (models.SearchTermYields, error) {
+			return nil, errors.New("boom")
+		},
+	}
+
+	_, err := RankSearchTerms(&mockDB)
+	u.AssertEqual(t, err.Error(), "pipeline.RankSearchTerms: boom")
+}
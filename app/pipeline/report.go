@@ -0,0 +1,252 @@
+package pipeline
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tunedmystic/commits.lol/app/models"
+)
+
+// Report summarizes a pipeline run.
+type Report struct {
+	Trigger    string
+	FromDate   string
+	ToDate     string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Terms      []*TermReport
+	Budget     int // The API call budget of the run, or 0 if there's no limit.
+}
+
+// TermReport summarizes the results of a single term, searched in a single source.
+type TermReport struct {
+	Term       string
+	Source     string
+	Fetched    int
+	Saved      int
+	Duplicates int
+	Rejected   map[string]int // keyed by the ValidationError
+	Errors     []string
+	APICalls   int
+	Newest     time.Time // author date of the newest fetched commit
+	Skipped    bool      // the search was skipped, because the API call budget was spent
+}
+
+// newTermReport ...
+func newTermReport(term, source string) *TermReport {
+	return &TermReport{
+		Term:     term,
+		Source:   source,
+		Rejected: map[string]int{},
+		Errors:   []string{},
+	}
+}
+
+// RejectedCount returns the amount of rejected commits, for all reasons.
+func (t *TermReport) RejectedCount() int {
+	count := 0
+	for _, n := range t.Rejected {
+		count += n
+	}
+	return count
+}
+
+// Skipped returns the amount of searches that were skipped, because the API call budget was spent.
+func (r Report) Skipped() int {
+	count := 0
+	for _, t := range r.Terms {
+		if t.Skipped {
+			count++
+		}
+	}
+	return count
+}
+
+// Totals adds up the results of every term.
+func (r Report) Totals() TermReport {
+	totals := *newTermReport("", "")
+
+	for _, t := range r.Terms {
+		totals.Fetched += t.Fetched
+		totals.Saved += t.Saved
+		totals.Duplicates += t.Duplicates
+		totals.APICalls += t.APICalls
+		totals.Errors = append(totals.Errors, t.Errors...)
+		for reason, n := range t.Rejected {
+			totals.Rejected[reason] += n
+		}
+	}
+
+	return totals
+}
+
+// spreadCalls splits the API calls of the source's searches evenly across its terms.
+// A Crawler crawls once per run, so the search that triggered the crawl made all of its
+// calls, though every term is matched against the crawled commits. The skipped searches
+// didn't use the crawl, and get none.
+func (r Report) spreadCalls(source string) {
+	terms := []*TermReport{}
+	calls := 0
+	for _, t := range r.Terms {
+		if t.Source == source && !t.Skipped {
+			terms = append(terms, t)
+			calls += t.APICalls
+		}
+	}
+
+	for i, t := range terms {
+		t.APICalls = calls / len(terms)
+		if i < calls%len(terms) {
+			t.APICalls++
+		}
+	}
+}
+
+// ToModel converts the report into a PipelineRun, so it can be saved.
+func (r Report) ToModel() models.PipelineRun {
+	totals := r.Totals()
+
+	run := models.PipelineRun{
+		Trigger:    r.Trigger,
+		StartedAt:  r.StartedAt,
+		FinishedAt: r.FinishedAt,
+		FromDate:   r.FromDate,
+		ToDate:     r.ToDate,
+		Fetched:    totals.Fetched,
+		Saved:      totals.Saved,
+		Duplicates: totals.Duplicates,
+		Rejected:   totals.RejectedCount(),
+		APICalls:   totals.APICalls,
+	}
+
+	// Every term is searched in every source, so the
+	// terms and sources are repeated across the TermReports.
+	terms := []string{}
+	sources := []string{}
+	seenTerms := map[string]bool{}
+	seenSources := map[string]bool{}
+
+	for _, t := range r.Terms {
+		if !seenTerms[t.Term] {
+			seenTerms[t.Term] = true
+			terms = append(terms, t.Term)
+		}
+		if !seenSources[t.Source] {
+			seenSources[t.Source] = true
+			sources = append(sources, t.Source)
+		}
+	}
+
+	// A run that spent its budget is recorded with an error, so it stands out.
+	if skipped := r.Skipped(); skipped > 0 {
+		totals.Errors = append(totals.Errors, r.budgetError(skipped))
+	}
+
+	run.SetTerms(terms)
+	run.SetSources(sources)
+	run.SetErrors(totals.Errors)
+	return run
+}
+
+// ToSearchHistories returns the results of every term, summed across the sources.
+// Searches that didn't use an API (like local repositories) don't have a yield, and are skipped.
+func (r Report) ToSearchHistories(runID int) models.SearchHistories {
+	histories := models.SearchHistories{}
+	index := map[string]int{}
+
+	for _, t := range r.Terms {
+		if t.APICalls == 0 {
+			continue
+		}
+
+		i, ok := index[t.Term]
+		if !ok {
+			i = len(histories)
+			index[t.Term] = i
+			histories = append(histories, models.SearchHistory{
+				RunID:      runID,
+				Term:       t.Term,
+				SearchedAt: r.StartedAt,
+			})
+		}
+
+		histories[i].APICalls += t.APICalls
+		histories[i].Saved += t.Saved
+	}
+
+	return histories
+}
+
+// ToSearchTermStates returns the search state of every term that was searched.
+// Like the search histories, only searches that used an API are included.
+func (r Report) ToSearchTermStates() models.SearchTermStates {
+	states := models.SearchTermStates{}
+	index := map[string]int{}
+
+	for _, t := range r.Terms {
+		// Failed searches don't count, so the term stays stale.
+		if t.APICalls == 0 || (len(t.Errors) > 0 && t.Fetched == 0) {
+			continue
+		}
+
+		i, ok := index[t.Term]
+		if !ok {
+			i = len(states)
+			index[t.Term] = i
+			states = append(states, models.SearchTermState{
+				Term:           t.Term,
+				LastSearchedAt: r.StartedAt,
+			})
+		}
+
+		if !t.Newest.IsZero() && (!states[i].Watermark.Valid || t.Newest.After(states[i].Watermark.Time)) {
+			states[i].Watermark = sql.NullTime{Time: t.Newest.UTC(), Valid: true}
+		}
+	}
+
+	return states
+}
+
+// budgetError describes the searches that were skipped.
+func (r Report) budgetError(skipped int) string {
+	return fmt.Sprintf("the API call budget of %d was spent, %d searches were skipped", r.Budget, skipped)
+}
+
+// Duration returns how long the run took.
+func (r Report) Duration() time.Duration {
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// String renders the report as a table, for logging.
+func (r Report) String() string {
+	b := strings.Builder{}
+
+	fmt.Fprintf(&b, "%-30s %-10s %8s %6s %6s %9s %7s %6s\n", "TERM", "SOURCE", "FETCHED", "SAVED", "DUPES", "REJECTED", "ERRORS", "CALLS")
+	for _, t := range r.Terms {
+		fmt.Fprintf(&b, "%-30s %-10s %8d %6d %6d %9d %7d %6d\n", t.Term, t.Source, t.Fetched, t.Saved, t.Duplicates, t.RejectedCount(), len(t.Errors), t.APICalls)
+	}
+
+	totals := r.Totals()
+	fmt.Fprintf(&b, "%-30s %-10s %8d %6d %6d %9d %7d %6d\n", "TOTAL", "", totals.Fetched, totals.Saved, totals.Duplicates, totals.RejectedCount(), len(totals.Errors), totals.APICalls)
+
+	// Rejection reasons, sorted so the output is stable.
+	reasons := make([]string, 0, len(totals.Rejected))
+	for reason := range totals.Rejected {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	for _, reason := range reasons {
+		fmt.Fprintf(&b, "  rejected %4d: %s\n", totals.Rejected[reason], reason)
+	}
+
+	if skipped := r.Skipped(); skipped > 0 {
+		fmt.Fprintf(&b, "%s\n", r.budgetError(skipped))
+	}
+
+	fmt.Fprintf(&b, "took %v", r.Duration().Round(time.Millisecond))
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/tunedmystic/commits.lol/app/clients/github"
+	"github.com/tunedmystic/commits.lol/app/db"
+	"go.uber.org/zap"
+)
+
+// Reprocess passes the saved, valid commits through the stages, so that new stages
+// apply to the commits that were saved before them. The commits that a stage rejects
+// are flagged as not valid, and the commits that a stage changes are updated.
+// Returns the amount of commits that were flagged, and the amount that were updated.
+func Reprocess(database db.Database, stages ...Stage) (int, int, error) {
+	commits, err := database.AllCommits()
+	if err != nil {
+		return 0, 0, fmt.Errorf("pipeline.Reprocess: %v", err)
+	}
+
+	flagged, updated := 0, 0
+
+	for _, commit := range commits {
+		if !commit.Valid {
+			continue
+		}
+
+		processed := commit
+		err := runStages(stages, &processed)
+
+		if err != nil {
+			if _, ok := err.(github.ValidationError); !ok {
+				return flagged, updated, fmt.Errorf("pipeline.Reprocess: %v", err)
+			}
+
+			commit.Valid = false
+			if err := database.UpdateCommit(&commit); err != nil {
+				return flagged, updated, fmt.Errorf("pipeline.Reprocess: %v", err)
+			}
+
+			zap.S().Infof("  Commit %d [%s] flagged: %v", commit.ID, commit.Message, err)
+			flagged++
+			continue
+		}
+
+		if processed == commit {
+			continue
+		}
+
+		if err := database.UpdateCommit(&processed); err != nil {
+			return flagged, updated, fmt.Errorf("pipeline.Reprocess: %v", err)
+		}
+		updated++
+	}
+
+	return flagged, updated, nil
+}
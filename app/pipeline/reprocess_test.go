@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tunedmystic/commits.lol/app/db"
+	"github.com/tunedmystic/commits.lol/app/models"
+	u "github.com/tunedmystic/commits.lol/app/utils"
+)
+
+func Test_Reprocess(t *testing.T) {
+	bot := mockCommit("fixed a bug")
+	bot.ID = 2
+	bot.Author.Username = "dependabot[bot]"
+
+	commits := models.GitCommits{mockCommit("fixed a bug"), bot, mockCommit("Merge branch 'main'")}
+	commits[0].ID = 1
+	commits[2].ID = 3
+	for i := range commits {
+		commits[i].Valid = true
+	}
+
+	// Commits that are already flagged are skipped.
+	skipped := mockCommit("Initial commit")
+	skipped.ID = 4
+	commits = append(commits, skipped)
+
+	updated := models.GitCommits{}
+	mockDB := db.MockDB{
+		AllCommitsMock: func() (models.GitCommits, error) {
+			return commits, nil
+		},
+		UpdateCommitMock: func(commit *models.GitCommit) error {
+			updated = append(updated, *commit)
+			return nil
+		},
+	}
+
+	flagged, changed, err := Reprocess(&mockDB, &BotStage{Detector: u.NewDefaultBotDetector()})
+
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, flagged, 2)
+	u.AssertEqual(t, changed, 0)
+	u.AssertEqual(t, len(updated), 2)
+	u.AssertEqual(t, updated[0].ID, 2)
+	u.AssertEqual(t, updated[0].Valid, false)
+	u.AssertEqual(t, updated[1].ID, 3)
+}
+
+func Test_Reprocess_updates_changed_commits(t *testing.T) {
+	commits := models.GitCommits{mockCommit("fixed the stupid bug again"), mockCommit("fixed it")}
+	for i := range commits {
+		commits[i].ID = i + 1
+		commits[i].Valid = true
+	}
+
+	updated := models.GitCommits{}
+	mockDB := db.MockDB{
+		AllCommitsMock: func() (models.GitCommits, error) {
+			return commits, nil
+		},
+		UpdateCommitMock: func(commit *models.GitCommit) error {
+			updated = append(updated, *commit)
+			return nil
+		},
+	}
+
+	flagged, changed, err := Reprocess(&mockDB, &LanguageStage{Detector: u.NewTrigramLanguageDetector()})
+
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, flagged, 0)
+
+	// Only the commit whose language was detected is updated.
+	u.AssertEqual(t, changed, 1)
+	u.AssertEqual(t, updated[0].ID, 1)
+	u.AssertEqual(t, updated[0].Lang, "en")
+	u.AssertEqual(t, updated[0].Valid, true)
+}
+
+func Test_Reprocess_error(t *testing.T) {
+	mockDB := db.MockDB{
+		AllCommitsMock: func() (models.GitCommits, error) {
+			return nil, errors.New("no such table")
+		},
+	}
+
+	_, _, err := Reprocess(&mockDB)
+	u.AssertEqual(t, err.Error(), "pipeline.Reprocess: no such table")
+}
@@ -0,0 +1,629 @@
+package pipeline
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tunedmystic/commits.lol/app/clients/gharchive"
+	"github.com/tunedmystic/commits.lol/app/clients/gitea"
+	"github.com/tunedmystic/commits.lol/app/clients/github"
+	"github.com/tunedmystic/commits.lol/app/clients/gitlab"
+	"github.com/tunedmystic/commits.lol/app/clients/gitlog"
+	"github.com/tunedmystic/commits.lol/app/config"
+	"github.com/tunedmystic/commits.lol/app/models"
+	"github.com/tunedmystic/commits.lol/app/utils"
+	"go.uber.org/zap"
+)
+
+// Source defines behavior for a service that commits are collected from.
+// Commits are returned with their Author and Repo populated, ready to be saved,
+// along with the amount of API calls that the search consumed.
+// The calls are taken from the budget, and the search stops early once it's spent,
+// with the commits it found so far.
+type Source interface {
+	Name() string
+	Search(term string, options github.CommitSearchOptions, budget *utils.CallBudget) (models.GitCommits, int, error)
+}
+
+// Crawler is a Source that crawls its commits once per run, instead of searching for each term.
+// It's given the options of the run before the searches, as the terms can have narrower date ranges.
+// The search that triggers the crawl returns all of its API calls, and the pipeline splits them
+// across the terms.
+type Crawler interface {
+	Source
+	SetRunOptions(options github.CommitSearchOptions)
+}
+
+// ------------------------------------------------------------------
+// Github
+
+// GithubSource searches commits with the Github commit search API.
+type GithubSource struct {
+	client github.Client
+}
+
+// NewGithubSource ...
+func NewGithubSource() *GithubSource {
+	return &GithubSource{client: github.NewClient()}
+}
+
+// Name ...
+func (s *GithubSource) Name() string {
+	return "github"
+}
+
+// Search ...
+func (s *GithubSource) Search(term string, options github.CommitSearchOptions, budget *utils.CallBudget) (models.GitCommits, int, error) {
+	options.QueryText = term
+
+	// The jobs of a run share the source, so each search
+	// counts its API calls on its own copy of the client.
+	client := s.client
+	client.SetBudget(budget)
+
+	commitItems, err := client.CommitSearchPaginated(options)
+	if err != nil {
+		return nil, client.Calls(), err
+	}
+
+	commits := make(models.GitCommits, 0, len(commitItems))
+	for _, item := range commitItems {
+		commits = append(commits, s.toCommit(item))
+	}
+	return commits, client.Calls(), nil
+}
+
+func (s *GithubSource) toCommit(item github.CommitItem) models.GitCommit {
+	return models.GitCommit{
+		Source:  config.SourceGithub,
+		Message: item.Commit.Message,
+		SHA:     item.SHA,
+		URL:     item.URL,
+		Date:    item.Commit.Author.Date,
+		Author: models.GitUser{
+			Source:    config.SourceGithub,
+			Username:  item.Author.Login,
+			URL:       item.Author.URL,
+			AvatarURL: item.Author.AvatarURL,
+		},
+		Repo: models.GitRepo{
+			Source:      config.SourceGithub,
+			Name:        item.Repo.Name,
+			Description: item.Repo.Description,
+			URL:         item.Repo.URL,
+		},
+	}
+}
+
+// ------------------------------------------------------------------
+// Gitlab
+
+// GitlabSource searches commits with the Gitlab search API.
+// The projects and users of the commits are looked up, and cached for the run,
+// as the source is created for each run.
+type GitlabSource struct {
+	client gitlab.Client
+}
+
+// NewGitlabSource ...
+func NewGitlabSource() *GitlabSource {
+	return &GitlabSource{client: gitlab.NewClient()}
+}
+
+// Name ...
+func (s *GitlabSource) Name() string {
+	return "gitlab"
+}
+
+// Search ...
+// The Gitlab search API has no date qualifiers, so the
+// date range in the options is applied to the results instead.
+func (s *GitlabSource) Search(term string, options github.CommitSearchOptions, budget *utils.CallBudget) (models.GitCommits, int, error) {
+	// Count the API calls of this search on a copy of the client.
+	// The copies still share the project and user caches.
+	client := s.client
+	client.SetBudget(budget)
+
+	// The results are filtered by date before they're capped, so the cap counts the commits in range.
+	commitItems, err := client.CommitSearchPaginated(term, func(item gitlab.CommitItem) bool {
+		return inDateRange(item.AuthoredDate, options)
+	})
+	if err != nil {
+		return nil, client.Calls(), err
+	}
+
+	commits := make(models.GitCommits, 0, len(commitItems))
+	for _, item := range commitItems {
+		// The lookups are API calls, so the commits that would be rejected are skipped first.
+		subject := models.GitCommit{Message: strings.TrimSpace(item.Message)}
+		subject.SplitMessage()
+		if err := github.ValidateMessage(subject.Message); err != nil {
+			continue
+		}
+
+		// A failed lookup only skips its commit.
+		project, err := client.Project(item.ProjectID)
+		if err == utils.ErrBudgetSpent {
+			break
+		}
+		if err != nil {
+			zap.S().Warnf("    - gitlab: skipping commit %s, project %d: %v", item.ShortID, item.ProjectID, err)
+			continue
+		}
+
+		user, err := client.UserByEmail(item.AuthorEmail)
+		if err == utils.ErrBudgetSpent {
+			break
+		}
+		if err != nil {
+			zap.S().Warnf("    - gitlab: skipping commit %s, user: %v", item.ShortID, err)
+			continue
+		}
+
+		commits = append(commits, s.toCommit(item, project, user))
+	}
+	return commits, client.Calls(), nil
+}
+
+func (s *GitlabSource) toCommit(item gitlab.CommitItem, project gitlab.Project, user gitlab.User) models.GitCommit {
+	commitURL := item.WebURL
+	if commitURL == "" {
+		commitURL = fmt.Sprintf("%s/-/commit/%s", project.WebURL, item.ID)
+	}
+
+	commit := models.GitCommit{
+		Source:  config.SourceGitlab,
+		Message: strings.TrimSpace(item.Message),
+		SHA:     item.ID,
+		URL:     commitURL,
+		Date:    item.AuthoredDate,
+		Repo: models.GitRepo{
+			Source:      config.SourceGitlab,
+			Name:        project.Name,
+			Description: project.Description,
+			URL:         project.WebURL,
+		},
+	}
+
+	// Commits by authors without an account are left without
+	// an Author, and will not pass validation.
+	if user.WebURL != "" {
+		commit.Author = models.GitUser{
+			Source:    config.SourceGitlab,
+			Username:  user.Username,
+			URL:       user.WebURL,
+			AvatarURL: user.AvatarURL,
+		}
+	}
+
+	return commit
+}
+
+// ------------------------------------------------------------------
+// Gitea
+
+// GiteaSource crawls the recently updated repositories of a Gitea (or Forgejo) instance.
+// Gitea has no global commit search, so the instance is crawled once
+// per pipeline run, and the crawled commits are matched against each term.
+type GiteaSource struct {
+	client gitea.Client
+
+	// The options of the run, which the crawl covers.
+	runOptions github.CommitSearchOptions
+
+	once    sync.Once
+	commits models.GitCommits
+	err     error
+}
+
+// NewGiteaSource ...
+func NewGiteaSource(baseURL string) *GiteaSource {
+	return &GiteaSource{client: gitea.NewClient(baseURL)}
+}
+
+// Name ...
+func (s *GiteaSource) Name() string {
+	return "gitea:" + s.client.BaseURL()
+}
+
+// SetRunOptions sets the options of the run, so the crawl covers the date range of every term.
+func (s *GiteaSource) SetRunOptions(options github.CommitSearchOptions) {
+	s.runOptions = options
+}
+
+// Search ...
+// The API calls of the crawl are returned by the search that triggered it,
+// and the pipeline splits them across the terms.
+// The crawled commits are filtered by the date range of each term.
+func (s *GiteaSource) Search(term string, options github.CommitSearchOptions, budget *utils.CallBudget) (models.GitCommits, int, error) {
+	calls := 0
+	s.once.Do(func() {
+		client := s.client
+		client.SetBudget(budget)
+		s.commits, s.err = s.crawl(&client, s.runOptions)
+		calls = client.Calls()
+	})
+
+	if s.err != nil {
+		return nil, calls, s.err
+	}
+
+	commits := models.GitCommits{}
+	for _, commit := range s.commits {
+		if matchesTerm(commit.Message, term) && inDateRange(commit.Date, options) {
+			commits = append(commits, commit)
+		}
+	}
+	return commits, calls, nil
+}
+
+// crawl fetches the commit lists of the recently updated repositories,
+// made since the start of the run's date range.
+func (s *GiteaSource) crawl(client *gitea.Client, options github.CommitSearchOptions) (models.GitCommits, error) {
+	zap.S().Infof("  Crawling %s", s.Name())
+
+	repos, err := client.RecentRepos()
+	if err == utils.ErrBudgetSpent {
+		zap.S().Warnf("  %s: the API call budget was spent, nothing was crawled", s.Name())
+		return models.GitCommits{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	since := time.Time{}
+	if utils.IsValidDate(options.FromDate) {
+		since = utils.MustParseDate(options.FromDate)
+	}
+
+	commits := models.GitCommits{}
+	for i, repo := range repos {
+		// Repos that can't be read (empty, mirrors in progress, etc.) are skipped.
+		items, err := client.RepoCommits(repo.FullName, since)
+		if err == utils.ErrBudgetSpent {
+			zap.S().Warnf("    - %s: the API call budget was spent, %d repos were not crawled", s.Name(), len(repos)-i)
+			break
+		}
+		if err != nil {
+			zap.S().Warnf("    - %s: skipping repo %s: %v", s.Name(), repo.FullName, err)
+			continue
+		}
+
+		for _, item := range items {
+			commits = append(commits, s.toCommit(item, repo))
+		}
+	}
+
+	zap.S().Infof("  Crawled %s, total fetched: %d", s.Name(), len(commits))
+	return commits, nil
+}
+
+func (s *GiteaSource) toCommit(item gitea.CommitItem, repo gitea.Repository) models.GitCommit {
+	commit := models.GitCommit{
+		Source:  config.SourceGitea,
+		Message: strings.TrimSpace(item.Commit.Message),
+		SHA:     item.SHA,
+		URL:     item.URL,
+		Date:    item.Commit.Author.Date,
+		Repo: models.GitRepo{
+			Source:      config.SourceGitea,
+			Name:        repo.Name,
+			Description: repo.Description,
+			URL:         repo.URL,
+		},
+	}
+
+	// Commits by authors without an account are left without
+	// an Author, and will not pass validation.
+	if item.Author != nil && item.Author.Login != "" {
+		authorURL := item.Author.URL
+		if authorURL == "" {
+			authorURL = fmt.Sprintf("%s/%s", s.client.BaseURL(), item.Author.Login)
+		}
+
+		commit.Author = models.GitUser{
+			Source:    config.SourceGitea,
+			Username:  item.Author.Login,
+			URL:       authorURL,
+			AvatarURL: item.Author.AvatarURL,
+		}
+	}
+
+	return commit
+}
+
+// ------------------------------------------------------------------
+// Local
+
+// LocalSource walks the history of local git repositories.
+// The history is read once, and only the commits that match
+// one of the terms are kept, so large monorepos stay cheap to scan.
+type LocalSource struct {
+	repos []gitlog.Repository
+	terms []string
+
+	once    sync.Once
+	commits models.GitCommits
+	err     error
+}
+
+// NewLocalSource creates a source for the repository at the path,
+// or for every repository in the path if it is a directory of clones.
+func NewLocalSource(path string, terms []string) (*LocalSource, error) {
+	repos, err := gitlog.FindRepositories(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalSource{repos: repos, terms: terms}, nil
+}
+
+// Name ...
+func (s *LocalSource) Name() string {
+	return "local"
+}
+
+// Search ...
+func (s *LocalSource) Search(term string, options github.CommitSearchOptions, budget *utils.CallBudget) (models.GitCommits, int, error) {
+	s.once.Do(func() {
+		s.commits, s.err = s.scan()
+	})
+
+	if s.err != nil {
+		return nil, 0, s.err
+	}
+
+	commits := models.GitCommits{}
+	for _, commit := range s.commits {
+		if matchesTerm(commit.Message, term) && inDateRange(commit.Date, options) {
+			commits = append(commits, commit)
+		}
+	}
+	return commits, 0, nil
+}
+
+// scan walks the history of every repository.
+func (s *LocalSource) scan() (models.GitCommits, error) {
+	commits := models.GitCommits{}
+
+	for _, repo := range s.repos {
+		zap.S().Infof("  Scanning %s", repo.Path)
+
+		model := s.toRepo(repo)
+		scanned := 0
+
+		err := repo.Log(func(item gitlog.CommitItem) {
+			scanned++
+			for _, term := range s.terms {
+				if matchesTerm(item.Message, term) {
+					commits = append(commits, s.toCommit(item, model))
+					return
+				}
+			}
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("scanning %s: %v", repo.Path, err)
+		}
+
+		zap.S().Infof("  Scanned %s, %d commits", repo.Path, scanned)
+	}
+
+	return commits, nil
+}
+
+func (s *LocalSource) toRepo(repo gitlog.Repository) models.GitRepo {
+	repoURL := repo.RemoteURL()
+	if repoURL == "" {
+		repoURL = "file://" + repo.Path
+	}
+
+	return models.GitRepo{
+		Source: config.SourceLocal,
+		Name:   repo.Name(),
+		URL:    repoURL,
+	}
+}
+
+func (s *LocalSource) toCommit(item gitlog.CommitItem, repo models.GitRepo) models.GitCommit {
+	commit := models.GitCommit{
+		Source:  config.SourceLocal,
+		Message: item.Message,
+		SHA:     item.SHA,
+		URL:     fmt.Sprintf("%s/commit/%s", repo.URL, item.SHA),
+		Date:    item.Date,
+		Repo:    repo,
+	}
+
+	// There are no accounts to link to, so the authors link to the repo, by name.
+	// Their emails are never published, and they have no avatar.
+	if name := strings.TrimSpace(item.AuthorName); name != "" {
+		commit.Author = models.GitUser{
+			Source:   config.SourceLocal,
+			Username: name,
+			URL:      repo.URL + "#" + url.PathEscape(name),
+		}
+	}
+
+	return commit
+}
+
+// ------------------------------------------------------------------
+// GH Archive
+
+// ArchiveSource reads commits from the PushEvents of hourly GH Archive dumps.
+// Like the LocalSource, the files are read once, and only the
+// commits that match one of the terms are kept.
+type ArchiveSource struct {
+	files []string
+	terms []string
+
+	once    sync.Once
+	commits models.GitCommits
+	err     error
+}
+
+// NewArchiveSource creates a source for the archive file at the path,
+// or for every archive file in the path if it is a directory.
+func NewArchiveSource(path string, terms []string) (*ArchiveSource, error) {
+	files, err := gharchive.FindFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ArchiveSource{files: files, terms: terms}, nil
+}
+
+// Name ...
+func (s *ArchiveSource) Name() string {
+	return "gharchive"
+}
+
+// Search ...
+func (s *ArchiveSource) Search(term string, options github.CommitSearchOptions, budget *utils.CallBudget) (models.GitCommits, int, error) {
+	s.once.Do(func() {
+		s.commits, s.err = s.read()
+	})
+
+	if s.err != nil {
+		return nil, 0, s.err
+	}
+
+	commits := models.GitCommits{}
+	for _, commit := range s.commits {
+		if matchesTerm(commit.Message, term) && inDateRange(commit.Date, options) {
+			commits = append(commits, commit)
+		}
+	}
+	return commits, 0, nil
+}
+
+// read extracts the matching commits from every archive file.
+func (s *ArchiveSource) read() (models.GitCommits, error) {
+	commits := models.GitCommits{}
+
+	for _, file := range s.files {
+		zap.S().Infof("  Reading %s", file)
+		scanned := 0
+
+		err := gharchive.ReadPushEvents(file, func(event gharchive.PushEvent) {
+			for _, item := range event.Commits {
+				// Commits that were already pushed to another branch are not distinct.
+				if !item.Distinct {
+					continue
+				}
+
+				scanned++
+
+				// The pusher can push the commits of others, which can't be credited to them.
+				if !pushedByAuthor(item, event.Actor) {
+					continue
+				}
+
+				for _, term := range s.terms {
+					if matchesTerm(item.Message, term) {
+						commits = append(commits, s.toCommit(item, event))
+						break
+					}
+				}
+			}
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", file, err)
+		}
+
+		zap.S().Infof("  Read %s, %d commits", file, scanned)
+	}
+
+	return commits, nil
+}
+
+// toCommit converts an archived commit into a Github commit.
+// The archive only has the name and email of the commit author,
+// so the pusher's account is used as the Author, and the push date as the Date.
+// Only the commits that were pushed by their author are converted (see pushedByAuthor).
+func (s *ArchiveSource) toCommit(item gharchive.PushCommit, event gharchive.PushEvent) models.GitCommit {
+	repoURL := "https://github.com/" + event.Repo.Name
+	repoName := event.Repo.Name
+	if i := strings.LastIndex(repoName, "/"); i >= 0 {
+		repoName = repoName[i+1:]
+	}
+
+	commit := models.GitCommit{
+		Source:  config.SourceGithub,
+		Message: strings.TrimSpace(item.Message),
+		SHA:     item.SHA,
+		URL:     fmt.Sprintf("%s/commit/%s", repoURL, item.SHA),
+		Date:    event.CreatedAt,
+		Repo: models.GitRepo{
+			Source: config.SourceGithub,
+			Name:   repoName,
+			URL:    repoURL,
+		},
+	}
+
+	if event.Actor.Login != "" {
+		commit.Author = models.GitUser{
+			Source:    config.SourceGithub,
+			Username:  event.Actor.Login,
+			URL:       "https://github.com/" + event.Actor.Login,
+			AvatarURL: event.Actor.AvatarURL,
+		}
+	}
+
+	return commit
+}
+
+// pushedByAuthor checks if the commit's author is the pusher. The author's name must be the
+// pusher's login, or the email must be the pusher's, like the noreply email of Github
+// (<id>+<login>@users.noreply.github.com) or <login>@<domain>.
+func pushedByAuthor(item gharchive.PushCommit, actor gharchive.Actor) bool {
+	if actor.Login == "" {
+		return false
+	}
+
+	if strings.EqualFold(item.Author.Name, actor.Login) {
+		return true
+	}
+
+	local := item.Author.Email
+	if i := strings.LastIndex(local, "@"); i >= 0 {
+		local = local[:i]
+	}
+	if i := strings.Index(local, "+"); i >= 0 {
+		local = local[i+1:]
+	}
+	return strings.EqualFold(local, actor.Login)
+}
+
+// ------------------------------------------------------------------
+
+// matchesTerm checks if the message contains the search term (case-insensitive).
+// It is used by the sources that don't have a search API of their own.
+func matchesTerm(message, term string) bool {
+	return strings.Contains(strings.ToLower(message), strings.ToLower(term))
+}
+
+// inDateRange checks if the date falls within the (inclusive) date range of the options.
+// Options without a valid date range match every date.
+func inDateRange(date time.Time, options github.CommitSearchOptions) bool {
+	if !utils.IsValidDate(options.FromDate) || !utils.IsValidDate(options.ToDate) {
+		return true
+	}
+
+	from := utils.MustParseDate(options.FromDate)
+	to := utils.MustParseDate(options.ToDate).AddDate(0, 0, 1)
+
+	return !date.Before(from) && date.Before(to)
+}
+
+// Ensure the source types satisfy the Source interface.
+var _ Source = &GithubSource{}
+var _ Source = &GitlabSource{}
+var _ Crawler = &GiteaSource{}
+var _ Source = &LocalSource{}
+var _ Source = &ArchiveSource{}
@@ -0,0 +1,386 @@
+package pipeline
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tunedmystic/commits.lol/app/clients/gharchive"
+	"github.com/tunedmystic/commits.lol/app/clients/github"
+	"github.com/tunedmystic/commits.lol/app/clients/gitlab"
+	"github.com/tunedmystic/commits.lol/app/config"
+	"github.com/tunedmystic/commits.lol/app/db"
+	"github.com/tunedmystic/commits.lol/app/models"
+	u "github.com/tunedmystic/commits.lol/app/utils"
+)
+
+func Test_GithubSource_toCommit(t *testing.T) {
+	item := github.CommitItem{
+		URL: "https://github.com/alice/gems/commit/abc123",
+		SHA: "abc123",
+		Commit: github.Commit{
+			Message: "fixed a bug",
+		},
+		Author: github.User{Login: "alice", URL: "https://github.com/alice"},
+		Repo:   github.Repository{Name: "gems", URL: "https://github.com/alice/gems"},
+	}
+
+	commit := (&GithubSource{}).toCommit(item)
+
+	u.AssertEqual(t, commit.Source, config.SourceGithub)
+	u.AssertEqual(t, commit.Message, "fixed a bug")
+	u.AssertEqual(t, commit.Author.Username, "alice")
+	u.AssertEqual(t, commit.Author.Source, config.SourceGithub)
+	u.AssertEqual(t, commit.Repo.URL, "https://github.com/alice/gems")
+}
+
+func Test_GitlabSource_toCommit(t *testing.T) {
+	item := gitlab.CommitItem{
+		ID:      "abc123",
+		Message: "fixed a bug\n",
+	}
+	project := gitlab.Project{Name: "gems", WebURL: "https://gitlab.example.com/team/gems"}
+	user := gitlab.User{Username: "alice", WebURL: "https://gitlab.example.com/alice"}
+
+	commit := (&GitlabSource{}).toCommit(item, project, user)
+
+	u.AssertEqual(t, commit.Source, config.SourceGitlab)
+	u.AssertEqual(t, commit.Message, "fixed a bug")
+	u.AssertEqual(t, commit.URL, "https://gitlab.example.com/team/gems/-/commit/abc123")
+	u.AssertEqual(t, commit.Author.Username, "alice")
+	u.AssertEqual(t, commit.Author.Source, config.SourceGitlab)
+	u.AssertEqual(t, commit.Repo.Name, "gems")
+}
+
+func Test_GitlabSource_toCommit_without_user(t *testing.T) {
+	item := gitlab.CommitItem{ID: "abc123", Message: "fixed a bug"}
+	project := gitlab.Project{Name: "gems", WebURL: "https://gitlab.example.com/team/gems"}
+
+	commit := (&GitlabSource{}).toCommit(item, project, gitlab.User{})
+
+	// Commits without an author are rejected by the pipeline.
+	u.AssertEqual(t, (&ValidateStage{}).Process(&commit), github.ErrNoAuthor)
+}
+
+func Test_GitlabSource_Search(t *testing.T) {
+	lookups := map[string]int{}
+	s := gitlabTestServer(lookups)
+	defer s.Close()
+
+	config.App.GitlabBaseURL = s.URL
+	defer func() { config.App.GitlabBaseURL = "https://gitlab.com" }()
+
+	source := NewGitlabSource()
+
+	// The commit of the missing project is skipped, along with the invalid message.
+	commits, calls, err := source.Search("bug", github.CommitSearchOptions{}, nil)
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(commits), 2)
+	u.AssertEqual(t, commits[0].Message, "fixed a bug")
+	u.AssertEqual(t, commits[0].Author.Username, "alice")
+	u.AssertEqual(t, commits[0].Repo.Name, "gems")
+	u.AssertEqual(t, commits[1].Message, "fixed a bug again")
+	u.AssertEqual(t, calls, 4)
+
+	// The invalid message isn't looked up, and the lookups are cached for the run.
+	u.AssertEqual(t, lookups["/api/v4/projects/6"], 1)
+	u.AssertEqual(t, lookups["/api/v4/projects/7"], 1)
+	u.AssertEqual(t, lookups["/api/v4/users"], 1)
+
+	_, calls, _ = source.Search("bug", github.CommitSearchOptions{}, nil)
+	u.AssertEqual(t, calls, 2)
+	u.AssertEqual(t, lookups["/api/v4/projects/6"], 1)
+}
+
+func Test_inDateRange(t *testing.T) {
+	options := github.CommitSearchOptions{FromDate: "2020-12-01", ToDate: "2020-12-02"}
+
+	u.AssertEqual(t, inDateRange(time.Date(2020, 11, 30, 23, 0, 0, 0, time.UTC), options), false)
+	u.AssertEqual(t, inDateRange(time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC), options), true)
+	u.AssertEqual(t, inDateRange(time.Date(2020, 12, 2, 23, 0, 0, 0, time.UTC), options), true)
+	u.AssertEqual(t, inDateRange(time.Date(2020, 12, 3, 0, 0, 0, 0, time.UTC), options), false)
+
+	// Options without dates match everything.
+	u.AssertEqual(t, inDateRange(time.Now(), github.CommitSearchOptions{}), true)
+}
+
+func Test_GiteaSource_Search(t *testing.T) {
+	s := giteaTestServer(nil)
+	defer s.Close()
+
+	source := NewGiteaSource(s.URL)
+
+	commits, calls, err := source.Search("stupid", github.CommitSearchOptions{}, nil)
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, calls, 2)
+	u.AssertEqual(t, len(commits), 3)
+	u.AssertEqual(t, commits[0].Source, config.SourceGitea)
+	u.AssertEqual(t, commits[0].Message, "fixed a stupid bug lol")
+	u.AssertEqual(t, commits[0].Author.URL, s.URL+"/alice")
+	u.AssertEqual(t, commits[0].Repo.Name, "gems")
+
+	// The crawled commits are reused for the next term, without any API calls.
+	commits, calls, err = source.Search("LOL", github.CommitSearchOptions{}, nil)
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, calls, 0)
+	u.AssertEqual(t, len(commits), 1)
+
+	// The date range of the options applies to the crawled commits.
+	options := github.CommitSearchOptions{FromDate: "2020-12-02", ToDate: "2020-12-03"}
+	commits, _, err = source.Search("stupid", options, nil)
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(commits), 2)
+}
+
+func Test_Pipeline_with_GiteaSource(t *testing.T) {
+	s := giteaTestServer(nil)
+	defer s.Close()
+
+	mu := sync.Mutex{}
+	saved := models.GitCommits{}
+
+	mockDB := db.MockDB{
+		AllBadWordsMock: func() (models.BadWords, error) {
+			return models.BadWords{{ID: 1, Text: "stupid"}}, nil
+		},
+		AllBlockWordsMock: func() (models.BlockWords, error) {
+			return models.BlockWords{}, nil
+		},
+		AllGroupTermsMock: func() (models.GroupTerms, error) {
+			return models.GroupTerms{{ID: 1, Text: "lol", Group: "funny"}}, nil
+		},
+		LatestHumorModelMock: func() (*models.HumorModel, error) {
+			return nil, nil
+		},
+		SaveCommitBatchMock: func(commits models.GitCommits) (models.GitCommits, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			saved = append(saved, commits...)
+			return commits, nil
+		},
+		CreatePipelineRunMock: func(run *models.PipelineRun) error {
+			return nil
+		},
+		CreateSearchHistoriesMock: func(histories models.SearchHistories) error {
+			return nil
+		},
+		UpdateSearchTermStatesMock: func(states models.SearchTermStates) error {
+			return nil
+		},
+	}
+
+	p := Commits(&mockDB)
+	p.WithSources(NewGiteaSource(s.URL))
+	p.WithSearchTerms("stupid")
+	p.Run()
+
+	// Only the valid commit is saved. The others have
+	// formatting issues, or are missing an author.
+	u.AssertEqual(t, len(saved), 1)
+	u.AssertEqual(t, saved[0].Message, "fixed a stupid bug lol")
+	u.AssertEqual(t, saved[0].Group, "funny")
+	u.AssertEqual(t, saved[0].MessageCensored != "", true)
+}
+
+func Test_GiteaSource_Search_run_options(t *testing.T) {
+	since := ""
+	s := giteaTestServer(&since)
+	defer s.Close()
+
+	source := NewGiteaSource(s.URL)
+	source.SetRunOptions(github.CommitSearchOptions{FromDate: "2020-12-01", ToDate: "2020-12-05"})
+
+	// The instance is crawled from the start of the run, even if the first term starts later.
+	commits, _, err := source.Search("stupid", github.CommitSearchOptions{FromDate: "2020-12-03", ToDate: "2020-12-05"}, nil)
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, since, "2020-12-01T00:00:00Z")
+	u.AssertEqual(t, len(commits), 1)
+	u.AssertEqual(t, commits[0].Message, "fixed a stupid bug lol")
+
+	// The other terms find the commits before the first term's date range.
+	commits, _, err = source.Search("typo", github.CommitSearchOptions{FromDate: "2020-12-01", ToDate: "2020-12-05"}, nil)
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(commits), 1)
+	u.AssertEqual(t, commits[0].Message, "stupid typo")
+}
+
+func Test_LocalSource_Search(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pipeline")
+	defer os.RemoveAll(dir)
+
+	git := func(args ...string) {
+		args = append([]string{"-C", dir, "-c", "user.name=Alice", "-c", "user.email=Alice@Example.com"}, args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("commit", "-q", "--allow-empty", "-m", "fixed a stupid bug lol")
+	git("commit", "-q", "--allow-empty", "-m", "stupid typo")
+	git("commit", "-q", "--allow-empty", "-m", "boring commit")
+
+	source, err := NewLocalSource(dir, []string{"stupid", "lol"})
+	u.AssertEqual(t, err, nil)
+
+	commits, _, err := source.Search("stupid", github.CommitSearchOptions{}, nil)
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(commits), 2)
+
+	commit := commits[1]
+	u.AssertEqual(t, commit.Source, config.SourceLocal)
+	u.AssertEqual(t, commit.Message, "fixed a stupid bug lol")
+	u.AssertEqual(t, commit.Author.Username, "Alice")
+	u.AssertEqual(t, commit.Repo.URL, "file://"+dir)
+
+	// The email of the author isn't published.
+	u.AssertEqual(t, commit.Author.URL, "file://"+dir+"#Alice")
+	u.AssertEqual(t, commit.Author.AvatarURL, "")
+	u.AssertEqual(t, strings.HasPrefix(commit.URL, "file://"+dir+"/commit/"), true)
+
+	// Commits that don't match any term are not kept.
+	commits, _, err = source.Search("boring", github.CommitSearchOptions{}, nil)
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(commits), 0)
+}
+
+func Test_pushedByAuthor(t *testing.T) {
+	actor := gharchive.Actor{Login: "alice"}
+
+	tests := []struct {
+		name, email string
+		expected    bool
+	}{
+		{"Alice", "alice.smith@example.com", true},
+		{"Alice Smith", "alice@example.com", true},
+		{"Alice Smith", "1234+alice@users.noreply.github.com", true},
+		{"Bob", "bob@example.com", false},
+		{"Bob", "alice.smith@example.com", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		item := gharchive.PushCommit{}
+		item.Author.Name = test.name
+		item.Author.Email = test.email
+		u.AssertEqual(t, pushedByAuthor(item, actor), test.expected)
+	}
+
+	// Events without an actor have no author.
+	item := gharchive.PushCommit{}
+	item.Author.Name = "alice"
+	u.AssertEqual(t, pushedByAuthor(item, gharchive.Actor{}), false)
+}
+
+func Test_ArchiveSource_Search(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pipeline")
+	defer os.RemoveAll(dir)
+
+	f, _ := os.Create(filepath.Join(dir, "2020-12-01-15.json.gz"))
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(`{"type":"PushEvent","actor":{"login":"alice","avatar_url":"https://avatars.githubusercontent.com/u/1?"},"repo":{"name":"alice/gems"},"payload":{"commits":[{"sha":"6104942438c14ec7bd21c6cd5bd995272b3faff6","message":"fixed a stupid bug lol","distinct":true,"author":{"name":"Alice","email":"1+alice@users.noreply.github.com"}},{"sha":"7d0e7bc1c6cd5bd995272b3faff66104942438c1","message":"stupid typo","distinct":false,"author":{"name":"alice","email":"alice@example.com"}},{"sha":"5bd995272b3faff66104942438c17d0e7bc1c6cd","message":"stupid merge","distinct":true,"author":{"name":"Bob","email":"bob@example.com"}},{"sha":"c6cd5bd995272b3faff66104942438c17d0e7bc1","message":"boring commit","distinct":true,"author":{"name":"alice","email":"alice@example.com"}}]},"created_at":"2020-12-01T15:01:00Z"}`))
+	gz.Close()
+	f.Close()
+
+	source, err := NewArchiveSource(dir, []string{"stupid", "boring"})
+	u.AssertEqual(t, err, nil)
+
+	// Commits that are not distinct, or not pushed by their author, are skipped.
+	commits, _, err := source.Search("stupid", github.CommitSearchOptions{}, nil)
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(commits), 1)
+
+	commit := commits[0]
+	u.AssertEqual(t, commit.Source, config.SourceGithub)
+	u.AssertEqual(t, commit.Message, "fixed a stupid bug lol")
+	u.AssertEqual(t, commit.URL, "https://github.com/alice/gems/commit/6104942438c14ec7bd21c6cd5bd995272b3faff6")
+	u.AssertEqual(t, commit.Author.URL, "https://github.com/alice")
+	u.AssertEqual(t, commit.Repo.Name, "gems")
+	u.AssertEqual(t, commit.Repo.URL, "https://github.com/alice/gems")
+
+	// The date range of the options applies to the archived commits.
+	commits, _, err = source.Search("boring", github.CommitSearchOptions{FromDate: "2020-12-02", ToDate: "2020-12-03"}, nil)
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, len(commits), 0)
+}
+
+// ------------------------------------------------------------------
+// Helpers
+// ------------------------------------------------------------------
+
+// gitlabTestServer creates a stand-in Gitlab server, where the project 7 is missing.
+// The requests to the lookups are counted by path.
+func gitlabTestServer(lookups map[string]int) *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/v4/search", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[
+			{"id": "6104942438c14ec7bd21c6cd5bd995272b3faff6", "short_id": "61049424", "message": "fixed a bug\n", "author_email": "alice@example.com", "project_id": 6},
+			{"id": "7d0e7bc1c6cd5bd995272b3faff66104942438c1", "short_id": "7d0e7bc1", "message": "[WIP] bug", "author_email": "alice@example.com", "project_id": 6},
+			{"id": "c6cd5bd995272b3faff66104942438c17d0e7bc1", "short_id": "c6cd5bd9", "message": "fixed a bug for real", "author_email": "alice@example.com", "project_id": 7},
+			{"id": "5bd995272b3faff66104942438c17d0e7bc1c6cd", "short_id": "5bd99527", "message": "fixed a bug again", "author_email": "alice@example.com", "project_id": 6}
+		]`))
+	})
+
+	mux.HandleFunc("/api/v4/projects/", func(w http.ResponseWriter, r *http.Request) {
+		lookups[r.URL.Path]++
+		if r.URL.Path != "/api/v4/projects/6" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "404 Project Not Found"}`))
+			return
+		}
+		w.Write([]byte(`{"id": 6, "name": "gems", "web_url": "https://gitlab.example.com/team/gems"}`))
+	})
+
+	mux.HandleFunc("/api/v4/users", func(w http.ResponseWriter, r *http.Request) {
+		lookups[r.URL.Path]++
+		w.Write([]byte(`[{"id": 1, "username": "alice", "web_url": "https://gitlab.example.com/alice"}]`))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+// giteaTestServer creates a stand-in Gitea server with a single repo.
+// The since date of the last commit list is recorded, if since isn't nil.
+func giteaTestServer(since *string) *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/v1/repos/search", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok": true, "data": [{"name": "gems", "full_name": "alice/gems", "html_url": "https://codeberg.org/alice/gems"}]}`))
+	})
+
+	mux.HandleFunc("/api/v1/repos/alice/gems/commits", func(w http.ResponseWriter, r *http.Request) {
+		if since != nil {
+			*since = r.URL.Query().Get("since")
+		}
+		w.Write([]byte(`[
+			{
+				"sha": "6104942438c14ec7bd21c6cd5bd995272b3faff6",
+				"html_url": "https://codeberg.org/alice/gems/commit/6104942438c14ec7bd21c6cd5bd995272b3faff6",
+				"commit": {"message": "fixed a stupid bug lol\n", "author": {"date": "2020-12-03T10:00:00Z"}},
+				"author": {"login": "alice", "avatar_url": "https://codeberg.org/avatars/alice"}
+			},
+			{
+				"sha": "7d0e7bc1c6cd5bd995272b3faff66104942438c1",
+				"html_url": "https://codeberg.org/alice/gems/commit/7d0e7bc1c6cd5bd995272b3faff66104942438c1",
+				"commit": {"message": "Add [WIP] stupid feature\n", "author": {"date": "2020-12-02T10:00:00Z"}},
+				"author": {"login": "alice", "avatar_url": "https://codeberg.org/avatars/alice"}
+			},
+			{
+				"sha": "c6cd5bd995272b3faff66104942438c17d0e7bc1",
+				"html_url": "https://codeberg.org/alice/gems/commit/c6cd5bd995272b3faff66104942438c17d0e7bc1",
+				"commit": {"message": "stupid typo\n", "author": {"date": "2020-12-01T10:00:00Z"}},
+				"author": null
+			}
+		]`))
+	})
+
+	return httptest.NewServer(mux)
+}
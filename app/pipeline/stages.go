@@ -0,0 +1,270 @@
+package pipeline
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/tunedmystic/commits.lol/app/clients/github"
+	"github.com/tunedmystic/commits.lol/app/config"
+	"github.com/tunedmystic/commits.lol/app/models"
+	"github.com/tunedmystic/commits.lol/app/utils"
+	"go.uber.org/zap"
+)
+
+// Stage is a step that every commit goes through, before it's saved.
+// A stage can filter the commit out, transform it, or enrich it with more data.
+// To filter a commit out, the stage returns a github.ValidationError, and the commit
+// is counted as rejected. Any other error is reported as a pipeline error.
+type Stage interface {
+	Name() string
+	Process(commit *models.GitCommit) error
+}
+
+// DefaultStages returns the stages that the pipeline runs, in order.
+func DefaultStages(cleaner utils.Cleaner, blocker utils.Blocker, grouper utils.Grouper) []Stage {
+	return []Stage{
+		&SplitMessageStage{},
+		&ValidateStage{},
+		&BotStage{Detector: utils.NewDefaultBotDetector()},
+		&BlockStage{Blocker: blocker},
+		&LanguageStage{Detector: utils.NewTrigramLanguageDetector(), Allowed: config.App.LanguageAllowlist},
+		&ColorThemeStage{},
+		&GroupStage{Grouper: grouper},
+		&CensorStage{Cleaner: cleaner},
+		&MessageHashStage{},
+	}
+}
+
+// runStages passes the commit through the stages, in order.
+// It stops at the first stage that returns an error.
+func runStages(stages []Stage, commit *models.GitCommit) error {
+	for _, stage := range stages {
+		err := stage.Process(commit)
+		if err == nil {
+			continue
+		}
+		if _, ok := err.(github.ValidationError); ok {
+			return err
+		}
+		return fmt.Errorf("pipeline.stage %s: %v", stage.Name(), err)
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------
+// SplitMessageStage
+
+// SplitMessageStage splits the commit message into its subject and body,
+// so that the next stages validate and censor the subject only.
+type SplitMessageStage struct{}
+
+// Name ...
+func (s *SplitMessageStage) Name() string {
+	return "split-message"
+}
+
+// Process ...
+func (s *SplitMessageStage) Process(commit *models.GitCommit) error {
+	commit.SplitMessage()
+	return nil
+}
+
+// ------------------------------------------------------------------
+// ValidateStage
+
+// ValidateStage filters out the commits that break the validation
+// rules, for commits from any source.
+type ValidateStage struct{}
+
+// Name ...
+func (s *ValidateStage) Name() string {
+	return "validate"
+}
+
+// Process ...
+func (s *ValidateStage) Process(commit *models.GitCommit) error {
+	if commit.Author.URL == "" {
+		return github.ErrNoAuthor
+	}
+
+	return github.ValidateMessage(commit.Message)
+}
+
+// ------------------------------------------------------------------
+// BotStage
+
+// BotStage filters out the commits that are made by bots, or have automated messages.
+type BotStage struct {
+	Detector utils.BotDetector
+}
+
+// Name ...
+func (s *BotStage) Name() string {
+	return "bots"
+}
+
+// Process ...
+func (s *BotStage) Process(commit *models.GitCommit) error {
+	if reason := s.Detector.Detect(commit.Author.Username, commit.Message); reason != "" {
+		zap.S().Debugf("  Commit [%s] is automated: %s", commit.Message, reason)
+		return utils.ErrBotCommit
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------
+// BlockStage
+
+// BlockStage filters out the commits whose message has a blocked word, in the subject or the body.
+type BlockStage struct {
+	Blocker utils.Blocker
+}
+
+// Name ...
+func (s *BlockStage) Name() string {
+	return "block"
+}
+
+// Process ...
+func (s *BlockStage) Process(commit *models.GitCommit) error {
+	if word := s.Blocker.Block(commit.Message + "\n" + commit.Body); word != "" {
+		zap.S().Debugf("  Commit [%s] has the blocked word [%s]", commit.Message, word)
+		return utils.ErrBlockedWord
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------
+// LanguageStage
+
+// LanguageStage detects the language of the commit message. If there are allowed
+// languages, it filters out the commits in other languages. Commits whose language
+// could not be detected are kept, as most short messages can't be detected.
+type LanguageStage struct {
+	Detector utils.LanguageDetector
+	Allowed  []string
+}
+
+// Name ...
+func (s *LanguageStage) Name() string {
+	return "language"
+}
+
+// Process ...
+func (s *LanguageStage) Process(commit *models.GitCommit) error {
+	commit.Lang = s.Detector.Detect(commit.Message)
+
+	if commit.Lang == "" || len(s.Allowed) == 0 {
+		return nil
+	}
+
+	for _, lang := range s.Allowed {
+		if commit.Lang == lang {
+			return nil
+		}
+	}
+	return utils.ErrMessageLanguage
+}
+
+// ------------------------------------------------------------------
+// ColorThemeStage
+
+// ColorThemeStage calculates the commit colors (for frontend).
+type ColorThemeStage struct{}
+
+// Name ...
+func (s *ColorThemeStage) Name() string {
+	return "color-theme"
+}
+
+// Process ...
+func (s *ColorThemeStage) Process(commit *models.GitCommit) error {
+	commit.SetColorTheme()
+	return nil
+}
+
+// ------------------------------------------------------------------
+// GroupStage
+
+// GroupStage calculates the commit group.
+type GroupStage struct {
+	Grouper utils.Grouper
+}
+
+// Name ...
+func (s *GroupStage) Name() string {
+	return "group"
+}
+
+// Process ...
+func (s *GroupStage) Process(commit *models.GitCommit) error {
+	commit.SetGroup(s.Grouper)
+	return nil
+}
+
+// ------------------------------------------------------------------
+// CensorStage
+
+// CensorStage censors the commit subject and body if necessary.
+type CensorStage struct {
+	Cleaner utils.Cleaner
+}
+
+// Name ...
+func (s *CensorStage) Name() string {
+	return "censor"
+}
+
+// Process ...
+func (s *CensorStage) Process(commit *models.GitCommit) error {
+	commit.SetCensoredMessage(s.Cleaner)
+	commit.SetCensoredBody(s.Cleaner)
+	return nil
+}
+
+// ------------------------------------------------------------------
+// ScoreStage
+
+// ScoreStage scores how funny the commit message is.
+type ScoreStage struct {
+	Scorer utils.Scorer
+}
+
+// Name ...
+func (s *ScoreStage) Name() string {
+	return "score"
+}
+
+// Process ...
+func (s *ScoreStage) Process(commit *models.GitCommit) error {
+	commit.Score = sql.NullFloat64{Float64: s.Scorer.Score(commit.Message), Valid: true}
+	return nil
+}
+
+// ------------------------------------------------------------------
+// MessageHashStage
+
+// MessageHashStage hashes the commit message, to find its duplicates.
+type MessageHashStage struct{}
+
+// Name ...
+func (s *MessageHashStage) Name() string {
+	return "message-hash"
+}
+
+// Process ...
+func (s *MessageHashStage) Process(commit *models.GitCommit) error {
+	commit.SetMessageHash()
+	return nil
+}
+
+var _ Stage = &SplitMessageStage{}
+var _ Stage = &ValidateStage{}
+var _ Stage = &BotStage{}
+var _ Stage = &BlockStage{}
+var _ Stage = &LanguageStage{}
+var _ Stage = &ColorThemeStage{}
+var _ Stage = &GroupStage{}
+var _ Stage = &CensorStage{}
+var _ Stage = &ScoreStage{}
+var _ Stage = &MessageHashStage{}
@@ -0,0 +1,211 @@
+package pipeline
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tunedmystic/commits.lol/app/clients/github"
+	"github.com/tunedmystic/commits.lol/app/models"
+	u "github.com/tunedmystic/commits.lol/app/utils"
+)
+
+// MockStage ...
+type MockStage struct {
+	NameMock    string
+	ProcessMock func(commit *models.GitCommit) error
+}
+
+// Name ...
+func (s *MockStage) Name() string {
+	return s.NameMock
+}
+
+// Process ...
+func (s *MockStage) Process(commit *models.GitCommit) error {
+	return s.ProcessMock(commit)
+}
+
+var _ Stage = &MockStage{}
+
+func Test_SplitMessageStage(t *testing.T) {
+	stage := &SplitMessageStage{}
+
+	commit := mockCommit("fixed a bug\n\nit was a feature")
+	u.AssertEqual(t, stage.Process(&commit), nil)
+	u.AssertEqual(t, commit.Message, "fixed a bug")
+	u.AssertEqual(t, commit.Body, "it was a feature")
+}
+
+func Test_ValidateStage(t *testing.T) {
+	stage := &ValidateStage{}
+
+	commit := mockCommit("fixed a bug")
+	u.AssertEqual(t, stage.Process(&commit), nil)
+
+	commit = mockCommit("fixed [a] bug")
+	u.AssertEqual(t, stage.Process(&commit), github.ErrMessageFormat)
+
+	commit = mockCommit("fixed a bug")
+	commit.Author = models.GitUser{}
+	u.AssertEqual(t, stage.Process(&commit), github.ErrNoAuthor)
+}
+
+func Test_BotStage(t *testing.T) {
+	stage := &BotStage{Detector: u.NewDefaultBotDetector()}
+
+	commit := mockCommit("fixed a bug")
+	u.AssertEqual(t, stage.Process(&commit), nil)
+
+	commit = mockCommit("Merge branch 'main' into dev")
+	u.AssertEqual(t, stage.Process(&commit), u.ErrBotCommit)
+
+	commit = mockCommit("fixed a bug")
+	commit.Author.Username = "dependabot[bot]"
+	u.AssertEqual(t, stage.Process(&commit), u.ErrBotCommit)
+}
+
+func Test_BlockStage(t *testing.T) {
+	stage := &BlockStage{Blocker: u.NewMessageBlocker([]string{"hateful"})}
+
+	commit := mockCommit("fixed a bug")
+	u.AssertEqual(t, stage.Process(&commit), nil)
+
+	commit = mockCommit("fixed a Hateful bug")
+	u.AssertEqual(t, stage.Process(&commit), u.ErrBlockedWord)
+
+	commit = mockCommit("fixed a bug")
+	commit.Body = "it was hateful"
+	u.AssertEqual(t, stage.Process(&commit), u.ErrBlockedWord)
+}
+
+func Test_LanguageStage(t *testing.T) {
+	stage := &LanguageStage{Detector: u.NewTrigramLanguageDetector()}
+
+	commit := mockCommit("pourquoi ça ne marche pas")
+	u.AssertEqual(t, stage.Process(&commit), nil)
+	u.AssertEqual(t, commit.Lang, "fr")
+
+	// Only the allowed languages are kept.
+	stage.Allowed = []string{"en", "es"}
+	u.AssertEqual(t, stage.Process(&commit), u.ErrMessageLanguage)
+
+	commit = mockCommit("why does this even work")
+	u.AssertEqual(t, stage.Process(&commit), nil)
+	u.AssertEqual(t, commit.Lang, "en")
+
+	// Messages whose language can't be detected are kept.
+	commit = mockCommit("wip")
+	u.AssertEqual(t, stage.Process(&commit), nil)
+	u.AssertEqual(t, commit.Lang, "")
+}
+
+func Test_ColorThemeStage(t *testing.T) {
+	commit := mockCommit("fixed a bug")
+	u.AssertEqual(t, (&ColorThemeStage{}).Process(&commit), nil)
+
+	u.AssertEqual(t, commit.ColorBackground != "", true)
+	u.AssertEqual(t, commit.ColorForeground != "", true)
+}
+
+func Test_GroupStage(t *testing.T) {
+	stage := &GroupStage{Grouper: u.NewCommitGrouper(map[string]string{"bug": "bugs"})}
+
+	commit := mockCommit("fixed a bug")
+	u.AssertEqual(t, stage.Process(&commit), nil)
+	u.AssertEqual(t, commit.Group, "bugs")
+}
+
+func Test_CensorStage(t *testing.T) {
+	stage := &CensorStage{Cleaner: u.NewMessageCleaner([]string{"bug"})}
+
+	commit := mockCommit("fixed a bug")
+	u.AssertEqual(t, stage.Process(&commit), nil)
+	u.AssertEqual(t, commit.MessageCensored != "", true)
+	u.AssertEqual(t, commit.Message, "fixed a bug")
+
+	commit = mockCommit("fixed it")
+	commit.Body = "it was a bug"
+	u.AssertEqual(t, stage.Process(&commit), nil)
+	u.AssertEqual(t, commit.MessageCensored, "")
+	u.AssertEqual(t, commit.BodyCensored != "", true)
+}
+
+func Test_MessageHashStage(t *testing.T) {
+	commit := mockCommit("Fixed a bug!")
+	u.AssertEqual(t, (&MessageHashStage{}).Process(&commit), nil)
+	u.AssertEqual(t, commit.MessageHash, u.MessageHash("fixed a bug"))
+}
+
+func Test_runStages(t *testing.T) {
+	calls := []string{}
+	stage := func(name string, err error) Stage {
+		return &MockStage{
+			NameMock: name,
+			ProcessMock: func(commit *models.GitCommit) error {
+				calls = append(calls, name)
+				commit.Message += " " + name
+				return err
+			},
+		}
+	}
+
+	// The stages run in order.
+	commit := mockCommit("fixed")
+	err := runStages([]Stage{stage("a", nil), stage("b", nil)}, &commit)
+	u.AssertEqual(t, err, nil)
+	u.AssertEqual(t, commit.Message, "fixed a b")
+
+	// A filter stops the commit, with the validation error.
+	calls = []string{}
+	err = runStages([]Stage{stage("a", github.ErrMessageFormat), stage("b", nil)}, &commit)
+	u.AssertEqual(t, err, github.ErrMessageFormat)
+	u.AssertEqual(t, len(calls), 1)
+
+	// Other errors are wrapped with the name of the stage.
+	err = runStages([]Stage{stage("a", errors.New("boom"))}, &commit)
+	u.AssertEqual(t, err.Error(), "pipeline.stage a: boom")
+}
+
+func Test_Run_with_stages(t *testing.T) {
+	source := &MockSource{
+		NameMock: "mock",
+		SearchMock: func(term string, options github.CommitSearchOptions, budget *u.CallBudget) (models.GitCommits, int, error) {
+			return models.GitCommits{mockCommit("fixed a bug"), mockCommit("fixed a typo")}, 1, nil
+		},
+	}
+
+	saved := models.GitCommits{}
+	mockDB := mockPipelineDB()
+	mockDB.SaveCommitBatchMock = func(commits models.GitCommits) (models.GitCommits, error) {
+		saved = append(saved, commits...)
+		return commits, nil
+	}
+
+	noTypos := &MockStage{
+		NameMock: "no-typos",
+		ProcessMock: func(commit *models.GitCommit) error {
+			if commit.Message == "fixed a typo" {
+				return github.ValidationError("no typos")
+			}
+			commit.Group = "custom"
+			return nil
+		},
+	}
+
+	p := Commits(mockDB)
+	p.WithSources(source)
+	p.WithSearchTerms("bug")
+	p.WithStages(&ValidateStage{}, noTypos)
+	report := p.Run()
+
+	termReport := report.Terms[0]
+	u.AssertEqual(t, termReport.Saved, 1)
+	u.AssertEqual(t, termReport.Rejected["no typos"], 1)
+
+	u.AssertEqual(t, len(saved), 1)
+	u.AssertEqual(t, saved[0].Group, "custom")
+	u.AssertEqual(t, saved[0].Valid, true)
+
+	// The default stages were replaced.
+	u.AssertEqual(t, saved[0].MessageHash, "")
+}
@@ -0,0 +1,156 @@
+package pipeline
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/tunedmystic/commits.lol/app/config"
+	"github.com/tunedmystic/commits.lol/app/db"
+	"github.com/tunedmystic/commits.lol/app/models"
+	"go.uber.org/zap"
+)
+
+// WebhookSubscriber POSTs the pipeline events as JSON to the webhook URLs.
+// The payloads are signed with the webhook secret, so the receivers can verify them.
+// The events are delivered in the background, in order, and every delivery is logged.
+type WebhookSubscriber struct {
+	db      db.Database
+	urls    []string
+	secret  string
+	retries int           // The amount of attempts after the first one.
+	backoff time.Duration // The wait before the first retry. It doubles with every retry.
+	client  *http.Client
+
+	events chan Event
+	done   chan bool
+}
+
+// NewWebhookSubscriber creates a WebhookSubscriber, and starts delivering the events.
+// It must be closed, to deliver the pending events.
+func NewWebhookSubscriber(database db.Database, urls []string, secret string) *WebhookSubscriber {
+	w := WebhookSubscriber{
+		db:      database,
+		urls:    urls,
+		secret:  secret,
+		retries: config.App.WebhookRetries,
+		backoff: time.Second,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		events:  make(chan Event, 100),
+		done:    make(chan bool),
+	}
+	go w.run()
+	return &w
+}
+
+// Notify queues the event, to be delivered to every webhook URL.
+// The pipeline doesn't wait for slow webhooks, so the event is dropped if the queue is full.
+func (w *WebhookSubscriber) Notify(event Event) {
+	select {
+	case w.events <- event:
+	default:
+		zap.S().Warnf("pipeline.WebhookSubscriber: the queue is full, dropping the %s event", event.Type)
+	}
+}
+
+// Close waits for the pending events to be delivered.
+func (w *WebhookSubscriber) Close() {
+	close(w.events)
+	<-w.done
+}
+
+// run delivers the queued events, until the subscriber is closed.
+func (w *WebhookSubscriber) run() {
+	for event := range w.events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			zap.S().Errorf("pipeline.WebhookSubscriber: %v", err)
+			continue
+		}
+
+		for _, url := range w.urls {
+			w.deliver(url, event.Type, payload)
+		}
+	}
+	close(w.done)
+}
+
+// deliver POSTs the payload to the URL, retrying on errors, and logs the delivery.
+func (w *WebhookSubscriber) deliver(url, eventType string, payload []byte) {
+	delivery := models.WebhookDelivery{
+		URL:       url,
+		Event:     eventType,
+		Payload:   string(payload),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	backoff := w.backoff
+	for attempt := 0; attempt <= w.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		delivery.Attempts++
+		delivery.StatusCode, delivery.Delivered, delivery.Error = w.post(url, eventType, payload)
+
+		if delivery.Delivered {
+			break
+		}
+	}
+
+	if !delivery.Delivered {
+		errMsg := fmt.Errorf("pipeline.WebhookSubscriber: %s to %s failed: %s", eventType, url, delivery.Error)
+		zap.S().Warn(errMsg.Error())
+		sentry.CaptureException(errMsg)
+	}
+
+	if err := w.db.CreateWebhookDelivery(&delivery); err != nil {
+		errMsg := fmt.Errorf("pipeline.WebhookSubscriber:CreateWebhookDelivery: %v", err)
+		zap.S().Error(errMsg.Error())
+		sentry.CaptureException(errMsg)
+	}
+}
+
+// post makes a single delivery attempt.
+// Returns the status code, whether the payload was delivered, and the error, if any.
+func (w *WebhookSubscriber) post(url, eventType string, payload []byte) (int, bool, string) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, false, err.Error()
+	}
+
+	req.Header.Add("User-Agent", "commits.lol")
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("X-Commits-Event", eventType)
+	req.Header.Add("X-Commits-Signature", SignPayload(w.secret, payload))
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return 0, false, err.Error()
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, false, fmt.Sprintf("unexpected status code %d", res.StatusCode)
+	}
+
+	return res.StatusCode, true, ""
+}
+
+// SignPayload returns the HMAC-SHA256 signature of the payload, as it's sent in
+// the X-Commits-Signature header. Example:  sha256=5d41402abc4b2a76...
+func SignPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Ensure the WebhookSubscriber type satisfies the Subscriber interface.
+var _ Subscriber = &WebhookSubscriber{}
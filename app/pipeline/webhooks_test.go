@@ -0,0 +1,130 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tunedmystic/commits.lol/app/db"
+	"github.com/tunedmystic/commits.lol/app/models"
+	u "github.com/tunedmystic/commits.lol/app/utils"
+)
+
+func mockWebhookDB(deliveries *models.WebhookDeliveries) *db.MockDB {
+	mu := sync.Mutex{}
+	return &db.MockDB{
+		CreateWebhookDeliveryMock: func(delivery *models.WebhookDelivery) error {
+			mu.Lock()
+			defer mu.Unlock()
+			*deliveries = append(*deliveries, *delivery)
+			return nil
+		},
+	}
+}
+
+func Test_WebhookSubscriber(t *testing.T) {
+	received := []Event{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		u.AssertEqual(t, r.Header.Get("Content-Type"), "application/json")
+		u.AssertEqual(t, r.Header.Get("X-Commits-Signature"), SignPayload("hunter2", body))
+
+		event := Event{}
+		json.Unmarshal(body, &event)
+		u.AssertEqual(t, r.Header.Get("X-Commits-Event"), event.Type)
+
+		received = append(received, event)
+	}))
+	defer server.Close()
+
+	deliveries := models.WebhookDeliveries{}
+	webhooks := NewWebhookSubscriber(mockWebhookDB(&deliveries), []string{server.URL}, "hunter2")
+
+	commit := mockCommit("fixed a bug")
+	commit.ID = 7
+	webhooks.Notify(newCommitEvent(commit))
+	webhooks.Notify(newRunEvent(EventRunFinished, models.PipelineRun{ID: 3, Trigger: TriggerCron, Saved: 1}))
+	webhooks.Close()
+
+	// The events are delivered in order.
+	u.AssertEqual(t, len(received), 2)
+	u.AssertEqual(t, received[0].Type, EventCommitSaved)
+	u.AssertEqual(t, received[0].Commit.ID, 7)
+	u.AssertEqual(t, received[0].Commit.Message, "fixed a bug")
+	u.AssertEqual(t, received[0].Commit.Author, "alice")
+	u.AssertEqual(t, received[1].Type, EventRunFinished)
+	u.AssertEqual(t, received[1].Run.ID, 3)
+	u.AssertEqual(t, received[1].Run.Saved, 1)
+
+	// Every delivery is logged.
+	u.AssertEqual(t, len(deliveries), 2)
+	u.AssertEqual(t, deliveries[0].Event, EventCommitSaved)
+	u.AssertEqual(t, deliveries[0].URL, server.URL)
+	u.AssertEqual(t, deliveries[0].StatusCode, http.StatusOK)
+	u.AssertEqual(t, deliveries[0].Attempts, 1)
+	u.AssertEqual(t, deliveries[0].Delivered, true)
+}
+
+func Test_WebhookSubscriber_retries(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	deliveries := models.WebhookDeliveries{}
+	webhooks := NewWebhookSubscriber(mockWebhookDB(&deliveries), []string{server.URL, "http://127.0.0.1:0"}, "")
+	webhooks.retries = 2
+	webhooks.backoff = time.Millisecond
+
+	webhooks.Notify(newRunEvent(EventRunStarted, models.PipelineRun{Trigger: TriggerCLI}))
+	webhooks.Close()
+
+	u.AssertEqual(t, len(deliveries), 2)
+
+	// The first URL succeeds on the last attempt.
+	u.AssertEqual(t, deliveries[0].Attempts, 3)
+	u.AssertEqual(t, deliveries[0].Delivered, true)
+	u.AssertEqual(t, deliveries[0].Error, "")
+
+	// The second URL can't be reached.
+	u.AssertEqual(t, deliveries[1].Attempts, 3)
+	u.AssertEqual(t, deliveries[1].Delivered, false)
+	u.AssertEqual(t, deliveries[1].StatusCode, 0)
+	u.AssertEqual(t, deliveries[1].Error != "", true)
+}
+
+func Test_WebhookSubscriber_full_queue(t *testing.T) {
+	// A subscriber that isn't delivering, so the queue fills up.
+	webhooks := WebhookSubscriber{events: make(chan Event, 1)}
+
+	notified := make(chan bool)
+	go func() {
+		webhooks.Notify(newRunEvent(EventRunStarted, models.PipelineRun{ID: 3}))
+		webhooks.Notify(newRunEvent(EventRunFinished, models.PipelineRun{ID: 3}))
+		notified <- true
+	}()
+
+	// The event that doesn't fit is dropped, instead of blocking the pipeline.
+	select {
+	case <-notified:
+	case <-time.After(time.Second):
+		t.Fatal("Notify blocked on a full queue")
+	}
+
+	u.AssertEqual(t, len(webhooks.events), 1)
+	u.AssertEqual(t, (<-webhooks.events).Type, EventRunStarted)
+}
+
+func Test_SignPayload(t *testing.T) {
+	// The signature of an empty payload, as computed by `openssl dgst -sha256 -hmac key`.
+	u.AssertEqual(t, SignPayload("key", []byte("")), "sha256=5d5d139563c95b5967b9bd9a8c9b233a9dedb45072794cd232dc1b74832607d0")
+}
@@ -1,7 +1,9 @@
 package server
 
 import (
+	"crypto/subtle"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"go.uber.org/zap"
@@ -15,6 +17,65 @@ func CacheControl(h http.Handler) http.Handler {
 	})
 }
 
+// BasicAuth protects the handlers with the given credentials.
+func BasicAuth(username, password string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, pass, ok := r.BasicAuth()
+			validUser := subtle.ConstantTimeCompare([]byte(user), []byte(username)) == 1
+			validPass := subtle.ConstantTimeCompare([]byte(pass), []byte(password)) == 1
+
+			if !ok || !validUser || !validPass {
+				w.Header().Set("WWW-Authenticate", `Basic realm="commits.lol admin"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SameOrigin protects the handlers from cross-site requests, by rejecting the unsafe
+// requests (like a POST) that don't come from the site itself. The Origin header
+// of the request, or its Referer, must be the host of the request, or of the baseURL.
+func SameOrigin(baseURL string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				origin = r.Header.Get("Referer")
+			}
+
+			if !sameHost(origin, r.Host) && !sameHost(origin, baseURL) {
+				http.Error(w, "cross-site requests are forbidden", http.StatusForbidden)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// sameHost checks if the URL has the host. The host can be a URL too.
+func sameHost(rawURL, host string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || host == "" {
+		return false
+	}
+
+	if h, err := url.Parse(host); err == nil && h.Host != "" {
+		host = h.Host
+	}
+	return u.Host == host
+}
+
 // StatusRecorder allows us to capture the response status code.
 type StatusRecorder struct {
 	http.ResponseWriter
@@ -38,3 +38,72 @@ func Test_Logging(t *testing.T) {
 
 	u.AssertEqual(t, handlerReached, true)
 }
+
+func Test_BasicAuth(t *testing.T) {
+	// Dummy handler that will be wrapped with the middleware.
+	handlerReached := false
+	h := func(w http.ResponseWriter, r *http.Request) {
+		handlerReached = true
+	}
+	protected := BasicAuth("admin", "hunter2")(http.HandlerFunc(h))
+
+	// Wrong password.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.SetBasicAuth("admin", "hunter3")
+
+	protected.ServeHTTP(w, r)
+
+	u.AssertEqual(t, w.Code, http.StatusUnauthorized)
+	u.AssertEqual(t, w.Header().Get("WWW-Authenticate"), `Basic realm="commits.lol admin"`)
+	u.AssertEqual(t, handlerReached, false)
+
+	// Correct credentials.
+	w = httptest.NewRecorder()
+	r.SetBasicAuth("admin", "hunter2")
+
+	protected.ServeHTTP(w, r)
+
+	u.AssertEqual(t, w.Code, http.StatusOK)
+	u.AssertEqual(t, handlerReached, true)
+}
+
+func Test_SameOrigin(t *testing.T) {
+	// Dummy handler that will be wrapped with the middleware.
+	handlerReached := false
+	h := func(w http.ResponseWriter, r *http.Request) {
+		handlerReached = true
+	}
+	protected := SameOrigin("https://commits.lol")(http.HandlerFunc(h))
+
+	tests := []struct {
+		method, origin, referer string
+		expected                int
+	}{
+		{http.MethodGet, "", "", http.StatusOK},
+		{http.MethodPost, "", "", http.StatusForbidden},
+		{http.MethodPost, "https://evil.example.com", "", http.StatusForbidden},
+		{http.MethodPost, "null", "", http.StatusForbidden},
+		{http.MethodPost, "", "https://evil.example.com/admin/runs", http.StatusForbidden},
+		{http.MethodPost, "https://commits.lol", "", http.StatusOK},
+		{http.MethodPost, "http://example.com", "", http.StatusOK}, // the host of the request
+		{http.MethodPost, "", "https://commits.lol/admin/runs", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		handlerReached = false
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.method, "/admin/runs", nil)
+		if test.origin != "" {
+			r.Header.Set("Origin", test.origin)
+		}
+		if test.referer != "" {
+			r.Header.Set("Referer", test.referer)
+		}
+
+		protected.ServeHTTP(w, r)
+
+		u.AssertEqual(t, w.Code, test.expected)
+		u.AssertEqual(t, handlerReached, test.expected == http.StatusOK)
+	}
+}
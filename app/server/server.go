@@ -1,22 +1,44 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"math/rand"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/gorilla/mux"
 	"github.com/tunedmystic/commits.lol/app/config"
 	"github.com/tunedmystic/commits.lol/app/db"
+	"github.com/tunedmystic/commits.lol/app/models"
+	"github.com/tunedmystic/commits.lol/app/utils"
 )
 
 // Server contains all dependencies for the application.
 type Server struct {
 	Templates *template.Template
 	DB        db.Database
+
+	// RunPipeline runs the pipeline, and returns once the run is done.
+	// It is used by the admin pages, and is optional.
+	RunPipeline func()
+
+	// Whether a pipeline run from the admin pages is in progress (1) or not (0).
+	running int32
+
+	// Reprocess passes the saved commits through the pipeline stages in the background,
+	// so that a new block word flags the commits that have it. It is optional.
+	Reprocess func()
+
+	// Whether a reprocess is in progress (1) or not (0), and whether another one
+	// was asked for in the meantime (1) or not (0).
+	reprocessing     int32
+	reprocessPending int32
 }
 
 // NewServer creates a new Server type.
@@ -31,6 +53,18 @@ func NewServer(DB db.Database) Server {
 		"Unescape": func(html string) template.HTML {
 			return template.HTML(html)
 		},
+		"Highlight": func(text string, words []string) template.HTML {
+			return template.HTML(utils.HighlightText(text, words))
+		},
+		"HighlightHTML": func(html string, words []string) template.HTML {
+			return template.HTML(utils.Highlight(html, words))
+		},
+		"Previous": func(page int) int {
+			return page - 1
+		},
+		"Next": func(page int) int {
+			return page + 1
+		},
 		"MetaImg": func() string {
 			metaImages := []string{"meta1.png", "meta2.png", "meta3.png"}
 			return metaImages[rand.Int()%len(metaImages)]
@@ -54,7 +88,8 @@ func (s *Server) IndexHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Get recent commits.
 	group := r.URL.Query().Get("group")
-	commits, err := s.DB.RecentCommitsByGroup(group)
+	lang := r.URL.Query().Get("lang")
+	commits, err := s.DB.RecentCommitsByGroup(group, lang)
 	if err != nil {
 		sentry.CaptureException(err)
 		fmt.Println(err)
@@ -71,10 +106,307 @@ func (s *Server) IndexHandler(w http.ResponseWriter, r *http.Request) {
 	s.Templates.ExecuteTemplate(w, "index", commits)
 }
 
+// CommitHandler renders the permalink page of a commit, with its whole message.
+func (s *Server) CommitHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	commit, err := s.DB.GetCommit(ID)
+	if err != nil {
+		sentry.CaptureException(err)
+		fmt.Println(err)
+		http.Error(w, "oopsie, something went horribly wrong", http.StatusInternalServerError)
+		return
+	}
+	if commit == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.Templates.ExecuteTemplate(w, "commit", commit)
+}
+
+// SearchHandler renders the commits that match the `q` query param, with the matched words highlighted.
+// The results can be filtered by the `group` and `lang` query params, and paged with `page`.
+func (s *Server) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	query, filters, page := searchParams(r)
+
+	results, err := s.DB.SearchCommits(query, filters, page)
+	if err != nil {
+		sentry.CaptureException(err)
+		fmt.Println(err)
+		http.Error(w, "oopsie, something went horribly wrong", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		models.SearchResults
+		Words   []string
+		Filters models.SearchFilters
+	}{
+		SearchResults: results,
+		Words:         models.SearchWords(query),
+		Filters:       filters,
+	}
+
+	s.Templates.ExecuteTemplate(w, "search", data)
+}
+
+// searchResponse is the JSON response of the search API.
+type searchResponse struct {
+	Query   string         `json:"query"`
+	Page    int            `json:"page"`
+	HasNext bool           `json:"has_next"`
+	Commits []searchCommit `json:"commits"`
+}
+
+// searchCommit is a commit in the JSON response of the search API.
+type searchCommit struct {
+	ID          int       `json:"id"`
+	Message     string    `json:"message"`
+	Highlighted string    `json:"highlighted"` // The message as HTML, with the matched words in <mark> tags.
+	Censored    bool      `json:"censored"`    // The message has bad words.
+	URL         string    `json:"url"`
+	Permalink   string    `json:"permalink"`
+	Date        time.Time `json:"date"`
+	Author      string    `json:"author"`
+	Repo        string    `json:"repo"`
+}
+
+// SearchAPIHandler responds with the commits that match the `q` query param, as JSON.
+// It takes the same query params as the search page. The bad words are masked,
+// unless the `uncensored` query param is true.
+func (s *Server) SearchAPIHandler(w http.ResponseWriter, r *http.Request) {
+	query, filters, page := searchParams(r)
+	uncensored, _ := strconv.ParseBool(r.URL.Query().Get("uncensored"))
+
+	results, err := s.DB.SearchCommits(query, filters, page)
+	if err != nil {
+		sentry.CaptureException(err)
+		fmt.Println(err)
+		http.Error(w, "oopsie, something went horribly wrong", http.StatusInternalServerError)
+		return
+	}
+
+	words := models.SearchWords(query)
+	response := searchResponse{
+		Query:   results.Query,
+		Page:    results.Page,
+		HasNext: results.HasNext,
+		Commits: make([]searchCommit, 0, len(results.Commits)),
+	}
+
+	for _, commit := range results.Commits {
+		message := commit.Message
+		highlighted := utils.HighlightText(commit.Message, words)
+		if commit.MessageCensored != "" && !uncensored {
+			message = utils.StripTags(commit.MessageCensored)
+			highlighted = utils.Highlight(commit.MessageCensored, words)
+		}
+
+		response.Commits = append(response.Commits, searchCommit{
+			ID:          commit.ID,
+			Message:     message,
+			Highlighted: highlighted,
+			Censored:    commit.MessageCensored != "",
+			URL:         commit.URL,
+			Permalink:   fmt.Sprintf("%s/commits/%d", config.App.BaseURL, commit.ID),
+			Date:        commit.Date,
+			Author:      commit.Author.Username,
+			Repo:        commit.Repo.Name,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// searchParams reads the query, the filters and the page of a search from the query params.
+func searchParams(r *http.Request) (string, models.SearchFilters, int) {
+	params := r.URL.Query()
+
+	filters := models.SearchFilters{
+		Group: params.Get("group"),
+		Lang:  params.Get("lang"),
+	}
+
+	page, err := strconv.Atoi(params.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	return strings.TrimSpace(params.Get("q")), filters, page
+}
+
+// AdminRunsHandler renders the history of the pipeline runs.
+func (s *Server) AdminRunsHandler(w http.ResponseWriter, r *http.Request) {
+	runs, err := s.DB.RecentPipelineRuns(50)
+	if err != nil {
+		sentry.CaptureException(err)
+		fmt.Println(err)
+		http.Error(w, "oopsie, something went horribly wrong", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Runs       models.PipelineRuns
+		Triggered  bool
+		CanTrigger bool
+	}{
+		Runs:       runs,
+		Triggered:  r.URL.Query().Get("triggered") != "",
+		CanTrigger: s.RunPipeline != nil,
+	}
+
+	s.Templates.ExecuteTemplate(w, "admin_runs", data)
+}
+
+// AdminTriggerRunHandler starts a pipeline run in the background, and redirects back to the run history.
+// Only one run can be in progress at a time.
+func (s *Server) AdminTriggerRunHandler(w http.ResponseWriter, r *http.Request) {
+	if s.RunPipeline == nil {
+		http.Error(w, "the pipeline can't be triggered from here", http.StatusServiceUnavailable)
+		return
+	}
+
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		http.Error(w, "a pipeline run is already in progress", http.StatusConflict)
+		return
+	}
+
+	go func() {
+		defer atomic.StoreInt32(&s.running, 0)
+		s.RunPipeline()
+	}()
+
+	http.Redirect(w, r, "/admin/runs?triggered=1", http.StatusSeeOther)
+}
+
+// AdminBlockWordsHandler renders the block words.
+func (s *Server) AdminBlockWordsHandler(w http.ResponseWriter, r *http.Request) {
+	words, err := s.DB.AllBlockWords()
+	if err != nil {
+		sentry.CaptureException(err)
+		fmt.Println(err)
+		http.Error(w, "oopsie, something went horribly wrong", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Words       models.BlockWords
+		Reprocessed bool
+	}{
+		Words:       words,
+		Reprocessed: r.URL.Query().Get("reprocessed") != "",
+	}
+
+	s.Templates.ExecuteTemplate(w, "admin_blockwords", data)
+}
+
+// AdminCreateBlockWordHandler adds a block word, and reprocesses the saved commits,
+// so the ones that have it are flagged as not valid.
+func (s *Server) AdminCreateBlockWordHandler(w http.ResponseWriter, r *http.Request) {
+	text := strings.ToLower(strings.TrimSpace(r.FormValue("text")))
+	if text == "" {
+		http.Error(w, "the block word can't be empty", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.DB.CreateBlockWord(&models.BlockWord{Text: text}); err != nil {
+		sentry.CaptureException(err)
+		fmt.Println(err)
+		http.Error(w, "oopsie, something went horribly wrong", http.StatusInternalServerError)
+		return
+	}
+
+	if s.Reprocess == nil {
+		http.Redirect(w, r, "/admin/blockwords", http.StatusSeeOther)
+		return
+	}
+
+	s.startReprocess()
+
+	http.Redirect(w, r, "/admin/blockwords?reprocessed=1", http.StatusSeeOther)
+}
+
+// startReprocess reprocesses the saved commits in the background. Only one reprocess runs
+// at a time: if one is in progress, it runs once more when it's done, so it sees the new
+// block words, however many were added in the meantime.
+func (s *Server) startReprocess() {
+	atomic.StoreInt32(&s.reprocessPending, 1)
+	if !atomic.CompareAndSwapInt32(&s.reprocessing, 0, 1) {
+		return
+	}
+
+	go func() {
+		for {
+			for atomic.SwapInt32(&s.reprocessPending, 0) == 1 {
+				s.reprocess()
+			}
+			atomic.StoreInt32(&s.reprocessing, 0)
+
+			// Another reprocess could be asked for, right before the flag was cleared.
+			if atomic.LoadInt32(&s.reprocessPending) == 0 || !atomic.CompareAndSwapInt32(&s.reprocessing, 0, 1) {
+				return
+			}
+		}
+	}()
+}
+
+// reprocess runs the Reprocess func, and recovers if it panics, so the server keeps running.
+func (s *Server) reprocess() {
+	defer func() {
+		if err := recover(); err != nil {
+			sentry.CurrentHub().Recover(err)
+			fmt.Println("reprocess failed:", err)
+		}
+	}()
+
+	s.Reprocess()
+}
+
+// AdminDeleteBlockWordHandler removes a block word.
+// The commits that were flagged because of it stay flagged.
+func (s *Server) AdminDeleteBlockWordHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "the block word ID is not valid", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.DB.DeleteBlockWord(ID); err != nil {
+		sentry.CaptureException(err)
+		fmt.Println(err)
+		http.Error(w, "oopsie, something went horribly wrong", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/blockwords", http.StatusSeeOther)
+}
+
 // Routes returns the routes for the application.
 func (s *Server) Routes() http.Handler {
 	router := mux.NewRouter()
 	router.HandleFunc("/", s.IndexHandler).Methods("GET")
+	router.HandleFunc("/commits/{id:[0-9]+}", s.CommitHandler).Methods("GET")
+	router.HandleFunc("/search", s.SearchHandler).Methods("GET")
+	router.HandleFunc("/api/search", s.SearchAPIHandler).Methods("GET")
+
+	// The admin pages are only served when an admin password is configured.
+	if config.App.AdminPassword != "" {
+		admin := router.PathPrefix("/admin").Subrouter()
+		admin.Use(BasicAuth(config.App.AdminUsername, config.App.AdminPassword))
+		admin.Use(SameOrigin(config.App.BaseURL))
+		admin.HandleFunc("/runs", s.AdminRunsHandler).Methods("GET")
+		admin.HandleFunc("/runs", s.AdminTriggerRunHandler).Methods("POST")
+		admin.HandleFunc("/blockwords", s.AdminBlockWordsHandler).Methods("GET")
+		admin.HandleFunc("/blockwords", s.AdminCreateBlockWordHandler).Methods("POST")
+		admin.HandleFunc("/blockwords/{id:[0-9]+}/delete", s.AdminDeleteBlockWordHandler).Methods("POST")
+	}
+
 	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", CacheControl(http.FileServer(http.Dir("static")))))
 	return Logging(router)
 }
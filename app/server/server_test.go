@@ -1,14 +1,20 @@
 package server
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/tunedmystic/commits.lol/app/config"
 	"github.com/tunedmystic/commits.lol/app/db"
 	"github.com/tunedmystic/commits.lol/app/models"
@@ -40,7 +46,7 @@ func TestRoutes(t *testing.T) {
 
 func Test_IndexHandler_renders_index_page(t *testing.T) {
 	mockDB := db.MockDB{
-		RecentCommitsByGroupMock: func(group string) (models.GitCommits, error) {
+		RecentCommitsByGroupMock: func(group, lang string) (models.GitCommits, error) {
 			return mockGitCommits(), nil
 		},
 	}
@@ -56,7 +62,7 @@ func Test_IndexHandler_renders_index_page(t *testing.T) {
 
 func Test_IndexHandler_renders_commits_fragment(t *testing.T) {
 	mockDB := db.MockDB{
-		RecentCommitsByGroupMock: func(group string) (models.GitCommits, error) {
+		RecentCommitsByGroupMock: func(group, lang string) (models.GitCommits, error) {
 			return mockGitCommits(), nil
 		},
 	}
@@ -77,9 +83,29 @@ func Test_IndexHandler_renders_commits_fragment(t *testing.T) {
 	u.AssertEqual(t, w.Code, http.StatusOK)
 }
 
+func Test_IndexHandler_filters(t *testing.T) {
+	filters := []string{}
+	mockDB := db.MockDB{
+		RecentCommitsByGroupMock: func(group, lang string) (models.GitCommits, error) {
+			filters = append(filters, group, lang)
+			return mockGitCommits(), nil
+		},
+	}
+
+	s := NewServer(&mockDB)
+	r := httptest.NewRequest(http.MethodGet, "/?group=poop&lang=es&fragment=true", nil)
+	w := httptest.NewRecorder()
+
+	http.HandlerFunc(s.IndexHandler).ServeHTTP(w, r)
+
+	u.AssertEqual(t, w.Code, http.StatusOK)
+	u.AssertEqual(t, filters[0], "poop")
+	u.AssertEqual(t, filters[1], "es")
+}
+
 func Test_IndexHandler_DB_error(t *testing.T) {
 	mockDB := db.MockDB{
-		RecentCommitsByGroupMock: func(group string) (models.GitCommits, error) {
+		RecentCommitsByGroupMock: func(group, lang string) (models.GitCommits, error) {
 			return models.GitCommits{}, errors.New("boom")
 		},
 	}
@@ -97,3 +123,428 @@ func Test_IndexHandler_DB_error(t *testing.T) {
 	body, _ := ioutil.ReadAll(resp.Body)
 	u.AssertEqual(t, string(body), "oopsie, something went horribly wrong\n")
 }
+
+func Test_CommitHandler(t *testing.T) {
+	mockDB := db.MockDB{
+		GetCommitMock: func(ID int) (*models.GitCommit, error) {
+			if ID != 7 {
+				return nil, nil
+			}
+			return &models.GitCommit{
+				ID:           7,
+				Message:      "Fixed a bug",
+				Body:         "It was <b>not</b> a feature",
+				BodyCensored: "It was &lt;b&gt;not&lt;/b&gt; a feature",
+			}, nil
+		},
+	}
+
+	s := NewServer(&mockDB)
+	w := httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/commits/7", nil))
+
+	u.AssertEqual(t, w.Code, http.StatusOK)
+
+	body := w.Body.String()
+	u.AssertEqual(t, strings.Contains(body, "Fixed a bug"), true)
+	u.AssertEqual(t, strings.Contains(body, "It was &lt;b&gt;not&lt;/b&gt; a feature"), true)
+	u.AssertEqual(t, strings.Contains(body, "<b>not</b>"), false)
+
+	w = httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/commits/8", nil))
+	u.AssertEqual(t, w.Code, http.StatusNotFound)
+}
+
+func Test_AdminRunsHandler(t *testing.T) {
+	now := time.Now().UTC()
+	run := models.PipelineRun{ID: 7, Trigger: "cron", StartedAt: now, FinishedAt: now.Add(time.Second), Saved: 12}
+	run.SetTerms([]string{"fixed a bug"})
+	run.SetErrors([]string{"search failed"})
+
+	mockDB := db.MockDB{
+		RecentPipelineRunsMock: func(limit int) (models.PipelineRuns, error) {
+			return models.PipelineRuns{run}, nil
+		},
+	}
+
+	s := NewServer(&mockDB)
+	r := httptest.NewRequest(http.MethodGet, "/admin/runs", nil)
+	w := httptest.NewRecorder()
+
+	http.HandlerFunc(s.AdminRunsHandler).ServeHTTP(w, r)
+
+	u.AssertEqual(t, w.Code, http.StatusOK)
+
+	body := w.Body.String()
+	u.AssertEqual(t, strings.Contains(body, "fixed a bug"), true)
+	u.AssertEqual(t, strings.Contains(body, "search failed"), true)
+	u.AssertEqual(t, strings.Contains(body, "1 errors"), true)
+}
+
+func Test_AdminTriggerRunHandler(t *testing.T) {
+	s := NewServer(&db.MockDB{})
+	r := httptest.NewRequest(http.MethodPost, "/admin/runs", nil)
+	w := httptest.NewRecorder()
+
+	// The pipeline can't be triggered without a RunPipeline func.
+	http.HandlerFunc(s.AdminTriggerRunHandler).ServeHTTP(w, r)
+	u.AssertEqual(t, w.Code, http.StatusServiceUnavailable)
+
+	triggered := make(chan bool, 1)
+	finish := make(chan bool)
+	s.RunPipeline = func() {
+		triggered <- true
+		<-finish
+	}
+
+	w = httptest.NewRecorder()
+	http.HandlerFunc(s.AdminTriggerRunHandler).ServeHTTP(w, r)
+
+	u.AssertEqual(t, w.Code, http.StatusSeeOther)
+	u.AssertEqual(t, w.Header().Get("Location"), "/admin/runs?triggered=1")
+	u.AssertEqual(t, <-triggered, true)
+
+	// Another run can't start while the run is in progress.
+	w = httptest.NewRecorder()
+	http.HandlerFunc(s.AdminTriggerRunHandler).ServeHTTP(w, r)
+	u.AssertEqual(t, w.Code, http.StatusConflict)
+
+	// It can once the run is done.
+	finish <- true
+	for atomic.LoadInt32(&s.running) == 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	w = httptest.NewRecorder()
+	http.HandlerFunc(s.AdminTriggerRunHandler).ServeHTTP(w, r)
+	u.AssertEqual(t, w.Code, http.StatusSeeOther)
+	u.AssertEqual(t, <-triggered, true)
+	finish <- true
+}
+
+func Test_AdminBlockWordsHandler(t *testing.T) {
+	mockDB := db.MockDB{
+		AllBlockWordsMock: func() (models.BlockWords, error) {
+			return models.BlockWords{{ID: 3, Text: "hateful"}}, nil
+		},
+	}
+
+	s := NewServer(&mockDB)
+	r := httptest.NewRequest(http.MethodGet, "/admin/blockwords", nil)
+	w := httptest.NewRecorder()
+
+	http.HandlerFunc(s.AdminBlockWordsHandler).ServeHTTP(w, r)
+
+	u.AssertEqual(t, w.Code, http.StatusOK)
+
+	body := w.Body.String()
+	u.AssertEqual(t, strings.Contains(body, "hateful"), true)
+	u.AssertEqual(t, strings.Contains(body, "/admin/blockwords/3/delete"), true)
+}
+
+func Test_AdminCreateBlockWordHandler(t *testing.T) {
+	created := ""
+	mockDB := db.MockDB{
+		CreateBlockWordMock: func(word *models.BlockWord) error {
+			created = word.Text
+			return nil
+		},
+	}
+
+	s := NewServer(&mockDB)
+	form := url.Values{"text": {"  Hateful "}}
+
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/admin/blockwords", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+
+	w := httptest.NewRecorder()
+	http.HandlerFunc(s.AdminCreateBlockWordHandler).ServeHTTP(w, newRequest())
+	u.AssertEqual(t, w.Code, http.StatusSeeOther)
+	u.AssertEqual(t, w.Header().Get("Location"), "/admin/blockwords")
+	u.AssertEqual(t, created, "hateful")
+
+	// The saved commits are reprocessed, when there's a Reprocess func.
+	reprocessed := make(chan bool, 1)
+	s.Reprocess = func() {
+		reprocessed <- true
+	}
+
+	w = httptest.NewRecorder()
+	http.HandlerFunc(s.AdminCreateBlockWordHandler).ServeHTTP(w, newRequest())
+	u.AssertEqual(t, w.Header().Get("Location"), "/admin/blockwords?reprocessed=1")
+	u.AssertEqual(t, <-reprocessed, true)
+
+	// Only one reprocess runs at a time. The posts made in the meantime run it once more.
+	idle := func() {
+		for atomic.LoadInt32(&s.reprocessing) == 1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	idle()
+
+	release := make(chan bool)
+	runs := int32(0)
+	s.Reprocess = func() {
+		atomic.AddInt32(&runs, 1)
+		<-release
+	}
+
+	http.HandlerFunc(s.AdminCreateBlockWordHandler).ServeHTTP(httptest.NewRecorder(), newRequest())
+	for atomic.LoadInt32(&runs) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		http.HandlerFunc(s.AdminCreateBlockWordHandler).ServeHTTP(httptest.NewRecorder(), newRequest())
+	}
+	release <- true
+	release <- true
+	idle()
+	u.AssertEqual(t, atomic.LoadInt32(&runs), int32(2))
+
+	// A panic doesn't crash the server, and the next post reprocesses again.
+	s.Reprocess = func() {
+		panic("db.Open failed")
+	}
+	http.HandlerFunc(s.AdminCreateBlockWordHandler).ServeHTTP(httptest.NewRecorder(), newRequest())
+	idle()
+
+	s.Reprocess = func() {
+		reprocessed <- true
+	}
+	http.HandlerFunc(s.AdminCreateBlockWordHandler).ServeHTTP(httptest.NewRecorder(), newRequest())
+	u.AssertEqual(t, <-reprocessed, true)
+
+	// Empty words are not valid.
+	form = url.Values{"text": {" "}}
+	w = httptest.NewRecorder()
+	http.HandlerFunc(s.AdminCreateBlockWordHandler).ServeHTTP(w, newRequest())
+	u.AssertEqual(t, w.Code, http.StatusBadRequest)
+}
+
+func Test_AdminDeleteBlockWordHandler(t *testing.T) {
+	deleted := 0
+	mockDB := db.MockDB{
+		DeleteBlockWordMock: func(ID int) error {
+			deleted = ID
+			return nil
+		},
+	}
+
+	s := NewServer(&mockDB)
+	r := httptest.NewRequest(http.MethodPost, "/admin/blockwords/3/delete", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": "3"})
+	w := httptest.NewRecorder()
+
+	http.HandlerFunc(s.AdminDeleteBlockWordHandler).ServeHTTP(w, r)
+
+	u.AssertEqual(t, w.Code, http.StatusSeeOther)
+	u.AssertEqual(t, deleted, 3)
+}
+
+func Test_Routes_admin(t *testing.T) {
+	// The admin pages are not served without a password.
+	s := NewServer(&db.MockDB{})
+	w := httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/runs", nil))
+	u.AssertEqual(t, w.Code, http.StatusNotFound)
+
+	config.App.AdminPassword = "hunter2"
+	defer func() { config.App.AdminPassword = "" }()
+
+	w = httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/runs", nil))
+	u.AssertEqual(t, w.Code, http.StatusUnauthorized)
+
+	// A run can't be triggered from another site.
+	s.RunPipeline = func() {}
+	r := httptest.NewRequest(http.MethodPost, "/admin/runs", nil)
+	r.SetBasicAuth(config.App.AdminUsername, config.App.AdminPassword)
+	r.Header.Set("Origin", "https://evil.example.com")
+
+	w = httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, r)
+	u.AssertEqual(t, w.Code, http.StatusForbidden)
+
+	r.Header.Set("Origin", "http://example.com")
+	w = httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, r)
+	u.AssertEqual(t, w.Code, http.StatusSeeOther)
+
+	// Neither can the block words be changed.
+	s.DB = &db.MockDB{
+		CreateBlockWordMock: func(word *models.BlockWord) error {
+			t.Error("the block word was created")
+			return nil
+		},
+		DeleteBlockWordMock: func(ID int) error {
+			t.Error("the block word was deleted")
+			return nil
+		},
+	}
+
+	for _, path := range []string{"/admin/blockwords", "/admin/blockwords/3/delete"} {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader("text=slur"))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth(config.App.AdminUsername, config.App.AdminPassword)
+		r.Header.Set("Origin", "https://evil.example.com")
+
+		w = httptest.NewRecorder()
+		s.Routes().ServeHTTP(w, r)
+		u.AssertEqual(t, w.Code, http.StatusForbidden)
+	}
+}
+
+func mockSearchDB() db.MockDB {
+	return db.MockDB{
+		SearchCommitsMock: func(query string, filters models.SearchFilters, page int) (models.SearchResults, error) {
+			return models.SearchResults{
+				Query: query,
+				Page:  page,
+				Commits: models.GitCommits{
+					{
+						ID:              7,
+						Message:         "fixed a crappy bug",
+						MessageCensored: `fixed a <span class="censored">c<span class="word">#%@$!</span></span> bug`,
+						Author:          models.GitUser{Username: "alice"},
+						Repo:            models.GitRepo{Name: "alice/repo"},
+					},
+					{ID: 8, Message: "fixed <b>another</b> bug"},
+				},
+				HasNext: true,
+			}, nil
+		},
+	}
+}
+
+func Test_SearchHandler(t *testing.T) {
+	mockDB := mockSearchDB()
+
+	s := NewServer(&mockDB)
+	w := httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?q=bug&lang=en&page=2", nil))
+
+	u.AssertEqual(t, w.Code, http.StatusOK)
+
+	body := w.Body.String()
+	u.AssertEqual(t, strings.Contains(body, `fixed a crappy <mark>bug</mark>`), true)
+	u.AssertEqual(t, strings.Contains(body, `<span class="word">#%@$!</span></span> <mark>bug</mark>`), true)
+	u.AssertEqual(t, strings.Contains(body, `fixed &lt;b&gt;another&lt;/b&gt; <mark>bug</mark>`), true)
+	u.AssertEqual(t, strings.Contains(body, `/search?q=bug&group=&lang=en&page=1`), true)
+	u.AssertEqual(t, strings.Contains(body, `/search?q=bug&group=&lang=en&page=3`), true)
+}
+
+func Test_SearchHandler_error(t *testing.T) {
+	mockDB := db.MockDB{
+		SearchCommitsMock: func(query string, filters models.SearchFilters, page int) (models.SearchResults, error) {
+			return models.SearchResults{}, errors.New("search failed")
+		},
+	}
+
+	s := NewServer(&mockDB)
+	w := httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?q=bug", nil))
+
+	u.AssertEqual(t, w.Code, http.StatusInternalServerError)
+}
+
+func Test_SearchAPIHandler(t *testing.T) {
+	var gotFilters models.SearchFilters
+	var gotPage int
+	mockDB := mockSearchDB()
+	search := mockDB.SearchCommitsMock
+	mockDB.SearchCommitsMock = func(query string, filters models.SearchFilters, page int) (models.SearchResults, error) {
+		gotFilters, gotPage = filters, page
+		return search(query, filters, page)
+	}
+
+	s := NewServer(&mockDB)
+	w := httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/search?q=+bug+&group=poop&page=nope", nil))
+
+	u.AssertEqual(t, w.Code, http.StatusOK)
+	u.AssertEqual(t, w.Header().Get("Content-Type"), "application/json")
+	u.AssertEqual(t, gotFilters.Group, "poop")
+	u.AssertEqual(t, gotPage, 1)
+
+	var response searchResponse
+	json.NewDecoder(w.Body).Decode(&response)
+
+	u.AssertEqual(t, response.Query, "bug")
+	u.AssertEqual(t, response.Page, 1)
+	u.AssertEqual(t, response.HasNext, true)
+	u.AssertEqual(t, len(response.Commits), 2)
+	u.AssertEqual(t, response.Commits[0].Message, "fixed a c#%@$! bug")
+	u.AssertEqual(t, response.Commits[0].Censored, true)
+	u.AssertEqual(t, response.Commits[0].Author, "alice")
+	u.AssertEqual(t, response.Commits[0].Repo, "alice/repo")
+	u.AssertEqual(t, response.Commits[0].Permalink, config.App.BaseURL+"/commits/7")
+	u.AssertEqual(t, response.Commits[1].Highlighted, "fixed &lt;b&gt;another&lt;/b&gt; <mark>bug</mark>")
+	u.AssertEqual(t, response.Commits[1].Censored, false)
+
+	// The raw messages are returned when `uncensored` is true.
+	w = httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/search?q=bug&uncensored=true", nil))
+	json.NewDecoder(w.Body).Decode(&response)
+
+	u.AssertEqual(t, response.Commits[0].Message, "fixed a crappy bug")
+	u.AssertEqual(t, response.Commits[0].Highlighted, "fixed a crappy <mark>bug</mark>")
+	u.AssertEqual(t, response.Commits[0].Censored, true)
+
+	// The words are matched in the text, not in its escaped HTML.
+	w = httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/search?q=lt", nil))
+	json.NewDecoder(w.Body).Decode(&response)
+
+	u.AssertEqual(t, response.Commits[1].Highlighted, "fixed &lt;b&gt;another&lt;/b&gt; bug")
+}
+
+func Test_Server_with_memory_db(t *testing.T) {
+	mem := db.NewMemoryDB()
+	commits := models.GitCommits{
+		{Message: "fixed a bug", MessageCensored: "fixed a bug", Group: "poop"},
+		{Message: "holy moly it works", MessageCensored: "holy moly it works", Group: "holy"},
+		{Message: "fixed the build", MessageCensored: "fixed the build", Group: "poop"},
+	}
+	for i := range commits {
+		commits[i].Valid = i != 2
+		commits[i].Date = time.Now().UTC()
+		commits[i].URL = fmt.Sprintf("https://github.com/alice/gems/commit/%d", i)
+		commits[i].Author = models.GitUser{Username: "alice", URL: "https://github.com/alice"}
+		commits[i].Repo = models.GitRepo{Name: "gems", URL: "https://github.com/alice/gems"}
+	}
+	created, err := mem.SaveCommitBatch(commits)
+	u.AssertEqual(t, err, nil)
+
+	s := NewServer(mem)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// The homepage shows the valid commits of the group.
+	w := get("/?group=poop")
+	u.AssertEqual(t, w.Code, http.StatusOK)
+	u.AssertEqual(t, strings.Contains(w.Body.String(), "fixed a bug"), true)
+	u.AssertEqual(t, strings.Contains(w.Body.String(), "holy moly it works"), false)
+	u.AssertEqual(t, strings.Contains(w.Body.String(), "fixed the build"), false)
+
+	// The commit page shows the valid commits only.
+	w = get(fmt.Sprintf("/commits/%d", created[1].ID))
+	u.AssertEqual(t, w.Code, http.StatusOK)
+	u.AssertEqual(t, strings.Contains(w.Body.String(), "holy moly it works"), true)
+
+	w = get(fmt.Sprintf("/commits/%d", created[2].ID))
+	u.AssertEqual(t, w.Code, http.StatusNotFound)
+
+	// The search finds the valid commits with the words.
+	w = get("/api/search?q=fixed")
+	var response searchResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	u.AssertEqual(t, len(response.Commits), 1)
+	u.AssertEqual(t, response.Commits[0].ID, created[0].ID)
+	u.AssertEqual(t, response.Commits[0].Author, "alice")
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"math"
+	"strings"
+)
+
+// Scorer defines behavior for a content scorer.
+type Scorer interface {
+	Score(text string) float64
+}
+
+// HumorExample is a commit message that was labeled as funny or not.
+type HumorExample struct {
+	Message string
+	Funny   bool
+}
+
+// HumorClassifier is a naive Bayes classifier, over the words and word pairs of
+// the normalized commit message. It's small enough to be stored as JSON.
+type HumorClassifier struct {
+	FunnyDocs      int            `json:"funny_docs"`
+	NotFunnyDocs   int            `json:"not_funny_docs"`
+	FunnyTokens    map[string]int `json:"funny_tokens"`
+	NotFunnyTokens map[string]int `json:"not_funny_tokens"`
+	FunnyTotal     int            `json:"funny_total"`
+	NotFunnyTotal  int            `json:"not_funny_total"`
+}
+
+// TrainHumorClassifier counts the tokens of the examples, and returns a new *HumorClassifier.
+func TrainHumorClassifier(examples []HumorExample) *HumorClassifier {
+	c := HumorClassifier{
+		FunnyTokens:    map[string]int{},
+		NotFunnyTokens: map[string]int{},
+	}
+
+	for _, example := range examples {
+		tokens := humorTokens(example.Message)
+
+		if example.Funny {
+			c.FunnyDocs++
+			c.FunnyTotal += len(tokens)
+			for _, token := range tokens {
+				c.FunnyTokens[token]++
+			}
+			continue
+		}
+
+		c.NotFunnyDocs++
+		c.NotFunnyTotal += len(tokens)
+		for _, token := range tokens {
+			c.NotFunnyTokens[token]++
+		}
+	}
+
+	return &c
+}
+
+// Score returns the probability that the message is funny, between 0 and 1.
+// Returns 0.5 if the classifier wasn't trained with both funny and not funny examples.
+func (c *HumorClassifier) Score(text string) float64 {
+	if c.FunnyDocs == 0 || c.NotFunnyDocs == 0 {
+		return 0.5
+	}
+
+	// The vocabulary size is used for Laplace smoothing of unseen tokens.
+	vocabulary := len(c.FunnyTokens)
+	for token := range c.NotFunnyTokens {
+		if _, ok := c.FunnyTokens[token]; !ok {
+			vocabulary++
+		}
+	}
+
+	logOdds := math.Log(float64(c.FunnyDocs)) - math.Log(float64(c.NotFunnyDocs))
+
+	for _, token := range humorTokens(text) {
+		funny := float64(c.FunnyTokens[token]+1) / float64(c.FunnyTotal+vocabulary)
+		notFunny := float64(c.NotFunnyTokens[token]+1) / float64(c.NotFunnyTotal+vocabulary)
+		logOdds += math.Log(funny) - math.Log(notFunny)
+	}
+
+	return 1 / (1 + math.Exp(-logOdds))
+}
+
+// humorTokens returns the words and word pairs of the normalized message.
+func humorTokens(message string) []string {
+	words := strings.Fields(NormalizeMessage(message))
+
+	tokens := make([]string, 0, len(words)*2)
+	for i, word := range words {
+		tokens = append(tokens, word)
+		if i > 0 {
+			tokens = append(tokens, words[i-1]+" "+word)
+		}
+	}
+	return tokens
+}
+
+var _ Scorer = &HumorClassifier{}
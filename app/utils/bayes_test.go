@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func testHumorExamples() []HumorExample {
+	return []HumorExample{
+		{"why does this even work lol", true},
+		{"i have no idea what i am doing", true},
+		{"please work this time i beg you", true},
+		{"fixed the stupid bug again lol", true},
+		{"who wrote this garbage oh wait it was me", true},
+		{"update dependencies", false},
+		{"add unit tests for the parser", false},
+		{"refactor the config loader", false},
+		{"update the changelog", false},
+		{"fix typo in the docs", false},
+	}
+}
+
+func Test_HumorClassifier_Score(t *testing.T) {
+	c := TrainHumorClassifier(testHumorExamples())
+
+	AssertEqual(t, c.FunnyDocs, 5)
+	AssertEqual(t, c.NotFunnyDocs, 5)
+
+	AssertEqual(t, c.Score("no idea why this works lol") > 0.7, true)
+	AssertEqual(t, c.Score("update the parser tests") < 0.3, true)
+	AssertEqual(t, c.Score("i beg you, please work") > c.Score("refactor the docs"), true)
+
+	// Messages with unseen words are close to neutral.
+	score := c.Score("xyzzy")
+	AssertEqual(t, score > 0.4 && score < 0.6, true)
+}
+
+func Test_HumorClassifier_untrained(t *testing.T) {
+	AssertEqual(t, TrainHumorClassifier(nil).Score("why does this even work"), 0.5)
+
+	// Only one class.
+	c := TrainHumorClassifier([]HumorExample{{"lol", true}})
+	AssertEqual(t, c.Score("lol"), 0.5)
+}
+
+func Test_HumorClassifier_JSON(t *testing.T) {
+	c := TrainHumorClassifier(testHumorExamples())
+	data, _ := json.Marshal(c)
+
+	loaded := HumorClassifier{}
+	AssertEqual(t, json.Unmarshal(data, &loaded), nil)
+	AssertEqual(t, loaded.Score("why does this even work"), c.Score("why does this even work"))
+}
+
+func Test_humorTokens(t *testing.T) {
+	tokens := humorTokens("Fixed the BUG!")
+
+	AssertEqual(t, len(tokens), 5)
+	AssertEqual(t, tokens[0], "fixed")
+	AssertEqual(t, tokens[2], "fixed the")
+	AssertEqual(t, tokens[4], "the bug")
+}
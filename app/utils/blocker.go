@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"strings"
+)
+
+// ErrBlockedWord is returned when a commit message contains a blocked word.
+const ErrBlockedWord ValidationError = "validate CommitItem: commit message contains a blocked word"
+
+// Blocker defines behavior for a content blocker.
+type Blocker interface {
+	Block(text string) string
+}
+
+// MessageBlocker finds the blocked words (slurs and hate terms) in a message.
+// Unlike the bad words of the MessageCleaner, which are censored, a message
+// with a blocked word is not published at all.
+type MessageBlocker struct {
+	BlockWords []string
+}
+
+// NewMessageBlocker ...
+func NewMessageBlocker(blockWords []string) *MessageBlocker {
+	words := make([]string, 0, len(blockWords))
+	for _, word := range blockWords {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			words = append(words, word)
+		}
+	}
+	return &MessageBlocker{BlockWords: words}
+}
+
+// Block returns the first blocked word that's in the text, or an empty string.
+func (b *MessageBlocker) Block(text string) string {
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		for _, blockWord := range b.BlockWords {
+			if strings.Contains(word, blockWord) {
+				return blockWord
+			}
+		}
+	}
+	return ""
+}
+
+// Ensure the MessageBlocker type satisfies the Blocker interface.
+var _ Blocker = &MessageBlocker{}
@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"testing"
+)
+
+func Test_Blocker(t *testing.T) {
+	blocker := NewMessageBlocker([]string{"Hateful", " ", "slur"})
+	AssertEqual(t, len(blocker.BlockWords), 2)
+
+	AssertEqual(t, blocker.Block("fixed a bug"), "")
+	AssertEqual(t, blocker.Block("fixed a HATEFUL bug"), "hateful")
+	AssertEqual(t, blocker.Block("removed the slurs!"), "slur")
+}
+
+func Test_Blocker_no_block_words(t *testing.T) {
+	AssertEqual(t, NewMessageBlocker(nil).Block("fixed a bug"), "")
+}
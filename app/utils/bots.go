@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrBotCommit is returned when a Commit is made by a bot, or has an automated message.
+const ErrBotCommit ValidationError = "validate CommitItem: commit is automated"
+
+// DefaultBotLogins are the logins of well-known bots and CI services.
+var DefaultBotLogins = []string{
+	"dependabot", "dependabot-preview", "renovate", "renovate-bot", "greenkeeper",
+	"snyk-bot", "pyup-bot", "imgbot", "github-actions", "actions-user",
+	"pre-commit-ci", "allcontributors", "semantic-release-bot", "codecov",
+	"travis-ci", "circleci", "jenkins", "gitlab-ci", "web-flow", "weblate",
+	"transifex", "crowdin", "mergify", "kodiakhq", "restyled-io",
+}
+
+// DefaultBotMessagePatterns match the messages generated by tools, bots and web UIs.
+var DefaultBotMessagePatterns = []string{
+	`(?i)^merge (branch|pull request|remote-tracking branch|tag|commit)\b`,
+	`(?i)^merged? .* into `,
+	`(?i)^revert\b`,
+	`(?i)^bump \S+ from \S+ to \S+`,
+	`(?i)^(update|pin) dependency `,
+	`(?i)^chore\(deps(-dev)?\)`,
+	`(?i)^(auto-?generated|automated|automatic) `,
+	`(?i)\[(skip ci|ci skip|bot)\]`,
+	`(?i)^(update|create|delete|rename) \S+\.\w+$`,
+	`(?i)^add files via upload$`,
+	`(?i)^initial commit$`,
+}
+
+// BotDetector defines behavior for a detector of automated commits.
+type BotDetector interface {
+	Detect(username, message string) string
+}
+
+// CommitBotDetector detects bots by their login, and automated messages by their template.
+type CommitBotDetector struct {
+	Logins   map[string]bool
+	Patterns []*regexp.Regexp
+}
+
+// NewCommitBotDetector ...
+func NewCommitBotDetector(logins []string, patterns []string) (*CommitBotDetector, error) {
+	d := CommitBotDetector{
+		Logins:   map[string]bool{},
+		Patterns: []*regexp.Regexp{},
+	}
+
+	for _, login := range logins {
+		d.Logins[strings.ToLower(login)] = true
+	}
+
+	for _, pattern := range patterns {
+		r, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("utils.NewCommitBotDetector: %v", err)
+		}
+		d.Patterns = append(d.Patterns, r)
+	}
+
+	return &d, nil
+}
+
+// NewDefaultBotDetector returns a detector for the default bot logins and message patterns.
+func NewDefaultBotDetector() *CommitBotDetector {
+	d, err := NewCommitBotDetector(DefaultBotLogins, DefaultBotMessagePatterns)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// Detect returns the reason that the commit is automated, or
+// an empty string if it looks like it was written by a person.
+func (d *CommitBotDetector) Detect(username, message string) string {
+	login := strings.ToLower(username)
+
+	if strings.HasSuffix(login, "[bot]") {
+		return fmt.Sprintf("bot login %q", username)
+	}
+
+	if d.Logins[login] {
+		return fmt.Sprintf("known bot %q", username)
+	}
+
+	for _, pattern := range d.Patterns {
+		if pattern.MatchString(message) {
+			return fmt.Sprintf("automated message %q", pattern)
+		}
+	}
+
+	return ""
+}
+
+var _ BotDetector = &CommitBotDetector{}
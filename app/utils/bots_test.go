@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_CommitBotDetector(t *testing.T) {
+	d := NewDefaultBotDetector()
+
+	tests := []struct {
+		username string
+		message  string
+		bot      bool
+	}{
+		{"alice", "fixed a bug", false},
+		{"alice", "merge conflicts are the worst", false},
+		{"alice", "reverting my life choices", false},
+		{"alice", "updated the readme lol", false},
+		{"dependabot[bot]", "fixed a bug", true},
+		{"Dependabot", "fixed a bug", true},
+		{"renovate-bot", "fixed a bug", true},
+		{"alice", "Merge branch 'main' into dev", true},
+		{"alice", "Merge pull request #12 from alice/bugfix", true},
+		{"alice", "Revert \"fixed a bug\"", true},
+		{"alice", "Bump lodash from 4.17.15 to 4.17.19", true},
+		{"alice", "chore(deps): update eslint", true},
+		{"alice", "Update README.md", true},
+		{"alice", "Add files via upload", true},
+		{"alice", "Initial commit", true},
+		{"alice", "fixed the build [skip ci]", true},
+	}
+
+	for _, test := range tests {
+		reason := d.Detect(test.username, test.message)
+		AssertEqual(t, reason != "", test.bot)
+	}
+}
+
+func Test_CommitBotDetector_reason(t *testing.T) {
+	d := NewDefaultBotDetector()
+
+	AssertEqual(t, d.Detect("snyk[bot]", "fixed a bug"), `bot login "snyk[bot]"`)
+	AssertEqual(t, d.Detect("imgbot", "fixed a bug"), `known bot "imgbot"`)
+	AssertEqual(t, strings.HasPrefix(d.Detect("alice", "Initial commit"), "automated message"), true)
+}
+
+func Test_NewCommitBotDetector(t *testing.T) {
+	d, err := NewCommitBotDetector([]string{"Robo"}, []string{`^beep`})
+	AssertEqual(t, err, nil)
+	AssertEqual(t, d.Detect("robo", "fixed a bug") != "", true)
+	AssertEqual(t, d.Detect("alice", "beep boop") != "", true)
+	AssertEqual(t, d.Detect("dependabot", "fixed a bug"), "")
+
+	_, err = NewCommitBotDetector(nil, []string{"("})
+	AssertEqual(t, err != nil, true)
+}
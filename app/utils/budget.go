@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrBudgetSpent is returned by the API clients when their CallBudget is spent.
+var ErrBudgetSpent = errors.New("the API call budget was spent")
+
+// CallBudget is the amount of API calls that are left to a pipeline run.
+// It's shared by the clients of every search in the run, so it's safe for concurrent use.
+// A nil budget has no limit.
+type CallBudget struct {
+	mu        sync.Mutex
+	remaining int
+}
+
+// NewCallBudget returns a budget of the given amount of calls,
+// or nil if the amount is 0, as there's no limit then.
+func NewCallBudget(calls int) *CallBudget {
+	if calls <= 0 {
+		return nil
+	}
+	return &CallBudget{remaining: calls}
+}
+
+// Spend takes a call from the budget, before it's made.
+// Returns false if the budget is spent, and the call must not be made.
+func (b *CallBudget) Spend() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.remaining == 0 {
+		return false
+	}
+	b.remaining--
+	return true
+}
+
+// Spent checks if there are no calls left in the budget.
+func (b *CallBudget) Spent() bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.remaining == 0
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"sync"
+	"testing"
+)
+
+func Test_CallBudget(t *testing.T) {
+	budget := NewCallBudget(2)
+	AssertEqual(t, budget.Spent(), false)
+	AssertEqual(t, budget.Spend(), true)
+	AssertEqual(t, budget.Spend(), true)
+	AssertEqual(t, budget.Spent(), true)
+	AssertEqual(t, budget.Spend(), false)
+}
+
+func Test_CallBudget_no_limit(t *testing.T) {
+	budget := NewCallBudget(0)
+	AssertEqual(t, budget == nil, true)
+
+	for i := 0; i < 100; i++ {
+		AssertEqual(t, budget.Spend(), true)
+	}
+	AssertEqual(t, budget.Spent(), false)
+}
+
+func Test_CallBudget_concurrent(t *testing.T) {
+	budget := NewCallBudget(50)
+
+	spent := make(chan bool, 100)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			spent <- budget.Spend()
+		}()
+	}
+	wg.Wait()
+	close(spent)
+
+	calls := 0
+	for ok := range spent {
+		if ok {
+			calls++
+		}
+	}
+	AssertEqual(t, calls, 50)
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// htmlTags matches the tags of an HTML fragment.
+var htmlTags = regexp.MustCompile(`<[^>]*>`)
+
+// Highlight wraps the words in <mark> tags, wherever they appear in the text of the HTML fragment.
+// The words are matched regardless of case, and only in the unescaped text, never inside
+// the tags or the entities, so the censored messages keep their markup.
+// Example:  fixed a <b>bug</b>  ->  fixed a <b><mark>bug</mark></b>
+func Highlight(fragment string, words []string) string {
+	matcher := wordMatcher(words)
+	if matcher == nil {
+		return fragment
+	}
+
+	highlighted := strings.Builder{}
+	last := 0
+	for _, tag := range htmlTags.FindAllStringIndex(fragment, -1) {
+		highlighted.WriteString(highlight(html.UnescapeString(fragment[last:tag[0]]), matcher))
+		highlighted.WriteString(fragment[tag[0]:tag[1]])
+		last = tag[1]
+	}
+	highlighted.WriteString(highlight(html.UnescapeString(fragment[last:]), matcher))
+
+	return highlighted.String()
+}
+
+// HighlightText escapes the text as HTML, and wraps the words in <mark> tags,
+// wherever they appear in the text. The words are matched regardless of case.
+// Example:  fixed a <bug>  ->  fixed a &lt;<mark>bug</mark>&gt;
+func HighlightText(text string, words []string) string {
+	matcher := wordMatcher(words)
+	if matcher == nil {
+		return html.EscapeString(text)
+	}
+	return highlight(text, matcher)
+}
+
+// wordMatcher returns a regexp that matches any of the words, regardless of case,
+// or nil if there are no words.
+func wordMatcher(words []string) *regexp.Regexp {
+	patterns := []string{}
+	for _, word := range words {
+		if word != "" {
+			patterns = append(patterns, regexp.QuoteMeta(word))
+		}
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	// Longer words go first, so they win over the words they contain.
+	sort.SliceStable(patterns, func(i, j int) bool { return len(patterns[i]) > len(patterns[j]) })
+	return regexp.MustCompile("(?i)" + strings.Join(patterns, "|"))
+}
+
+// highlight wraps the matches of the text in <mark> tags, and escapes the rest of it as HTML.
+func highlight(text string, matcher *regexp.Regexp) string {
+	highlighted := strings.Builder{}
+	last := 0
+	for _, match := range matcher.FindAllStringIndex(text, -1) {
+		highlighted.WriteString(html.EscapeString(text[last:match[0]]))
+		highlighted.WriteString("<mark>" + html.EscapeString(text[match[0]:match[1]]) + "</mark>")
+		last = match[1]
+	}
+	highlighted.WriteString(html.EscapeString(text[last:]))
+
+	return highlighted.String()
+}
+
+// StripTags removes the tags of the HTML fragment, and unescapes its text.
+// Example:  s<span class="word">#%@$</span>  ->  s#%@$
+func StripTags(fragment string) string {
+	return html.UnescapeString(htmlTags.ReplaceAllString(fragment, ""))
+}
@@ -0,0 +1,50 @@
+package utils
+
+import "testing"
+
+func Test_Highlight(t *testing.T) {
+	tests := []struct {
+		fragment string
+		words    []string
+		expected string
+	}{
+		{"fixed a bug", []string{"bug"}, "fixed a <mark>bug</mark>"},
+		{"Fixed a BUG", []string{"fixed", "bug"}, "<mark>Fixed</mark> a <mark>BUG</mark>"},
+		{"fixed a bug", []string{}, "fixed a bug"},
+		{"fixed a bug", []string{"yolo"}, "fixed a bug"},
+		{"debugged the bug", []string{"bug", "debug"}, "<mark>debug</mark>ged the <mark>bug</mark>"},
+		{"a.b a+b", []string{"a+b"}, "a.b <mark>a+b</mark>"},
+		{"x &lt; y", []string{"<"}, "x <mark>&lt;</mark> y"},
+		// The entities are never split.
+		{"x &lt; y &amp; it&#39;s", []string{"lt", "amp", "39"}, "x &lt; y &amp; it&#39;s"},
+		// The tags are left alone.
+		{`s<span class="word">#%@$</span> span`, []string{"span"}, `s<span class="word">#%@$</span> <mark>span</mark>`},
+	}
+
+	for _, test := range tests {
+		AssertEqual(t, Highlight(test.fragment, test.words), test.expected)
+	}
+}
+
+func Test_HighlightText(t *testing.T) {
+	tests := []struct {
+		text     string
+		words    []string
+		expected string
+	}{
+		{"fixed a bug", []string{"bug"}, "fixed a <mark>bug</mark>"},
+		{"fixed a <bug>", []string{"bug"}, "fixed a &lt;<mark>bug</mark>&gt;"},
+		{"fixed a <bug>", []string{}, "fixed a &lt;bug&gt;"},
+		{"x < y & it's", []string{"lt", "amp", "39"}, "x &lt; y &amp; it&#39;s"},
+		{"x < y", []string{"<"}, "x <mark>&lt;</mark> y"},
+	}
+
+	for _, test := range tests {
+		AssertEqual(t, HighlightText(test.text, test.words), test.expected)
+	}
+}
+
+func Test_StripTags(t *testing.T) {
+	AssertEqual(t, StripTags(`s<span class="censored">h<span class="word">#%@</span></span> happens`), "sh#%@ happens")
+	AssertEqual(t, StripTags("x &lt; y"), "x < y")
+}
@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ErrMessageLanguage is returned when a commit message is not in one of the allowed languages.
+const ErrMessageLanguage ValidationError = "validate CommitItem: commit message is not in an allowed language"
+
+// minLanguageLetters is the amount of letters that a message needs, for its language to be detected.
+const minLanguageLetters = 10
+
+// minLanguageScore is the lowest similarity to a language profile, for the language to be detected.
+const minLanguageScore = 0.1
+
+// minLanguageMargin is how many times more similar the text has to be to its language, than to
+// the runner-up. Short texts are often close to several languages, and are left undetected.
+const minLanguageMargin = 1.2
+
+// languageScripts are the languages that are detected by their script alone.
+var languageScripts = []struct {
+	Lang   string
+	Script *unicode.RangeTable
+}{
+	{"ja", unicode.Hiragana},
+	{"ja", unicode.Katakana},
+	{"ko", unicode.Hangul},
+	{"zh", unicode.Han},
+	{"ru", unicode.Cyrillic},
+	{"el", unicode.Greek},
+	{"ar", unicode.Arabic},
+	{"he", unicode.Hebrew},
+	{"hi", unicode.Devanagari},
+	{"th", unicode.Thai},
+}
+
+// LanguageDetector defines behavior for a language classifier.
+type LanguageDetector interface {
+	Detect(text string) string
+}
+
+// TrigramLanguageDetector detects the language of a text, by comparing its trigrams
+// to the trigram profile of each language. The profiles are built from a small,
+// embedded corpus, so the detection works offline.
+type TrigramLanguageDetector struct {
+	profiles map[string]map[string]float64
+	langs    []string
+}
+
+// NewTrigramLanguageDetector builds the language profiles, and returns a new *TrigramLanguageDetector.
+func NewTrigramLanguageDetector() *TrigramLanguageDetector {
+	d := TrigramLanguageDetector{
+		profiles: map[string]map[string]float64{},
+	}
+
+	for lang, text := range languageCorpus {
+		d.profiles[lang] = normalize(trigrams(text))
+		d.langs = append(d.langs, lang)
+	}
+	sort.Strings(d.langs)
+
+	return &d
+}
+
+// Languages returns the languages that can be detected.
+func (d *TrigramLanguageDetector) Languages() []string {
+	langs := append([]string{}, d.langs...)
+	for _, script := range languageScripts {
+		if !containsString(langs, script.Lang) {
+			langs = append(langs, script.Lang)
+		}
+	}
+	return langs
+}
+
+// Detect returns the language code of the text, like "en",
+// or an empty string if the language could not be detected.
+func (d *TrigramLanguageDetector) Detect(text string) string {
+	text = strings.ToLower(text)
+
+	// Languages with their own script are detected by the script of most of the letters.
+	counts := map[string]int{}
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, script := range languageScripts {
+			if unicode.Is(script.Script, r) {
+				counts[script.Lang]++
+				break
+			}
+		}
+	}
+
+	// Japanese mixes kana with Han characters.
+	if counts["ja"] > 0 && (counts["ja"]+counts["zh"])*2 > letters {
+		return "ja"
+	}
+
+	for _, script := range languageScripts {
+		if counts[script.Lang]*2 > letters {
+			return script.Lang
+		}
+	}
+
+	// Latin languages are detected by their trigrams.
+	if letters < minLanguageLetters {
+		return ""
+	}
+
+	grams := normalize(trigrams(text))
+	bestLang, bestScore, runnerUpScore := "", 0.0, 0.0
+	for _, lang := range d.langs {
+		score := 0.0
+		for gram, weight := range grams {
+			score += weight * d.profiles[lang][gram]
+		}
+		if score > bestScore {
+			bestLang, bestScore, runnerUpScore = lang, score, bestScore
+		} else if score > runnerUpScore {
+			runnerUpScore = score
+		}
+	}
+
+	if bestScore < minLanguageScore || bestScore < runnerUpScore*minLanguageMargin {
+		return ""
+	}
+	return bestLang
+}
+
+// trigrams counts the 3-letter sequences of every word in the text.
+// Words are padded with spaces, so that their start and end are also counted.
+func trigrams(text string) map[string]float64 {
+	counts := map[string]float64{}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+
+	for _, word := range words {
+		runes := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			counts[string(runes[i:i+3])]++
+		}
+	}
+
+	return counts
+}
+
+// normalize scales the counts to a unit vector, so that texts of any length can be compared.
+func normalize(counts map[string]float64) map[string]float64 {
+	sum := 0.0
+	for _, count := range counts {
+		sum += count * count
+	}
+
+	norm := math.Sqrt(sum)
+	for gram := range counts {
+		counts[gram] /= norm
+	}
+	return counts
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+var _ LanguageDetector = &TrigramLanguageDetector{}
@@ -0,0 +1,107 @@
+package utils
+
+// languageCorpus is the sample text that the trigram profile of each language is built from.
+// It mixes everyday sentences with the words that show up in commit messages.
+var languageCorpus = map[string]string{
+	"en": `
+		fixed a bug in the login page. the tests are failing again and i have no idea why.
+		this should work now, i hope. added a new feature for the users, and removed the old code
+		that nobody was using. update the documentation with the latest changes. why does this
+		even work? please do not touch this function, it will break everything. another typo,
+		what was i thinking. finally made the build pass after three days of pain. this is the
+		worst code i have ever written, but it works and i am going home. the server crashed
+		because of a missing check, so i added it. refactor the whole thing because it was too
+		slow. it was working yesterday, i swear. sorry for the mess, i will clean it up later.
+		the quick brown fox jumps over the lazy dog. when the weather is nice we like to walk in
+		the park with our friends and talk about the things that happened during the week.
+		something went wrong with the database, and the changes were lost. make it faster and
+		easier to read. they said it would be easy, they were wrong. just one more small fix.
+		remove the debug logs and the console output. i hate javascript so much, and css too.
+		merge the changes from the main branch. bump the version of the dependencies. delete
+		unused files, clean up imports, rename variables, move the helpers into their own
+		module. handle errors properly this time. improve performance of the search query.
+		add more tests for the parser and the config loader. revert my last commit because it
+		broke production. update the readme, fix the links, fix formatting, fix lint warnings.
+		oops, forgot to commit this file. temporary hack, do not merge. works on my machine.
+	`,
+	"es": `
+		arreglado un error en la página de inicio. las pruebas están fallando otra vez y no
+		tengo ni idea de por qué. esto debería funcionar ahora, eso espero. añadida una nueva
+		función para los usuarios y eliminado el código viejo que nadie usaba. actualizar la
+		documentación con los últimos cambios. por qué esto funciona? por favor no toques esta
+		función, va a romper todo. otro error de escritura, en qué estaba pensando. por fin
+		pasa la compilación después de tres días de dolor. este es el peor código que he escrito,
+		pero funciona y me voy a casa. el servidor se cayó porque faltaba una comprobación, así
+		que la añadí. ayer funcionaba, lo juro. perdón por el desorden, lo limpio más tarde.
+		cuando hace buen tiempo nos gusta caminar por el parque con nuestros amigos y hablar de
+		las cosas que pasaron durante la semana. algo salió mal con la base de datos y se
+		perdieron los cambios. hacerlo más rápido y más fácil de leer. dijeron que sería fácil.
+	`,
+	"fr": `
+		correction d'un bug dans la page de connexion. les tests échouent encore et je ne sais
+		pas pourquoi. ça devrait marcher maintenant, j'espère. ajout d'une nouvelle fonction pour
+		les utilisateurs et suppression du vieux code que personne n'utilisait. mise à jour de la
+		documentation avec les derniers changements. pourquoi est-ce que ça marche? s'il vous
+		plaît ne touchez pas à cette fonction, elle va tout casser. encore une faute de frappe, à
+		quoi je pensais. enfin la compilation passe après trois jours de souffrance. c'est le pire
+		code que j'ai jamais écrit, mais il marche et je rentre chez moi. le serveur a planté
+		parce qu'il manquait une vérification, donc je l'ai ajoutée. ça marchait hier, je le jure.
+		désolé pour le bazar, je nettoierai plus tard. quand il fait beau nous aimons nous
+		promener dans le parc avec nos amis et parler des choses qui se sont passées pendant la
+		semaine. quelque chose s'est mal passé avec la base de données et les changements sont
+		perdus. rendre le code plus rapide et plus facile à lire. ils disaient que ce serait facile.
+	`,
+	"de": `
+		fehler auf der anmeldeseite behoben. die tests schlagen wieder fehl und ich habe keine
+		ahnung warum. das sollte jetzt funktionieren, hoffe ich. eine neue funktion für die
+		benutzer hinzugefügt und den alten code entfernt, den niemand benutzt hat. die
+		dokumentation mit den letzten änderungen aktualisiert. warum funktioniert das überhaupt?
+		bitte diese funktion nicht anfassen, sonst geht alles kaputt. noch ein tippfehler, was
+		habe ich mir dabei gedacht. endlich läuft der build nach drei tagen voller schmerzen. das
+		ist der schlechteste code, den ich je geschrieben habe, aber er funktioniert und ich gehe
+		nach hause. der server ist abgestürzt, weil eine prüfung gefehlt hat, also habe ich sie
+		hinzugefügt. gestern hat es noch funktioniert, ich schwöre. entschuldigung für das chaos,
+		ich räume später auf. wenn das wetter schön ist, gehen wir gerne mit unseren freunden im
+		park spazieren und reden über die dinge, die in der woche passiert sind. mit der datenbank
+		ist etwas schiefgelaufen und die änderungen sind verloren. schneller und leichter lesbar.
+	`,
+	"pt": `
+		corrigido um erro na página de login. os testes estão falhando de novo e eu não faço
+		ideia do porquê. isso deve funcionar agora, espero. adicionada uma nova função para os
+		usuários e removido o código antigo que ninguém usava. atualizar a documentação com as
+		últimas mudanças. por que isso funciona? por favor não mexa nessa função, ela vai quebrar
+		tudo. mais um erro de digitação, no que eu estava pensando. finalmente a compilação passou
+		depois de três dias de sofrimento. este é o pior código que eu já escrevi, mas funciona e
+		eu vou para casa. o servidor caiu porque faltava uma verificação, então eu adicionei.
+		ontem estava funcionando, eu juro. desculpa pela bagunça, vou limpar depois. quando o
+		tempo está bom nós gostamos de caminhar no parque com os nossos amigos e conversar sobre
+		as coisas que aconteceram durante a semana. alguma coisa deu errado com o banco de dados
+		e as mudanças foram perdidas. deixar mais rápido e mais fácil de ler. disseram que seria fácil.
+	`,
+	"it": `
+		corretto un errore nella pagina di accesso. i test stanno fallendo di nuovo e non ho
+		idea del perché. adesso dovrebbe funzionare, spero. aggiunta una nuova funzione per gli
+		utenti e rimosso il vecchio codice che nessuno usava. aggiornare la documentazione con
+		le ultime modifiche. perché questo funziona? per favore non toccare questa funzione, si
+		rompe tutto. un altro errore di battitura, a cosa stavo pensando. finalmente la
+		compilazione passa dopo tre giorni di sofferenza. questo è il codice peggiore che abbia
+		mai scritto, ma funziona e me ne vado a casa. il server si è bloccato perché mancava un
+		controllo, quindi l'ho aggiunto. ieri funzionava, lo giuro. scusate per il disordine,
+		pulisco più tardi. quando il tempo è bello ci piace passeggiare nel parco con i nostri
+		amici e parlare delle cose che sono successe durante la settimana. qualcosa è andato
+		storto con il database e le modifiche sono andate perse. renderlo più veloce e più facile.
+	`,
+	"nl": `
+		een fout op de inlogpagina opgelost. de tests falen weer en ik heb geen idee waarom. dit
+		zou nu moeten werken, hoop ik. een nieuwe functie voor de gebruikers toegevoegd en de
+		oude code verwijderd die niemand gebruikte. de documentatie bijgewerkt met de laatste
+		wijzigingen. waarom werkt dit eigenlijk? raak deze functie alsjeblieft niet aan, dan gaat
+		alles kapot. weer een typfout, waar dacht ik aan. eindelijk slaagt de build na drie dagen
+		van ellende. dit is de slechtste code die ik ooit heb geschreven, maar het werkt en ik ga
+		naar huis. de server crashte omdat er een controle ontbrak, dus die heb ik toegevoegd.
+		gisteren werkte het nog, echt waar. sorry voor de rommel, ik ruim het later op. als het
+		mooi weer is wandelen we graag in het park met onze vrienden en praten we over de dingen
+		die er deze week gebeurd zijn. er ging iets mis met de database en de wijzigingen zijn
+		verloren gegaan. sneller en makkelijker om te lezen. ze zeiden dat het makkelijk zou zijn.
+	`,
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"testing"
+)
+
+func Test_TrigramLanguageDetector(t *testing.T) {
+	d := NewTrigramLanguageDetector()
+
+	tests := []struct {
+		message  string
+		expected string
+	}{
+		{"fixed the stupid bug again", "en"},
+		{"why does this even work", "en"},
+		{"who wrote this garbage", "en"},
+		{"arreglado el error de la página", "es"},
+		{"pourquoi ça ne marche pas", "fr"},
+		{"warum geht das nicht", "de"},
+		{"corrigido o erro do servidor", "pt"},
+		{"perché non funziona", "it"},
+		{"waarom werkt dit niet", "nl"},
+		{"исправил баг", "ru"},
+		{"修复了错误", "zh"},
+		{"バグを修正", "ja"},
+		{"不具合を修正", "ja"},
+		{"버그 수정", "ko"},
+	}
+
+	for _, test := range tests {
+		AssertEqual(t, d.Detect(test.message), test.expected)
+	}
+}
+
+func Test_TrigramLanguageDetector_undetected(t *testing.T) {
+	d := NewTrigramLanguageDetector()
+
+	// Too short.
+	AssertEqual(t, d.Detect("wip"), "")
+	AssertEqual(t, d.Detect("fixed a bug"), "")
+
+	// Not like any language.
+	AssertEqual(t, d.Detect("asdfgh qwerty zxcvb"), "")
+
+	// Too close to several languages.
+	AssertEqual(t, d.Detect("never deploy on friday"), "")
+}
+
+func Test_TrigramLanguageDetector_Languages(t *testing.T) {
+	langs := NewTrigramLanguageDetector().Languages()
+
+	AssertEqual(t, langs[0], "de")
+	AssertEqual(t, containsString(langs, "en"), true)
+	AssertEqual(t, containsString(langs, "ja"), true)
+	AssertEqual(t, len(langs), 16)
+}
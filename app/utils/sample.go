@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"math"
+	"sort"
+)
+
+// WeightedSample picks n of the items at random, without replacement, favoring the ones
+// with higher weights, and returns their indexes. The weight func returns the weight of
+// the item at an index. The random func returns numbers in [0.0, 1.0), like rand.Float64.
+func WeightedSample(length, n int, weight func(i int) float64, random func() float64) []int {
+	// Weighted random sampling (Efraimidis-Spirakis): every item gets
+	// a key of random^(1/weight), and the items with the largest keys win.
+	type keyed struct {
+		index int
+		key   float64
+	}
+
+	items := make([]keyed, 0, length)
+	for i := 0; i < length; i++ {
+		items = append(items, keyed{i, math.Pow(random(), 1/weight(i))})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].key > items[j].key
+	})
+
+	if n > len(items) {
+		n = len(items)
+	}
+
+	indexes := make([]int, 0, n)
+	for _, item := range items[:n] {
+		indexes = append(indexes, item.index)
+	}
+	return indexes
+}
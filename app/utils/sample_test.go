@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func Test_WeightedSample(t *testing.T) {
+	weights := []float64{0.9, 0.1, 0.5}
+	weight := func(i int) float64 { return weights[i] }
+
+	// Every item is picked once, at most.
+	indexes := WeightedSample(len(weights), 10, weight, rand.Float64)
+	AssertEqual(t, len(indexes), 3)
+
+	seen := map[int]bool{}
+	for _, i := range indexes {
+		seen[i] = true
+	}
+	AssertEqual(t, len(seen), 3)
+
+	// The heaviest item is picked far more often than the others.
+	r := rand.New(rand.NewSource(1))
+	picks := map[int]int{}
+	for i := 0; i < 1000; i++ {
+		picks[WeightedSample(len(weights), 1, weight, r.Float64)[0]]++
+	}
+
+	AssertEqual(t, picks[0] > picks[2], true)
+	AssertEqual(t, picks[2] > picks[1], true)
+	AssertEqual(t, len(WeightedSample(0, 1, weight, r.Float64)), 0)
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"regexp"
+	"strings"
+)
+
+// SimhashThreshold is the maximum Hamming distance between the simhashes
+// of two messages, for them to be near-duplicates.
+const SimhashThreshold = 6
+
+// simhashMinLength is the length of the shortest normalized message with a simhash.
+// Shorter messages have too few shingles to tell them apart, so they only match exactly.
+const simhashMinLength = 12
+
+var (
+	urlRegex      = regexp.MustCompile(`https?://\S+`)
+	issueRefRegex = regexp.MustCompile(`\B#\d+\b`)
+	shaRegex      = regexp.MustCompile(`\b[0-9a-f]*[0-9][0-9a-f]*\b`)
+	nonAlnumRegex = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+)
+
+// NormalizeMessage reduces a commit message to the words that make it funny.
+// Casing, punctuation, urls, issue references, shas and numbers are removed.
+// Example:  "Fix stupid typo (#123)!!"  ->  "fix stupid typo"
+func NormalizeMessage(message string) string {
+	message = strings.ToLower(message)
+	message = urlRegex.ReplaceAllString(message, " ")
+	message = issueRefRegex.ReplaceAllString(message, " ")
+	message = nonAlnumRegex.ReplaceAllString(message, " ")
+	message = shaRegex.ReplaceAllString(message, " ")
+	return strings.Join(strings.Fields(message), " ")
+}
+
+// MessageHash returns the hash of the normalized message.
+// Messages that only differ in casing, punctuation, etc. have the same hash.
+func MessageHash(message string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(NormalizeMessage(message))))
+}
+
+// Simhash returns the simhash of the normalized message, over its 3-character shingles.
+// Similar messages have simhashes that differ in only a few bits.
+// Returns 0 for short messages.
+func Simhash(message string) uint64 {
+	normalized := NormalizeMessage(message)
+	if len(normalized) < simhashMinLength {
+		return 0
+	}
+
+	runes := []rune(" " + normalized + " ")
+
+	features := []string{}
+	for i := 0; i+3 <= len(runes); i++ {
+		features = append(features, string(runes[i:i+3]))
+	}
+
+	weights := [64]int{}
+	for _, feature := range features {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var hash uint64
+	for bit := 0; bit < 64; bit++ {
+		if weights[bit] > 0 {
+			hash |= 1 << bit
+		}
+	}
+	return hash
+}
+
+// HammingDistance returns the number of bits that differ between the two hashes.
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// SimhashBands splits the simhash into 8 bands of 8 bits.
+// Two simhashes within the SimhashThreshold share at least one band.
+func SimhashBands(hash uint64) [8]uint8 {
+	bands := [8]uint8{}
+	for i := range bands {
+		bands[i] = uint8(hash >> (8 * i))
+	}
+	return bands
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"testing"
+)
+
+func Test_NormalizeMessage(t *testing.T) {
+	tests := []struct {
+		message  string
+		expected string
+	}{
+		{"Fix stupid typo", "fix stupid typo"},
+		{"  fix   STUPID typo!!! ", "fix stupid typo"},
+		{"Fix stupid typo (#123)", "fix stupid typo"},
+		{"fix stupid typo in 3f2a9c1", "fix stupid typo in"},
+		{"fix stupid typo, see https://example.com/issue/4", "fix stupid typo see"},
+		{"café déjà vu", "café déjà vu"},
+		{"#!@", ""},
+	}
+
+	for _, test := range tests {
+		AssertEqual(t, NormalizeMessage(test.message), test.expected)
+	}
+}
+
+func Test_MessageHash(t *testing.T) {
+	AssertEqual(t, MessageHash("Fix stupid typo."), MessageHash("fix stupid typo (#12)"))
+	AssertEqual(t, MessageHash("fix stupid typo") == MessageHash("fix stupid typos"), false)
+	AssertEqual(t, len(MessageHash("fix stupid typo")), 40)
+}
+
+func Test_Simhash(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		similar bool
+	}{
+		{"fix the stupid typo in the readme", "fix the stupid typo in the readme again", true},
+		{"oops forgot to add the file", "oops, forgot to add the files", true},
+		{"why does this even work", "why does this even work lol", true},
+		{"fix stupid typo", "added unit tests", false},
+		{"i hate javascript", "i hate css", false},
+	}
+
+	for _, test := range tests {
+		distance := HammingDistance(Simhash(test.a), Simhash(test.b))
+		AssertEqual(t, distance <= SimhashThreshold, test.similar)
+	}
+
+	// Normalized messages have the same simhash.
+	AssertEqual(t, Simhash("Fix stupid typo!"), Simhash("fix stupid typo"))
+	AssertEqual(t, Simhash("Fix stupid typo!!!") == 0, false)
+
+	// Short messages don't have a simhash.
+	AssertEqual(t, Simhash("fix typo"), uint64(0))
+	AssertEqual(t, Simhash(""), uint64(0))
+}
+
+func Test_SimhashBands(t *testing.T) {
+	bands := SimhashBands(0x0807060504030201)
+
+	for i, band := range bands {
+		AssertEqual(t, band, uint8(i+1))
+	}
+
+	// Hashes within the threshold share a band.
+	a := uint64(0xffffffffffffffff)
+	b := a ^ (1 | 1<<9 | 1<<18 | 1<<27 | 1<<36 | 1<<45 | 1<<54)
+	shared := false
+	for i, band := range SimhashBands(a) {
+		if SimhashBands(b)[i] == band {
+			shared = true
+		}
+	}
+	AssertEqual(t, shared, true)
+}
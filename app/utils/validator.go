@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ValidationError is returned when a Commit is not valid.
+type ValidationError string
+
+func (err ValidationError) Error() string {
+	return string(err)
+}
+
+// Errors raised by commit message validation.
+const (
+	ErrMessageLength   ValidationError = "validate CommitItem: commit message too long"
+	ErrMessageShort    ValidationError = "validate CommitItem: commit message too short"
+	ErrMessageFormat   ValidationError = "validate CommitItem: commit message has formatting issues"
+	ErrMessageScript   ValidationError = "validate CommitItem: commit message has letters from another script"
+	ErrMessageEmoji    ValidationError = "validate CommitItem: commit message has emoji"
+	ErrMessageRejected ValidationError = "validate CommitItem: commit message matches a reject pattern"
+)
+
+// DefaultMessagePattern is the format that commit messages are required to have, by default.
+// It starts with a letter, and only has letters, digits and some punctuation.
+const DefaultMessagePattern = `^[a-zA-Z][\w '#%\.\!\:\-\)\(]+$`
+
+// ValidationRules are the rules that a commit message must follow.
+// They can be loaded from a JSON file, with the same keys.
+type ValidationRules struct {
+	MinLength       int      `json:"min_length"`
+	MaxLength       int      `json:"max_length"` // 0 means no limit
+	Scripts         []string `json:"scripts"`    // Unicode script names, like "Latin". Empty allows any script.
+	AllowEmoji      bool     `json:"allow_emoji"`
+	RejectPatterns  []string `json:"reject_patterns"`  // The message must not match any of these.
+	RequirePatterns []string `json:"require_patterns"` // The message must match all of these.
+
+	// DisableDefaultPattern drops the DefaultMessagePattern from the require patterns of a file.
+	DisableDefaultPattern bool `json:"disable_default_pattern"`
+}
+
+// DefaultValidationRules returns the rules that commit messages follow, by default.
+func DefaultValidationRules(maxLength int) ValidationRules {
+	return ValidationRules{
+		MinLength:       2,
+		MaxLength:       maxLength,
+		Scripts:         []string{"Latin"},
+		AllowEmoji:      false,
+		RequirePatterns: []string{DefaultMessagePattern},
+	}
+}
+
+// LoadValidationRules reads the rules from a JSON file.
+// The keys that are missing from the file keep the value of the given rules.
+// The require patterns of the file are added to the given ones, so the DefaultMessagePattern,
+// which keeps the markup out of the messages, is only dropped if the file disables it.
+func LoadValidationRules(path string, rules ValidationRules) (ValidationRules, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return rules, fmt.Errorf("utils.LoadValidationRules: %v", err)
+	}
+
+	loaded := rules
+	loaded.RequirePatterns = nil
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return rules, fmt.Errorf("utils.LoadValidationRules: %v", err)
+	}
+
+	patterns := []string{}
+	for _, pattern := range append(append([]string{}, rules.RequirePatterns...), loaded.RequirePatterns...) {
+		if pattern == DefaultMessagePattern && loaded.DisableDefaultPattern {
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+	loaded.RequirePatterns = patterns
+
+	return loaded, nil
+}
+
+// RuleCheck is the result of checking a message against one rule.
+type RuleCheck struct {
+	Rule   string
+	Passed bool
+	Detail string
+	Err    ValidationError
+}
+
+// MessageValidator checks commit messages against a set of ValidationRules.
+type MessageValidator struct {
+	Rules ValidationRules
+
+	scripts         []*unicode.RangeTable
+	rejectPatterns  []*regexp.Regexp
+	requirePatterns []*regexp.Regexp
+}
+
+// NewMessageValidator compiles the rules, and returns a new *MessageValidator.
+func NewMessageValidator(rules ValidationRules) (*MessageValidator, error) {
+	v := MessageValidator{Rules: rules}
+
+	for _, name := range rules.Scripts {
+		script, ok := unicode.Scripts[name]
+		if !ok {
+			return nil, fmt.Errorf("utils.NewMessageValidator: unknown script %q", name)
+		}
+		v.scripts = append(v.scripts, script)
+	}
+
+	for _, pattern := range rules.RejectPatterns {
+		r, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("utils.NewMessageValidator: %v", err)
+		}
+		v.rejectPatterns = append(v.rejectPatterns, r)
+	}
+
+	for _, pattern := range rules.RequirePatterns {
+		r, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("utils.NewMessageValidator: %v", err)
+		}
+		v.requirePatterns = append(v.requirePatterns, r)
+	}
+
+	return &v, nil
+}
+
+// Validate checks the message against the rules.
+// Returns the ValidationError of the first rule that the message breaks.
+func (v *MessageValidator) Validate(message string) error {
+	for _, check := range v.Explain(message) {
+		if !check.Passed {
+			return check.Err
+		}
+	}
+	return nil
+}
+
+// Explain checks the message against every rule, in order.
+func (v *MessageValidator) Explain(message string) []RuleCheck {
+	checks := []RuleCheck{}
+	length := utf8.RuneCountInString(message)
+
+	checks = append(checks, RuleCheck{
+		Rule:   "min length",
+		Passed: length >= v.Rules.MinLength,
+		Detail: fmt.Sprintf("%d characters, at least %d", length, v.Rules.MinLength),
+		Err:    ErrMessageShort,
+	})
+
+	if v.Rules.MaxLength > 0 {
+		checks = append(checks, RuleCheck{
+			Rule:   "max length",
+			Passed: length <= v.Rules.MaxLength,
+			Detail: fmt.Sprintf("%d characters, at most %d", length, v.Rules.MaxLength),
+			Err:    ErrMessageLength,
+		})
+	}
+
+	if !v.Rules.AllowEmoji {
+		emoji := findRune(message, isEmoji)
+		checks = append(checks, RuleCheck{
+			Rule:   "no emoji",
+			Passed: emoji == "",
+			Detail: foundDetail(emoji),
+			Err:    ErrMessageEmoji,
+		})
+	}
+
+	if len(v.scripts) > 0 {
+		letter := findRune(message, func(r rune) bool {
+			return unicode.IsLetter(r) && !unicode.In(r, v.scripts...)
+		})
+		checks = append(checks, RuleCheck{
+			Rule:   fmt.Sprintf("scripts %s", strings.Join(v.Rules.Scripts, ", ")),
+			Passed: letter == "",
+			Detail: foundDetail(letter),
+			Err:    ErrMessageScript,
+		})
+	}
+
+	for _, pattern := range v.rejectPatterns {
+		match := pattern.FindString(message)
+		checks = append(checks, RuleCheck{
+			Rule:   fmt.Sprintf("reject %s", pattern),
+			Passed: !pattern.MatchString(message),
+			Detail: foundDetail(match),
+			Err:    ErrMessageRejected,
+		})
+	}
+
+	for _, pattern := range v.requirePatterns {
+		checks = append(checks, RuleCheck{
+			Rule:   fmt.Sprintf("require %s", pattern),
+			Passed: pattern.MatchString(message),
+			Err:    ErrMessageFormat,
+		})
+	}
+
+	return checks
+}
+
+// findRune returns the first rune in the text that matches, or an empty string.
+func findRune(text string, match func(r rune) bool) string {
+	for _, r := range text {
+		if match(r) {
+			return string(r)
+		}
+	}
+	return ""
+}
+
+func foundDetail(found string) string {
+	if found == "" {
+		return ""
+	}
+	return fmt.Sprintf("found %q", found)
+}
+
+// isEmoji checks if the rune is in one of the emoji blocks,
+// or is used to join and style emoji.
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // emoticons, pictographs, symbols, flags
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // miscellaneous symbols, dingbats
+		return true
+	case r >= 0x2300 && r <= 0x23FF: // miscellaneous technical
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF: // miscellaneous symbols and arrows
+		return true
+	case r == 0x200D || r == 0xFE0F: // zero width joiner, variation selector
+		return true
+	}
+	return false
+}
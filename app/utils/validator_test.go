@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_MessageValidator_default_rules(t *testing.T) {
+	v, err := NewMessageValidator(DefaultValidationRules(20))
+	AssertEqual(t, err, nil)
+
+	tests := []struct {
+		message  string
+		expected error
+	}{
+		{"fixed a bug", nil},
+		{"fixed a 'bug'", nil},
+		{"x", ErrMessageShort},
+		{"fixed a bug fixed a bug", ErrMessageLength},
+		{"fixed a bug 🐛", ErrMessageEmoji},
+		{"fixed a bug ✨", ErrMessageEmoji},
+		{"исправил баг", ErrMessageScript},
+		{"café crème", ErrMessageFormat},
+		{"fixed [a] bug", ErrMessageFormat},
+	}
+
+	for _, test := range tests {
+		AssertEqual(t, v.Validate(test.message), test.expected)
+	}
+}
+
+func Test_MessageValidator_custom_rules(t *testing.T) {
+	v, err := NewMessageValidator(ValidationRules{
+		MinLength:       5,
+		Scripts:         []string{"Latin", "Cyrillic"},
+		AllowEmoji:      true,
+		RejectPatterns:  []string{`(?i)^merge `},
+		RequirePatterns: []string{`\s`},
+	})
+	AssertEqual(t, err, nil)
+
+	tests := []struct {
+		message  string
+		expected error
+	}{
+		{"исправил баг 🐛", nil},
+		{"café crème [wip]", nil},
+		{"bug", ErrMessageShort},
+		{"Merge branch main", ErrMessageRejected},
+		{"fixed-a-bug", ErrMessageFormat},
+		{"修复了错误 bug", ErrMessageScript},
+	}
+
+	for _, test := range tests {
+		AssertEqual(t, v.Validate(test.message), test.expected)
+	}
+}
+
+func Test_MessageValidator_invalid_rules(t *testing.T) {
+	_, err := NewMessageValidator(ValidationRules{Scripts: []string{"Klingon"}})
+	AssertEqual(t, err.Error(), `utils.NewMessageValidator: unknown script "Klingon"`)
+
+	_, err = NewMessageValidator(ValidationRules{RejectPatterns: []string{"("}})
+	AssertEqual(t, err != nil, true)
+}
+
+func Test_MessageValidator_Explain(t *testing.T) {
+	v, _ := NewMessageValidator(DefaultValidationRules(20))
+
+	checks := v.Explain("fixed a bug 🐛")
+	AssertEqual(t, len(checks), 5)
+
+	AssertEqual(t, checks[0].Rule, "min length")
+	AssertEqual(t, checks[0].Passed, true)
+	AssertEqual(t, checks[2].Rule, "no emoji")
+	AssertEqual(t, checks[2].Passed, false)
+	AssertEqual(t, checks[2].Detail, `found "🐛"`)
+
+	// Every rule is checked, even after one fails.
+	AssertEqual(t, checks[4].Passed, false)
+	AssertEqual(t, checks[4].Err, ErrMessageFormat)
+}
+
+func Test_LoadValidationRules(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "rules")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "rules.json")
+	ioutil.WriteFile(path, []byte(`{"max_length": 80, "allow_emoji": true}`), 0644)
+
+	rules, err := LoadValidationRules(path, DefaultValidationRules(45))
+	AssertEqual(t, err, nil)
+	AssertEqual(t, rules.MaxLength, 80)
+	AssertEqual(t, rules.AllowEmoji, true)
+
+	// The missing keys keep their default.
+	AssertEqual(t, rules.MinLength, 2)
+	AssertEqual(t, rules.Scripts[0], "Latin")
+
+	_, err = LoadValidationRules(filepath.Join(dir, "missing.json"), rules)
+	AssertEqual(t, err != nil, true)
+}
+
+func Test_LoadValidationRules_require_patterns(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "rules")
+	defer os.RemoveAll(dir)
+
+	// The require patterns of the file are added to the default pattern.
+	path := filepath.Join(dir, "rules.json")
+	ioutil.WriteFile(path, []byte(`{"require_patterns": ["\\s"]}`), 0644)
+
+	rules, err := LoadValidationRules(path, DefaultValidationRules(45))
+	AssertEqual(t, err, nil)
+	AssertEqual(t, len(rules.RequirePatterns), 2)
+	AssertEqual(t, rules.RequirePatterns[0], DefaultMessagePattern)
+	AssertEqual(t, rules.RequirePatterns[1], `\s`)
+
+	v, _ := NewMessageValidator(rules)
+	AssertEqual(t, v.Validate("fixed <script>a</script> bug"), ErrMessageFormat)
+
+	// The default pattern is only dropped if the file disables it.
+	ioutil.WriteFile(path, []byte(`{"require_patterns": [], "disable_default_pattern": true}`), 0644)
+
+	rules, err = LoadValidationRules(path, DefaultValidationRules(45))
+	AssertEqual(t, err, nil)
+	AssertEqual(t, len(rules.RequirePatterns), 0)
+}
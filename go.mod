@@ -9,6 +9,7 @@ require (
 	github.com/integrii/flaggy v1.4.4
 	github.com/jmoiron/sqlx v1.2.0
 	github.com/kelseyhightower/envconfig v1.4.0
+	github.com/lib/pq v1.10.0
 	github.com/mattn/go-sqlite3 v1.14.5
 	github.com/robfig/cron/v3 v3.0.1
 	go.uber.org/multierr v1.6.0 // indirect
@@ -4,10 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math/rand"
 	"net/http"
 	"os"
+	"strconv"
+	"text/tabwriter"
 	"time"
 
+	"github.com/getsentry/sentry-go"
 	"github.com/integrii/flaggy"
 	"github.com/robfig/cron/v3"
 	"go.uber.org/zap"
@@ -15,6 +19,7 @@ import (
 	"github.com/tunedmystic/commits.lol/app/clients/github"
 	"github.com/tunedmystic/commits.lol/app/config"
 	"github.com/tunedmystic/commits.lol/app/db"
+	"github.com/tunedmystic/commits.lol/app/models"
 	"github.com/tunedmystic/commits.lol/app/pipeline"
 	"github.com/tunedmystic/commits.lol/app/server"
 	"github.com/tunedmystic/commits.lol/app/utils"
@@ -41,6 +46,70 @@ func main() {
 	cmdFetchCommits.String(&fetchCommitsToDate, "t", "to", "AuthorDate to")
 	flaggy.AttachSubcommand(cmdFetchCommits, 1)
 
+	// The 'scan-repo' subcommand.
+	scanRepoPath := ""
+	cmdScanRepo := flaggy.NewSubcommand("scan-repo")
+	cmdScanRepo.Description = "Scan the history of a local git repository (or a directory of repositories)"
+	cmdScanRepo.AddPositionalValue(&scanRepoPath, "path", 1, true, "Path to the repository")
+	flaggy.AttachSubcommand(cmdScanRepo, 1)
+
+	// The 'ingest-gharchive' subcommand.
+	archivePath := ""
+	cmdIngestArchive := flaggy.NewSubcommand("ingest-gharchive")
+	cmdIngestArchive.Description = "Ingest commits from gzipped GH Archive files (or a directory of files)"
+	cmdIngestArchive.AddPositionalValue(&archivePath, "path", 1, true, "Path to the archive files")
+	flaggy.AttachSubcommand(cmdIngestArchive, 1)
+
+	// The 'runs' subcommand.
+	runsLimit := 20
+	cmdRuns := flaggy.NewSubcommand("runs")
+	cmdRuns.Description = "Show the history of the pipeline runs"
+	cmdRuns.Int(&runsLimit, "n", "limit", "Amount of runs to show")
+	flaggy.AttachSubcommand(cmdRuns, 1)
+
+	// The 'webhooks' subcommand.
+	webhooksLimit := 20
+	cmdWebhooks := flaggy.NewSubcommand("webhooks")
+	cmdWebhooks.Description = "Show the log of the webhook deliveries"
+	cmdWebhooks.Int(&webhooksLimit, "n", "limit", "Amount of deliveries to show")
+	flaggy.AttachSubcommand(cmdWebhooks, 1)
+
+	// The 'rank-terms' subcommand.
+	cmdRankTerms := flaggy.NewSubcommand("rank-terms")
+	cmdRankTerms.Description = "Re-rank the search terms by their recent yield"
+	flaggy.AttachSubcommand(cmdRankTerms, 1)
+
+	// The 'dedupe' subcommand.
+	cmdDedupe := flaggy.NewSubcommand("dedupe")
+	cmdDedupe.Description = "Cluster the near-duplicate commits under their canonical commit"
+	flaggy.AttachSubcommand(cmdDedupe, 1)
+
+	// The 'reprocess' subcommand.
+	cmdReprocess := flaggy.NewSubcommand("reprocess")
+	cmdReprocess.Description = "Detect the language of the saved commits, and flag the ones made by bots or with block words as not valid"
+	flaggy.AttachSubcommand(cmdReprocess, 1)
+
+	// The 'label' subcommand.
+	labelCommitID := ""
+	labelNotFunny := false
+	cmdLabel := flaggy.NewSubcommand("label")
+	cmdLabel.Description = "Label a commit as funny (or not), to train the humor model"
+	cmdLabel.AddPositionalValue(&labelCommitID, "id", 1, true, "The commit ID")
+	cmdLabel.Bool(&labelNotFunny, "n", "not-funny", "Label the commit as not funny")
+	flaggy.AttachSubcommand(cmdLabel, 1)
+
+	// The 'train' subcommand.
+	cmdTrain := flaggy.NewSubcommand("train")
+	cmdTrain.Description = "Train the humor model from the labeled commits, and rescore the commits"
+	flaggy.AttachSubcommand(cmdTrain, 1)
+
+	// The 'validate' subcommand.
+	validateMessage := ""
+	cmdValidate := flaggy.NewSubcommand("validate")
+	cmdValidate.Description = "Explain why a commit message is accepted or rejected"
+	cmdValidate.AddPositionalValue(&validateMessage, "message", 1, true, "The commit message")
+	flaggy.AttachSubcommand(cmdValidate, 1)
+
 	// The 'limits' subcommand.
 	cmdLimits := flaggy.NewSubcommand("limits")
 	cmdLimits.Description = "Check API rate limits"
@@ -48,6 +117,9 @@ func main() {
 
 	flaggy.Parse()
 
+	// Seed the random sampling of search terms.
+	rand.Seed(time.Now().UnixNano())
+
 	if len(os.Args) < 2 {
 		flaggy.ShowHelp("")
 		return
@@ -65,7 +137,47 @@ func main() {
 	if cmdFetchCommits.Used {
 		utils.MustParseDate(fetchCommitsFromDate)
 		utils.MustParseDate(fetchCommitsToDate)
-		FetchCommits(fetchCommitsFromDate, fetchCommitsToDate)
+		FetchCommits(fetchCommitsFromDate, fetchCommitsToDate, pipeline.TriggerCLI, false)
+	}
+
+	if cmdScanRepo.Used {
+		ScanRepo(scanRepoPath)
+	}
+
+	if cmdIngestArchive.Used {
+		IngestArchive(archivePath)
+	}
+
+	if cmdRuns.Used {
+		ShowRuns(runsLimit)
+	}
+
+	if cmdWebhooks.Used {
+		ShowWebhookDeliveries(webhooksLimit)
+	}
+
+	if cmdRankTerms.Used {
+		RankTerms()
+	}
+
+	if cmdDedupe.Used {
+		DedupeCommits()
+	}
+
+	if cmdReprocess.Used {
+		ReprocessCommits()
+	}
+
+	if cmdLabel.Used {
+		LabelCommit(labelCommitID, !labelNotFunny)
+	}
+
+	if cmdTrain.Used {
+		TrainHumorModel()
+	}
+
+	if cmdValidate.Used {
+		ValidateMessage(validateMessage)
 	}
 
 	if cmdLimits.Used {
@@ -76,10 +188,14 @@ func main() {
 // RunServer ...
 func RunServer() {
 	zap.S().Info("[run] server")
-	db := db.NewSqliteDB(config.App.DatabaseName)
+	db := db.Open(config.App.DatabaseSource())
 	defer db.Close()
 
-	s := server.NewServer(&db)
+	s := server.NewServer(db)
+	s.RunPipeline = func() {
+		FetchRecentCommits(pipeline.TriggerAdmin)
+	}
+	s.Reprocess = ReprocessCommits
 
 	addr := fmt.Sprintf("0.0.0.0:%v", config.App.Port)
 	zap.S().Info("Server is running on ", addr)
@@ -91,17 +207,24 @@ func RunTasks() {
 	zap.S().Info("[run] periodic tasks")
 	c := cron.New()
 	c.AddFunc("@every 60m", func() {
-		to := time.Now().UTC()
-		from := to.AddDate(0, 0, -3) // 3 days back.
-		FetchCommits(from.Format("2006-01-02"), to.Format("2006-01-02"))
+		FetchRecentCommits(pipeline.TriggerCron)
 	})
+	c.AddFunc("@daily", RankTerms)
 	c.Start()
 }
 
+// FetchRecentCommits fetches the newest commits of every term.
+// Each term is searched from its own watermark, within the last FetchDays.
+func FetchRecentCommits(trigger string) {
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -config.App.FetchDays)
+	FetchCommits(from.Format("2006-01-02"), to.Format("2006-01-02"), trigger, true)
+}
+
 // FetchCommits ...
-func FetchCommits(fromDate, toDate string) {
+func FetchCommits(fromDate, toDate, trigger string, withWatermarks bool) {
 	zap.S().Infof("[run] fetch-commits from %s to %s", fromDate, toDate)
-	db := db.NewSqliteDB(config.App.DatabaseName)
+	db := db.Open(config.App.DatabaseSource())
 	defer db.Close()
 
 	options := github.CommitSearchOptions{
@@ -111,13 +234,255 @@ func FetchCommits(fromDate, toDate string) {
 	}
 
 	// Run the commit pipeline with randomly fetched searchTerms.
-	p := pipeline.Commits(&db)
+	p := pipeline.Commits(db)
+	p.WithSources(CommitSources()...)
 	p.WithOptions(options)
 	p.WithRandomSearchTerms()
-	p.Run()
+	p.WithTrigger(trigger)
+	if withWatermarks {
+		p.WithWatermarks()
+	}
+	RunPipeline(db, p)
 	zap.S().Info("[done] fetch-commits")
 }
 
+// RunPipeline runs the commit pipeline, and sends its events to the configured webhooks.
+func RunPipeline(database db.Database, p pipeline.CommitPipeline) {
+	if len(config.App.Webhooks) > 0 {
+		webhooks := pipeline.NewWebhookSubscriber(database, config.App.Webhooks, config.App.WebhookSecret)
+		defer webhooks.Close()
+		p.WithSubscribers(webhooks)
+	}
+	p.Run()
+}
+
+// ScanRepo ...
+func ScanRepo(path string) {
+	zap.S().Infof("[run] scan-repo %s", path)
+	db := db.Open(config.App.DatabaseSource())
+	defer db.Close()
+
+	terms, err := db.AllSearchTerms()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	source, err := pipeline.NewLocalSource(path, terms.ToStrings())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Run the commit pipeline with every searchTerm, over the local history.
+	p := pipeline.Commits(db)
+	p.WithSources(source)
+	p.WithSearchTerms(terms.ToStrings()...)
+	RunPipeline(db, p)
+	zap.S().Info("[done] scan-repo")
+}
+
+// IngestArchive ...
+func IngestArchive(path string) {
+	zap.S().Infof("[run] ingest-gharchive %s", path)
+	db := db.Open(config.App.DatabaseSource())
+	defer db.Close()
+
+	terms, err := db.AllSearchTerms()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	source, err := pipeline.NewArchiveSource(path, terms.ToStrings())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Run the commit pipeline with every searchTerm, over the archived PushEvents.
+	p := pipeline.Commits(db)
+	p.WithSources(source)
+	p.WithSearchTerms(terms.ToStrings()...)
+	RunPipeline(db, p)
+	zap.S().Info("[done] ingest-gharchive")
+}
+
+// CommitSources returns the sources that are configured for fetching commits.
+func CommitSources() []pipeline.Source {
+	sources := []pipeline.Source{pipeline.NewGithubSource()}
+
+	if config.App.GitlabAPIKey != "" {
+		sources = append(sources, pipeline.NewGitlabSource())
+	}
+
+	for _, instance := range config.App.GiteaInstances {
+		sources = append(sources, pipeline.NewGiteaSource(instance))
+	}
+
+	return sources
+}
+
+// RankTerms ...
+func RankTerms() {
+	zap.S().Info("[run] rank-terms")
+	db := db.Open(config.App.DatabaseSource())
+	defer db.Close()
+
+	changed, err := pipeline.RankSearchTerms(db)
+	if err != nil {
+		zap.S().Error(err.Error())
+		sentry.CaptureException(err)
+		return
+	}
+	zap.S().Infof("[done] rank-terms, %d terms changed rank", changed)
+}
+
+// DedupeCommits ...
+func DedupeCommits() {
+	zap.S().Info("[run] dedupe")
+	db := db.Open(config.App.DatabaseSource())
+	defer db.Close()
+
+	duplicates, err := db.DedupeCommits()
+	if err != nil {
+		zap.S().Error(err.Error())
+		sentry.CaptureException(err)
+		return
+	}
+	zap.S().Infof("[done] dedupe, %d duplicate commits", duplicates)
+}
+
+// ReprocessCommits ...
+func ReprocessCommits() {
+	zap.S().Info("[run] reprocess")
+	db := db.Open(config.App.DatabaseSource())
+	defer db.Close()
+
+	blockWords, err := db.AllBlockWords()
+	if err != nil {
+		zap.S().Error(err.Error())
+		sentry.CaptureException(err)
+		return
+	}
+
+	flagged, updated, err := pipeline.Reprocess(db,
+		&pipeline.BotStage{Detector: utils.NewDefaultBotDetector()},
+		&pipeline.BlockStage{Blocker: utils.NewMessageBlocker(blockWords.ToStrings())},
+		&pipeline.LanguageStage{Detector: utils.NewTrigramLanguageDetector(), Allowed: config.App.LanguageAllowlist},
+	)
+	if err != nil {
+		zap.S().Error(err.Error())
+		sentry.CaptureException(err)
+		return
+	}
+	zap.S().Infof("[done] reprocess, %d commits flagged as not valid, %d updated", flagged, updated)
+}
+
+// LabelCommit ...
+func LabelCommit(commitID string, funny bool) {
+	ID, err := strconv.Atoi(commitID)
+	if err != nil {
+		log.Fatalf("Could not convert %v to a commit ID.\n", commitID)
+	}
+
+	db := db.Open(config.App.DatabaseSource())
+	defer db.Close()
+
+	if err := db.LabelCommit(ID, funny); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// TrainHumorModel ...
+func TrainHumorModel() {
+	zap.S().Info("[run] train")
+	db := db.Open(config.App.DatabaseSource())
+	defer db.Close()
+
+	model, err := pipeline.TrainHumorModel(db)
+	if err != nil {
+		zap.S().Error(err.Error())
+		sentry.CaptureException(err)
+		return
+	}
+	zap.S().Infof("  Trained humor model %d from %d labeled commits", model.ID, model.Examples)
+
+	// Rescore the saved commits with the new model.
+	scorer := pipeline.LatestScorer(db)
+	if scorer == nil {
+		zap.S().Warn("  The humor model could not be loaded, so the commits were not rescored")
+		return
+	}
+
+	_, updated, err := pipeline.Reprocess(db, &pipeline.ScoreStage{Scorer: scorer})
+	if err != nil {
+		zap.S().Error(err.Error())
+		sentry.CaptureException(err)
+		return
+	}
+	zap.S().Infof("[done] train, %d commits rescored", updated)
+}
+
+// ValidateMessage prints the result of every validation rule for the message.
+// Only the subject of a multi-line message is validated, like in the pipeline.
+func ValidateMessage(message string) {
+	commit := models.GitCommit{Message: message}
+	commit.SplitMessage()
+	checks := github.MessageValidator.Explain(commit.Message)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, check := range checks {
+		result := "PASS"
+		if !check.Passed {
+			result = "FAIL"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", result, check.Rule, check.Detail)
+	}
+	w.Flush()
+
+	if err := github.ValidateMessage(commit.Message); err != nil {
+		fmt.Printf("\nRejected: %v\n", err)
+		return
+	}
+	fmt.Println("\nAccepted")
+}
+
+// ShowRuns prints the most recent pipeline runs.
+func ShowRuns(limit int) {
+	db := db.Open(config.App.DatabaseSource())
+	defer db.Close()
+
+	runs, err := db.RecentPipelineRuns(limit)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "ID\tSTARTED\tTOOK\tTRIGGER\tTERMS\tFETCHED\tSAVED\tDUPES\tREJECTED\tERRORS\tCALLS\t")
+	for _, run := range runs {
+		fmt.Fprintf(w, "%d\t%s\t%v\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t\n",
+			run.ID, run.StartedAt.Format("2006-01-02 15:04"), run.Duration(), run.Trigger, len(run.TermList()),
+			run.Fetched, run.Saved, run.Duplicates, run.Rejected, len(run.ErrorList()), run.APICalls)
+	}
+	w.Flush()
+}
+
+// ShowWebhookDeliveries prints the most recent webhook deliveries.
+func ShowWebhookDeliveries(limit int) {
+	db := db.Open(config.App.DatabaseSource())
+	defer db.Close()
+
+	deliveries, err := db.RecentWebhookDeliveries(limit)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCREATED\tEVENT\tURL\tSTATUS\tATTEMPTS\tDELIVERED\tERROR\t")
+	for _, d := range deliveries {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d\t%v\t%s\t\n",
+			d.ID, d.CreatedAt.Format("2006-01-02 15:04"), d.Event, d.URL, d.StatusCode, d.Attempts, d.Delivered, d.Error)
+	}
+	w.Flush()
+}
+
 // CheckRateLimits ...
 func CheckRateLimits() {
 	zap.S().Infof("[run] limits")
@@ -8,7 +8,13 @@ const header = `
 
 document.addEventListener('DOMContentLoaded', (e) => {
     console.log(header);
+
+    // The censor toggle is on the homepage and the search page.
+    if (!document.getElementById('censor-toggle')) { return; }
     document.getElementById('censor-toggle').addEventListener('click', toggleCensoredCommits);
+
+    // The buttons to fetch more commits are only on the homepage.
+    if (!document.getElementById('more-commits')) { return; }
     document.getElementById('more-commits').addEventListener('click', fetchMoreCommits());
     document.getElementById('poop-commits').addEventListener('click', fetchMoreCommits('poop'));
     document.getElementById('holy-commits').addEventListener('click', fetchMoreCommits('holy'));
@@ -34,10 +40,12 @@ function toggleCensoredCommits() {
 }
 
 // This function fetches a new batch of random commits and places it in the commits container.
+// The commits stay in the language of the page, if it was picked with the `lang` query param.
 function fetchMoreCommits(group) {
     group = typeof(group) === 'undefined' ? '' : group;
     return function() {
-        makeRequest(`/?group=${group}&fragment=true`, (html) => {
+        const lang = new URLSearchParams(window.location.search).get('lang') || '';
+        makeRequest(`/?group=${group}&lang=${encodeURIComponent(lang)}&fragment=true`, (html) => {
             document.getElementById('commit-items').innerHTML = html;
         }, 'when fetching commits');
     }